@@ -0,0 +1,254 @@
+package app
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"movie-api/api/config"
+	"movie-api/api/health"
+	"movie-api/api/jwtkeys"
+	"movie-api/api/mail"
+	"movie-api/api/middleware"
+	"movie-api/api/oidc"
+	movieHandler "movie-api/api/resource/movie/handler"
+	userHandler "movie-api/api/resource/user/handler"
+	userHelpers "movie-api/api/resource/user/helpers"
+	"movie-api/api/routes"
+	"movie-api/api/storage"
+
+	"github.com/gin-gonic/gin"
+	"github.com/go-playground/validator/v10"
+)
+
+// App is one instance of the API. It owns everything its handlers use, so several
+// instances can run side by side in one process, e.g. each on its own in-memory storage.
+type App struct {
+	Config   config.Config
+	Storage  *storage.Storage
+	Auth     *userHelpers.Service
+	Validate *validator.Validate
+	Logger   *log.Logger
+	Router   *gin.Engine
+
+	// Health runs the checks behind /readyz. Register checks for further dependencies on it.
+	Health *health.Checker
+
+	// jobs tracks the background jobs, so shutting down can wait for them.
+	jobs sync.WaitGroup
+}
+
+// New builds an App serving the data of store. It fails when the signing keys cannot
+// be loaded, as tokens can then neither be issued nor verified.
+func New(settings config.Config, store *storage.Storage, logger *log.Logger) (*App, error) {
+	signingKeys, err := jwtkeys.LoadDir(settings.Auth.JWTKeyDir)
+	if err != nil {
+		return nil, fmt.Errorf("Error loading JWT signing keys: %w", err)
+	}
+
+	sender, err := mail.NewSender(mail.Config{
+		Kind:         settings.Mail.Sender,
+		From:         settings.Mail.From,
+		Dir:          settings.Mail.Dir,
+		SMTPHost:     settings.Mail.SMTPHost,
+		SMTPPort:     settings.Mail.SMTPPort,
+		SMTPUsername: settings.Mail.SMTPUsername,
+		SMTPPassword: settings.Mail.SMTPPassword,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	auth, err := userHelpers.NewService(accountConfig(settings), store, signingKeys, sender, logger)
+	if err != nil {
+		return nil, err
+	}
+
+	app := &App{
+		Config:   settings,
+		Storage:  store,
+		Auth:     auth,
+		Validate: validator.New(),
+		Logger:   logger,
+		Health:   health.NewChecker(settings.Server.HealthCheckTimeout),
+	}
+
+	for name, check := range store.HealthChecks() {
+		app.Health.Register(name, check)
+	}
+
+	if app.Router, err = app.newRouter(); err != nil {
+		return nil, err
+	}
+
+	return app, nil
+}
+
+// accountConfig picks the settings of the account operations.
+func accountConfig(settings config.Config) userHelpers.Config {
+	accounts := userHelpers.Config{
+		AppBaseURL:          settings.Server.BaseURL,
+		MFAIssuer:           settings.Auth.MFAIssuer,
+		DeletionGracePeriod: settings.Auth.DeletionGracePeriod,
+		LoginGuardStore:     settings.Auth.LoginGuardStore,
+
+		AccessTokenLifetime:            settings.Auth.AccessTokenLifetime,
+		RefreshTokenLifetime:           settings.Auth.RefreshTokenLifetime,
+		EmailVerificationTokenLifetime: settings.Auth.EmailVerificationTokenLifetime,
+		PasswordResetTokenLifetime:     settings.Auth.PasswordResetTokenLifetime,
+		BcryptCost:                     settings.Auth.BcryptCost,
+	}
+
+	if settings.OIDC.Issuer != "" {
+		accounts.OIDC = &oidc.Config{
+			Issuer:       settings.OIDC.Issuer,
+			ClientID:     settings.OIDC.ClientID,
+			ClientSecret: settings.OIDC.ClientSecret,
+			RedirectURL:  settings.OIDC.RedirectURL,
+			Scopes:       settings.OIDC.Scopes,
+		}
+	}
+
+	return accounts
+}
+
+func (a *App) newRouter() (*gin.Engine, error) {
+	// Each request is logged once, and a panic answers 500 instead of ending the server
+	router := gin.New()
+	router.Use(gin.Logger(), gin.Recovery())
+
+	if err := router.SetTrustedProxies(a.Config.Server.TrustedProxies); err != nil {
+		return nil, fmt.Errorf("Invalid server.trusted_proxies (TRUSTED_PROXIES): %w", err)
+	}
+
+	unverifiedPermissions, err := middleware.ParseUnverifiedPermissions(a.Config.Auth.UnverifiedPermissions)
+	if err != nil {
+		return nil, fmt.Errorf("Invalid auth.unverified_permissions (UNVERIFIED_USER_PERMISSIONS): %w", err)
+	}
+
+	// Registered before the CORS and rate limit middleware, which must not apply to probes
+	routes.HealthRoutes(router, a.Health)
+
+	if len(a.Config.CORS.AllowedOrigins) > 0 {
+		router.Use(middleware.CORS(a.Config.CORS))
+	}
+	if a.Config.RateLimit.RequestsPerMinute > 0 {
+		router.Use(middleware.RateLimit(a.Config.RateLimit))
+	}
+
+	auth := middleware.NewAuth(a.Auth, unverifiedPermissions, a.Logger)
+	movies := movieHandler.New(a.Storage.Movies, a.Validate)
+	users := userHandler.New(a.Auth, auth, a.Validate, a.Logger)
+
+	routes.MoviesRoutes(router, auth, movies)
+	routes.AuthRoutes(router, auth, users)
+	routes.UserRoutes(router, auth, users)
+	routes.AdminRoutes(router, auth, users)
+	routes.WellKnownRoutes(router, users)
+
+	return router, nil
+}
+
+// ServeHTTP lets the App be used as an http.Handler, e.g. with httptest.NewServer.
+func (a *App) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	a.Router.ServeHTTP(w, r)
+}
+
+// RunBackgroundJobs starts the App's periodic jobs, which stop when ctx is done. Run
+// starts them itself; call it only when serving the Router some other way.
+func (a *App) RunBackgroundJobs(ctx context.Context) {
+	a.jobs.Add(2)
+
+	// Remove deleted accounts once their grace period is over
+	go func() {
+		defer a.jobs.Done()
+		a.Auth.RunAccountPurger(ctx, time.Hour)
+	}()
+
+	// Pick up keys added to JWT_KEY_DIR for rotation
+	go func() {
+		defer a.jobs.Done()
+		a.Auth.RunKeyReloader(ctx, time.Minute)
+	}()
+}
+
+// Run serves the API on the configured port, and runs the background jobs, until ctx is
+// done. It then shuts down in order, all within the configured shutdown timeout: readiness
+// fails for the shutdown delay, the server stops accepting connections and lets in-flight
+// requests finish, the background jobs stop, and the storage is closed last as both may
+// still use it.
+func (a *App) Run(ctx context.Context) error {
+	server := &http.Server{
+		Addr:         ":" + strconv.Itoa(a.Config.Server.Port),
+		Handler:      a.Router,
+		ReadTimeout:  a.Config.Server.ReadTimeout,
+		WriteTimeout: a.Config.Server.WriteTimeout,
+		IdleTimeout:  a.Config.Server.IdleTimeout,
+		ErrorLog:     a.Logger,
+	}
+
+	jobsContext, stopJobs := context.WithCancel(context.Background())
+	defer stopJobs()
+	a.RunBackgroundJobs(jobsContext)
+
+	serverErr := make(chan error, 1)
+	go func() {
+		a.Logger.Printf("Starting server on port: %v\n", a.Config.Server.Port)
+		serverErr <- server.ListenAndServe()
+	}()
+
+	var errs []error
+	select {
+	case err := <-serverErr:
+		// The server could not start, but the jobs and the storage still need closing
+		errs = append(errs, err)
+	case <-ctx.Done():
+	}
+
+	// One deadline covers the whole shutdown, the delay included
+	shutdownContext, cancel := context.WithTimeout(context.Background(), a.Config.Server.ShutdownTimeout)
+	defer cancel()
+
+	if len(errs) == 0 {
+		// Fail readiness first, and keep serving while load balancers notice
+		a.Health.SetShuttingDown()
+		if delay := a.Config.Server.ShutdownDelay; delay > 0 {
+			a.Logger.Printf("Shutting down in %s\n", delay)
+			select {
+			case <-time.After(delay):
+			case <-shutdownContext.Done():
+			}
+		}
+		a.Logger.Println("Shutting down, waiting for in-flight requests")
+	}
+
+	if err := server.Shutdown(shutdownContext); err != nil {
+		errs = append(errs, fmt.Errorf("Error draining requests: %w", err))
+		server.Close()
+	}
+
+	stopJobs()
+	jobsDone := make(chan struct{})
+	go func() {
+		a.jobs.Wait()
+		close(jobsDone)
+	}()
+	select {
+	case <-jobsDone:
+	case <-shutdownContext.Done():
+		errs = append(errs, fmt.Errorf("Background jobs did not stop within %s", a.Config.Server.ShutdownTimeout))
+	}
+
+	// An expired context makes Close cut the operations still in progress
+	if err := a.Storage.Close(shutdownContext); err != nil {
+		errs = append(errs, fmt.Errorf("Error closing storage: %w", err))
+	}
+
+	a.Logger.Println("Server stopped")
+	return errors.Join(errs...)
+}
@@ -0,0 +1,452 @@
+package app
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"movie-api/api/config"
+	"movie-api/api/jwtkeys"
+	"movie-api/api/middleware"
+	movieModels "movie-api/api/resource/movie/model"
+	models "movie-api/api/resource/user/model"
+	"movie-api/api/resource/user/repository"
+	"movie-api/api/storage"
+
+	"github.com/gin-gonic/gin"
+	"golang.org/x/crypto/bcrypt"
+)
+
+func TestMain(m *testing.M) {
+	// Keep the request log of every test out of the output
+	gin.SetMode(gin.TestMode)
+	gin.DefaultWriter = io.Discard
+
+	os.Exit(m.Run())
+}
+
+// testAPI is an App on in-memory storage, served by an httptest server.
+type testAPI struct {
+	t      *testing.T
+	app    *App
+	server *httptest.Server
+}
+
+// newTestAPI starts an App with its own storage and signing key. change may adjust
+// the settings first.
+func newTestAPI(t *testing.T, change func(settings *config.Config)) *testAPI {
+	t.Helper()
+
+	keyDir := t.TempDir()
+	signer, err := jwtkeys.GenerateKey(jwtkeys.EdDSA)
+	if err != nil {
+		t.Fatal(err)
+	}
+	key, err := jwtkeys.EncodeKey(signer, time.Time{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(keyDir, "test.pem"), key, 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	settings := config.Default()
+	settings.Database.Backend = "memory"
+	settings.Auth.JWTKeyDir = keyDir
+	settings.Auth.LoginGuardStore = "memory"
+	settings.Mail.Sender = "file"
+	settings.Mail.Dir = t.TempDir()
+	settings.Auth.BcryptCost = bcrypt.MinCost
+	if change != nil {
+		change(&settings)
+	}
+
+	store := storage.NewMemory()
+	if err := store.Movies.SeedIfEmpty(context.Background(), movieModels.SeedMovies); err != nil {
+		t.Fatal(err)
+	}
+
+	app, err := New(settings, store, log.New(io.Discard, "", 0))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	server := httptest.NewServer(app)
+	t.Cleanup(server.Close)
+
+	return &testAPI{t: t, app: app, server: server}
+}
+
+// call sends body as JSON with token as the bearer token, decodes the response into
+// out unless it is nil, and returns the status code.
+func (api *testAPI) call(method, path, token string, body, out any) int {
+	api.t.Helper()
+
+	var reader io.Reader
+	if body != nil {
+		data, err := json.Marshal(body)
+		if err != nil {
+			api.t.Fatal(err)
+		}
+		reader = bytes.NewReader(data)
+	}
+
+	request, err := http.NewRequest(method, api.server.URL+path, reader)
+	if err != nil {
+		api.t.Fatal(err)
+	}
+	request.Header.Set("Content-Type", "application/json")
+	if token != "" {
+		request.Header.Set("Authorization", "Bearer "+token)
+	}
+
+	response, err := api.server.Client().Do(request)
+	if err != nil {
+		api.t.Fatal(err)
+	}
+	defer response.Body.Close()
+
+	if out != nil {
+		if err := json.NewDecoder(response.Body).Decode(out); err != nil {
+			api.t.Fatalf("%s %s: decoding the %d response: %v", method, path, response.StatusCode, err)
+		}
+	}
+
+	return response.StatusCode
+}
+
+// expect fails the test unless the request answers with status.
+func (api *testAPI) expect(status int, method, path, token string, body, out any) {
+	api.t.Helper()
+
+	if got := api.call(method, path, token, body, out); got != status {
+		api.t.Fatalf("%s %s = %d, want %d", method, path, got, status)
+	}
+}
+
+// testUser is a registered user and the tokens of their last login.
+type testUser struct {
+	id       string
+	email    string
+	password string
+	tokens   models.TokenPair
+}
+
+var phoneNumbers atomic.Int64
+
+// register signs up a new user.
+func (api *testAPI) register(name string) *testUser {
+	api.t.Helper()
+
+	user := &testUser{email: name + "@example.com", password: "correct horse " + name}
+	body := map[string]any{
+		"first_name":    name,
+		"last_name":     "Tester",
+		"email_address": user.email,
+		"password":      user.password,
+		"phone_number":  fmt.Sprintf("+1202555%04d", phoneNumbers.Add(1)),
+	}
+
+	var response models.AuthResponse
+	api.expect(http.StatusCreated, "POST", "/auth/register", "", body, &response)
+	user.id = response.User.User_id
+	user.tokens = response.Tokens
+
+	return user
+}
+
+// login logs user in again and keeps the new tokens.
+func (api *testAPI) login(user *testUser) {
+	api.t.Helper()
+
+	var response models.AuthResponse
+	api.expect(http.StatusOK, "POST", "/auth/login", "", map[string]any{"email_address": user.email, "password": user.password}, &response)
+	user.tokens = response.Tokens
+}
+
+// registerAdmin signs up a user, makes them a verified admin and logs them in again
+// so their tokens carry the role.
+func (api *testAPI) registerAdmin(name string) *testUser {
+	api.t.Helper()
+
+	user := api.register(name)
+	ctx := context.Background()
+	role := middleware.RoleAdmin
+
+	if _, err := api.app.Auth.Users.UpdateProfile(ctx, user.id, models.UpdateUserRequest{User_type: &role}, time.Now()); err != nil {
+		api.t.Fatal(err)
+	}
+	if _, err := api.app.Auth.Users.MarkEmailVerified(ctx, user.id, user.email, time.Now()); err != nil {
+		api.t.Fatal(err)
+	}
+
+	api.login(user)
+	return user
+}
+
+func TestRequestsAreLoggedOnce(t *testing.T) {
+	var requestLog bytes.Buffer
+	gin.DefaultWriter = &requestLog
+	defer func() { gin.DefaultWriter = io.Discard }()
+
+	api := newTestAPI(t, nil)
+	api.expect(http.StatusNotFound, "GET", "/no-such-page", "", nil, nil)
+
+	if got := strings.Count(requestLog.String(), "/no-such-page"); got != 1 {
+		t.Errorf("request logged %d times, want once:\n%s", got, requestLog.String())
+	}
+}
+
+func TestRegister(t *testing.T) {
+	api := newTestAPI(t, nil)
+
+	body := map[string]any{
+		"first_name":    "Mallory",
+		"last_name":     "Tester",
+		"email_address": "mallory@example.com",
+		"password":      "correct horse",
+		"phone_number":  "+12025550100",
+		"user_type":     "ADMIN",
+	}
+
+	var response models.AuthResponse
+	api.expect(http.StatusCreated, "POST", "/auth/register", "", body, &response)
+
+	if response.User.User_type == nil || *response.User.User_type != middleware.RoleUser {
+		t.Errorf("user_type = %v, want USER whatever the request asks for", response.User.User_type)
+	}
+	if response.User.Email_verified {
+		t.Error("a new user's email address is verified")
+	}
+	if response.Tokens.Access_token == "" || response.Tokens.Refresh_token == "" {
+		t.Errorf("tokens = %+v, want an access and a refresh token", response.Tokens)
+	}
+
+	// The role cannot be used either
+	api.expect(http.StatusForbidden, "GET", "/users/", response.Tokens.Access_token, nil, nil)
+
+	// The email address and phone number are taken now
+	body["phone_number"] = "+12025550101"
+	api.expect(http.StatusBadRequest, "POST", "/auth/register", "", body, nil)
+	body["email_address"] = "other@example.com"
+	body["phone_number"] = "+12025550100"
+	api.expect(http.StatusBadRequest, "POST", "/auth/register", "", body, nil)
+}
+
+func TestStorageRejectsTakenAddresses(t *testing.T) {
+	api := newTestAPI(t, nil)
+	alice := api.register("alice")
+	bob := api.register("bob")
+	ctx := context.Background()
+
+	// Registrations racing past the checks of RegisterUser are stopped by the storage
+	stored, err := api.app.Auth.Users.FindByID(ctx, alice.id, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	otherPhoneNumber := "+12025559999"
+	otherEmail := "other@example.com"
+
+	sameEmail := stored
+	sameEmail.User_id, sameEmail.Phone_number = "same-email", &otherPhoneNumber
+	if err := api.app.Auth.Users.Insert(ctx, sameEmail); !errors.Is(err, repository.ErrDuplicateEmail) {
+		t.Errorf("insert with alice's email address: err = %v, want ErrDuplicateEmail", err)
+	}
+
+	samePhoneNumber := stored
+	samePhoneNumber.User_id, samePhoneNumber.Email_address = "same-phone-number", &otherEmail
+	if err := api.app.Auth.Users.Insert(ctx, samePhoneNumber); !errors.Is(err, repository.ErrDuplicatePhoneNumber) {
+		t.Errorf("insert with alice's phone number: err = %v, want ErrDuplicatePhoneNumber", err)
+	}
+
+	_, err = api.app.Auth.Users.UpdateProfile(ctx, bob.id, models.UpdateUserRequest{Phone_number: stored.Phone_number}, time.Now())
+	if !errors.Is(err, repository.ErrDuplicatePhoneNumber) {
+		t.Errorf("bob taking alice's phone number: err = %v, want ErrDuplicatePhoneNumber", err)
+	}
+}
+
+func TestRegisterValidates(t *testing.T) {
+	api := newTestAPI(t, nil)
+
+	api.expect(http.StatusBadRequest, "POST", "/auth/register", "", map[string]any{"email_address": "not an address"}, nil)
+}
+
+func TestLogin(t *testing.T) {
+	api := newTestAPI(t, nil)
+	alice := api.register("alice")
+
+	api.expect(http.StatusBadRequest, "POST", "/auth/login", "", map[string]any{"email_address": alice.email, "password": "wrong password"}, nil)
+	api.expect(http.StatusBadRequest, "POST", "/auth/login", "", map[string]any{"email_address": "nobody@example.com", "password": "wrong password"}, nil)
+
+	api.login(alice)
+
+	var profile models.SelfProfile
+	api.expect(http.StatusOK, "GET", "/users/"+alice.id, alice.tokens.Access_token, nil, &profile)
+	if profile.Email_address == nil || *profile.Email_address != alice.email {
+		t.Errorf("profile = %+v, want alice's", profile)
+	}
+
+	api.expect(http.StatusUnauthorized, "GET", "/users/"+alice.id, "", nil, nil)
+	api.expect(http.StatusUnauthorized, "GET", "/users/"+alice.id, "not.a.token", nil, nil)
+}
+
+func TestLoginThrottlesFailures(t *testing.T) {
+	api := newTestAPI(t, nil)
+	alice := api.register("alice")
+	wrong := map[string]any{"email_address": alice.email, "password": "wrong password"}
+
+	// The default account policy allows three free attempts, the fourth failure backs off
+	for i := 0; i < 4; i++ {
+		api.expect(http.StatusBadRequest, "POST", "/auth/login", "", wrong, nil)
+	}
+
+	// Even the right password waits for the backoff
+	status := api.call("POST", "/auth/login", "", map[string]any{"email_address": alice.email, "password": alice.password}, nil)
+	if status != http.StatusTooManyRequests {
+		t.Fatalf("login during the backoff = %d, want 429", status)
+	}
+
+	// So do other checks of the password
+	api.expect(http.StatusTooManyRequests, "POST", "/users/"+alice.id+"/password", alice.tokens.Access_token,
+		map[string]any{"current_password": alice.password, "new_password": "new password"}, nil)
+}
+
+func TestForgotPasswordIsThrottled(t *testing.T) {
+	api := newTestAPI(t, nil)
+	alice := api.register("alice")
+
+	// Unknown addresses are throttled alike, so the answers do not tell them apart
+	for _, email := range []string{alice.email, "nobody@example.com"} {
+		for i := 0; i < 4; i++ {
+			api.expect(http.StatusAccepted, "POST", "/auth/forgot-password", "", map[string]any{"email_address": email}, nil)
+		}
+		api.expect(http.StatusTooManyRequests, "POST", "/auth/forgot-password", "", map[string]any{"email_address": email}, nil)
+	}
+}
+
+func TestRefresh(t *testing.T) {
+	api := newTestAPI(t, nil)
+	alice := api.register("alice")
+	first := alice.tokens
+
+	var second models.TokenPair
+	api.expect(http.StatusOK, "POST", "/auth/refresh", "", map[string]any{"refresh_token": first.Refresh_token}, &second)
+	if second.Refresh_token == first.Refresh_token || second.Access_token == "" {
+		t.Fatalf("refreshed tokens = %+v, want a new pair", second)
+	}
+	api.expect(http.StatusOK, "GET", "/users/"+alice.id, second.Access_token, nil, nil)
+
+	// An access token is not a refresh token
+	api.expect(http.StatusUnauthorized, "POST", "/auth/refresh", "", map[string]any{"refresh_token": second.Access_token}, nil)
+
+	// Using a refresh token twice logs the user out everywhere
+	api.expect(http.StatusUnauthorized, "POST", "/auth/refresh", "", map[string]any{"refresh_token": first.Refresh_token}, nil)
+	api.expect(http.StatusUnauthorized, "GET", "/users/"+alice.id, second.Access_token, nil, nil)
+	api.expect(http.StatusUnauthorized, "POST", "/auth/refresh", "", map[string]any{"refresh_token": second.Refresh_token}, nil)
+
+	// Logging in again right away works
+	api.login(alice)
+	api.expect(http.StatusOK, "GET", "/users/"+alice.id, alice.tokens.Access_token, nil, nil)
+}
+
+func TestLogoutAll(t *testing.T) {
+	api := newTestAPI(t, nil)
+	alice := api.register("alice")
+	first := alice.tokens
+	api.login(alice)
+	second := alice.tokens
+
+	api.expect(http.StatusOK, "POST", "/auth/logout-all", second.Access_token, nil, nil)
+
+	for _, tokens := range []models.TokenPair{first, second} {
+		api.expect(http.StatusUnauthorized, "GET", "/users/"+alice.id, tokens.Access_token, nil, nil)
+		api.expect(http.StatusUnauthorized, "POST", "/auth/refresh", "", map[string]any{"refresh_token": tokens.Refresh_token}, nil)
+	}
+
+	// Tokens issued right after stay valid, including after another logout of all devices
+	for i := 0; i < 2; i++ {
+		api.login(alice)
+		api.expect(http.StatusOK, "GET", "/users/"+alice.id, alice.tokens.Access_token, nil, nil)
+		api.expect(http.StatusOK, "POST", "/auth/logout-all", alice.tokens.Access_token, nil, nil)
+		api.expect(http.StatusUnauthorized, "GET", "/users/"+alice.id, alice.tokens.Access_token, nil, nil)
+	}
+}
+
+func TestChangePassword(t *testing.T) {
+	api := newTestAPI(t, nil)
+	alice := api.register("alice")
+	old := alice.tokens
+
+	var tokens models.TokenPair
+	api.expect(http.StatusOK, "POST", "/users/"+alice.id+"/password", old.Access_token,
+		map[string]any{"current_password": alice.password, "new_password": "new password"}, &tokens)
+
+	// Tokens issued before the change are revoked, however recently
+	api.expect(http.StatusUnauthorized, "GET", "/users/"+alice.id, old.Access_token, nil, nil)
+	api.expect(http.StatusOK, "GET", "/users/"+alice.id, tokens.Access_token, nil, nil)
+
+	api.expect(http.StatusBadRequest, "POST", "/auth/login", "", map[string]any{"email_address": alice.email, "password": alice.password}, nil)
+	alice.password = "new password"
+	api.login(alice)
+}
+
+func TestSearchMovies(t *testing.T) {
+	api := newTestAPI(t, nil)
+	alice := api.register("alice")
+
+	var page struct {
+		Data []struct {
+			Title string  `json:"title"`
+			Score float64 `json:"score"`
+		} `json:"data"`
+		Total int64 `json:"total"`
+	}
+	api.expect(http.StatusOK, "GET", "/movies/search?q=fihgt+clb", alice.tokens.Access_token, nil, &page)
+	if page.Total != 0 {
+		t.Errorf("search with a typo in a short word found %d movies", page.Total)
+	}
+
+	// Typos are found wherever they are in the word
+	for _, q := range []string{"fihgt+club", "ifght+club", "fight+lcub"} {
+		api.expect(http.StatusOK, "GET", "/movies/search?q="+q, alice.tokens.Access_token, nil, &page)
+		if page.Total != 1 || page.Data[0].Title != "Fight Club" || page.Data[0].Score <= 0 {
+			t.Errorf("search results for %s = %+v, want Fight Club", q, page)
+		}
+	}
+
+	api.expect(http.StatusBadRequest, "GET", "/movies/search", alice.tokens.Access_token, nil, nil)
+}
+
+func TestSearchMoviesCountsEveryMatch(t *testing.T) {
+	api := newTestAPI(t, nil)
+	alice := api.register("alice")
+
+	const sequels = 1200
+	for i := 1; i <= sequels; i++ {
+		movie := movieModels.Movie{Movie_id: uint64(1000 + i), Title: fmt.Sprintf("Sequel %d", i), Popularity: float64(i)}
+		if err := api.app.Storage.Movies.Insert(context.Background(), &movie); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	var page struct {
+		Data  []movieModels.Movie `json:"data"`
+		Total int64               `json:"total"`
+	}
+	api.expect(http.StatusOK, "GET", "/movies/search?q=seqeul&limit=5", alice.tokens.Access_token, nil, &page)
+	if page.Total != sequels || len(page.Data) != 5 {
+		t.Errorf("total = %d with %d on the page, want %d with 5", page.Total, len(page.Data), sequels)
+	}
+}
@@ -0,0 +1,180 @@
+package app
+
+import (
+	"context"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"movie-api/api/loginguard"
+	models "movie-api/api/resource/user/model"
+	"movie-api/api/totp"
+)
+
+// code returns the TOTP code of secret steps time steps from now. Every accepted code
+// must be from a later step than the last one, so tests move forward one step at a time.
+func code(t *testing.T, secret string, steps int64) string {
+	t.Helper()
+
+	code, err := totp.Code(secret, totp.Step(time.Now())+steps)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return code
+}
+
+// enableMFA turns two-factor authentication on for user and returns the secret and the
+// recovery codes. The tokens of user are replaced by those the confirmation returns.
+func (api *testAPI) enableMFA(user *testUser) (string, []string) {
+	api.t.Helper()
+
+	var enrollment models.MFAEnrollment
+	api.expect(http.StatusOK, "POST", "/auth/mfa/enroll", user.tokens.Access_token, nil, &enrollment)
+
+	var confirmed models.RecoveryCodes
+	api.expect(http.StatusOK, "POST", "/auth/mfa/confirm", user.tokens.Access_token, map[string]any{"code": code(api.t, enrollment.Secret, 0)}, &confirmed)
+	if len(confirmed.Recovery_codes) == 0 || confirmed.Tokens == nil {
+		api.t.Fatalf("confirmation = %+v, want recovery codes and tokens", confirmed)
+	}
+	user.tokens = *confirmed.Tokens
+
+	return enrollment.Secret, confirmed.Recovery_codes
+}
+
+func TestMFALogin(t *testing.T) {
+	api := newTestAPI(t, nil)
+	alice := api.register("alice")
+	secret, recoveryCodes := api.enableMFA(alice)
+
+	api.expect(http.StatusOK, "GET", "/users/"+alice.id, alice.tokens.Access_token, nil, nil)
+
+	// The password alone only gets a challenge
+	credentials := map[string]any{"email_address": alice.email, "password": alice.password}
+	var challenge models.MFAChallengeResponse
+	api.expect(http.StatusOK, "POST", "/auth/login", "", credentials, &challenge)
+	if !challenge.Mfa_required || challenge.Mfa_token == "" {
+		t.Fatalf("login response = %+v, want an MFA challenge", challenge)
+	}
+	api.expect(http.StatusUnauthorized, "GET", "/users/"+alice.id, challenge.Mfa_token, nil, nil)
+
+	api.expect(http.StatusUnauthorized, "POST", "/auth/login/mfa", "", map[string]any{"mfa_token": challenge.Mfa_token, "code": "000000"}, nil)
+
+	var response models.AuthResponse
+	api.expect(http.StatusOK, "POST", "/auth/login/mfa", "", map[string]any{"mfa_token": challenge.Mfa_token, "code": code(t, secret, 1)}, &response)
+	api.expect(http.StatusOK, "GET", "/users/"+alice.id, response.Tokens.Access_token, nil, nil)
+
+	// A challenge completes one login only
+	api.expect(http.StatusUnauthorized, "POST", "/auth/login/mfa", "", map[string]any{"mfa_token": challenge.Mfa_token, "recovery_code": recoveryCodes[0]}, nil)
+
+	// A recovery code works once
+	api.expect(http.StatusOK, "POST", "/auth/login", "", credentials, &challenge)
+	api.expect(http.StatusOK, "POST", "/auth/login/mfa", "", map[string]any{"mfa_token": challenge.Mfa_token, "recovery_code": recoveryCodes[0]}, nil)
+	api.expect(http.StatusOK, "POST", "/auth/login", "", credentials, &challenge)
+	api.expect(http.StatusUnauthorized, "POST", "/auth/login/mfa", "", map[string]any{"mfa_token": challenge.Mfa_token, "recovery_code": recoveryCodes[0]}, nil)
+}
+
+func TestMFACodeCannotBeReplayed(t *testing.T) {
+	api := newTestAPI(t, nil)
+	alice := api.register("alice")
+	secret, _ := api.enableMFA(alice)
+
+	credentials := map[string]any{"email_address": alice.email, "password": alice.password}
+	current := code(t, secret, 1)
+
+	var challenge models.MFAChallengeResponse
+	api.expect(http.StatusOK, "POST", "/auth/login", "", credentials, &challenge)
+	api.expect(http.StatusOK, "POST", "/auth/login/mfa", "", map[string]any{"mfa_token": challenge.Mfa_token, "code": current}, nil)
+
+	api.expect(http.StatusOK, "POST", "/auth/login", "", credentials, &challenge)
+	api.expect(http.StatusUnauthorized, "POST", "/auth/login/mfa", "", map[string]any{"mfa_token": challenge.Mfa_token, "code": current}, nil)
+}
+
+func TestMFALoginReportsLockedAccounts(t *testing.T) {
+	api := newTestAPI(t, nil)
+	alice := api.register("alice")
+	secret, _ := api.enableMFA(alice)
+
+	var challenge models.MFAChallengeResponse
+	api.expect(http.StatusOK, "POST", "/auth/login", "", map[string]any{"email_address": alice.email, "password": alice.password}, &challenge)
+
+	// Reach the lockout threshold without waiting for the backoff in between
+	for i := 0; i < loginguard.DefaultAccountPolicy.LockoutThreshold; i++ {
+		if err := api.app.Auth.LoginGuard.RecordFailure(context.Background(), alice.email, "192.0.2.1", loginguard.ReasonWrongMFACode); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	var response struct {
+		Message string `json:"message"`
+	}
+	api.expect(http.StatusTooManyRequests, "POST", "/auth/login/mfa", "", map[string]any{"mfa_token": challenge.Mfa_token, "code": code(t, secret, 1)}, &response)
+	if !strings.Contains(response.Message, "locked") {
+		t.Errorf("message = %q, want it to say the account is locked", response.Message)
+	}
+}
+
+func TestDisableMFA(t *testing.T) {
+	api := newTestAPI(t, nil)
+	alice := api.register("alice")
+	_, recoveryCodes := api.enableMFA(alice)
+	token := alice.tokens.Access_token
+
+	api.expect(http.StatusBadRequest, "POST", "/auth/mfa/disable", token, map[string]any{"password": "wrong password", "recovery_code": recoveryCodes[0]}, nil)
+	api.expect(http.StatusBadRequest, "POST", "/auth/mfa/disable", token, map[string]any{"password": alice.password, "recovery_code": "aaaaa-aaaaa"}, nil)
+	api.expect(http.StatusOK, "POST", "/auth/mfa/disable", token, map[string]any{"password": alice.password, "recovery_code": recoveryCodes[0]}, nil)
+
+	// The password is enough again
+	var response models.AuthResponse
+	api.expect(http.StatusOK, "POST", "/auth/login", "", map[string]any{"email_address": alice.email, "password": alice.password}, &response)
+	if response.Tokens.Access_token == "" {
+		t.Errorf("login after disabling MFA = %+v, want tokens", response)
+	}
+}
+
+func TestDisableMFAThrottlesWrongPasswords(t *testing.T) {
+	api := newTestAPI(t, nil)
+	alice := api.register("alice")
+	_, recoveryCodes := api.enableMFA(alice)
+	token := alice.tokens.Access_token
+	wrong := map[string]any{"password": "wrong password", "recovery_code": recoveryCodes[0]}
+
+	for i := 0; i < 4; i++ {
+		api.expect(http.StatusBadRequest, "POST", "/auth/mfa/disable", token, wrong, nil)
+	}
+
+	api.expect(http.StatusTooManyRequests, "POST", "/auth/mfa/disable", token, map[string]any{"password": alice.password, "recovery_code": recoveryCodes[0]}, nil)
+}
+
+func TestRegenerateRecoveryCodesThrottlesWrongCodes(t *testing.T) {
+	api := newTestAPI(t, nil)
+	alice := api.register("alice")
+	secret, _ := api.enableMFA(alice)
+	token := alice.tokens.Access_token
+
+	for i := 0; i < 4; i++ {
+		api.expect(http.StatusBadRequest, "POST", "/auth/mfa/recovery-codes", token, map[string]any{"code": code(t, secret, 100)}, nil)
+	}
+
+	api.expect(http.StatusTooManyRequests, "POST", "/auth/mfa/recovery-codes", token, map[string]any{"code": code(t, secret, 1)}, nil)
+}
+
+func TestAdminMustEnrollWhenRequired(t *testing.T) {
+	api := newTestAPI(t, nil)
+	admin := api.registerAdmin("admin")
+
+	api.expect(http.StatusOK, "PUT", "/admin/settings/security", admin.tokens.Access_token, map[string]any{"require_admin_mfa": true}, nil)
+
+	var response models.AuthResponse
+	api.expect(http.StatusOK, "POST", "/auth/login", "", map[string]any{"email_address": admin.email, "password": admin.password}, &response)
+	if !response.Mfa_enrollment_required {
+		t.Fatalf("login response = %+v, want enrolment required", response)
+	}
+	admin.tokens = response.Tokens
+
+	// The restricted token only reaches the enrolment endpoints
+	api.expect(http.StatusForbidden, "GET", "/users/", admin.tokens.Access_token, nil, nil)
+
+	api.enableMFA(admin)
+	api.expect(http.StatusOK, "GET", "/users/", admin.tokens.Access_token, nil, nil)
+}
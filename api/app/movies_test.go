@@ -0,0 +1,36 @@
+package app
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+func TestMovieTMDBIDIsUnique(t *testing.T) {
+	api := newTestAPI(t, nil)
+	admin := api.registerAdmin("admin")
+	token := admin.tokens.Access_token
+
+	var first, second struct {
+		Movie_id uint64 `json:"movie_id"`
+	}
+	api.expect(http.StatusCreated, "POST", "/movies/", token, map[string]any{"title": "First", "tmdb_id": 900001}, &first)
+	api.expect(http.StatusCreated, "POST", "/movies/", token, map[string]any{"title": "Second", "tmdb_id": 900002}, &second)
+
+	// The clash is reported as such, not as a taken movie_id
+	var response struct {
+		Message string `json:"message"`
+	}
+	api.expect(http.StatusConflict, "POST", "/movies/", token, map[string]any{"title": "Copy", "tmdb_id": 900001}, &response)
+	if !strings.Contains(response.Message, "tmdb_id") {
+		t.Errorf("message = %q, want it to name the tmdb_id", response.Message)
+	}
+
+	path := "/movies/" + strconv.FormatUint(second.Movie_id, 10)
+	api.expect(http.StatusConflict, "PUT", path, token, map[string]any{"title": "Second", "tmdb_id": 900001}, nil)
+	api.expect(http.StatusConflict, "PATCH", path, token, map[string]any{"tmdb_id": 900001}, nil)
+
+	// A movie keeps its own tmdb_id when replaced
+	api.expect(http.StatusOK, "PUT", path, token, map[string]any{"title": "Second Edition", "tmdb_id": 900002}, nil)
+}
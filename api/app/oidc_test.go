@@ -0,0 +1,180 @@
+package app
+
+import (
+	"context"
+	"net/http"
+	"net/http/cookiejar"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+
+	"movie-api/api/config"
+	"movie-api/api/oidc/mockprovider"
+	models "movie-api/api/resource/user/model"
+)
+
+// newOIDCTestAPI starts an App that logs users in at a mock OpenID Connect provider.
+// Its client keeps cookies like the browser of the user logging in.
+func newOIDCTestAPI(t *testing.T) *testAPI {
+	t.Helper()
+
+	var provider *mockprovider.Provider
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		provider.ServeHTTP(w, r)
+	}))
+	t.Cleanup(server.Close)
+
+	provider, err := mockprovider.New(server.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	api := newTestAPI(t, func(settings *config.Config) {
+		settings.OIDC.Issuer = provider.Issuer
+		settings.OIDC.ClientID = "movie-api"
+		settings.OIDC.RedirectURL = "http://localhost/auth/oidc/callback"
+	})
+
+	jar, err := cookiejar.New(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	api.server.Client().Jar = jar
+
+	return api
+}
+
+// redirect requests rawURL and returns where it redirects to.
+func (api *testAPI) redirect(rawURL string) *url.URL {
+	api.t.Helper()
+
+	noRedirects := *api.server.Client()
+	noRedirects.CheckRedirect = func(*http.Request, []*http.Request) error {
+		return http.ErrUseLastResponse
+	}
+	response, err := noRedirects.Get(rawURL)
+	if err != nil {
+		api.t.Fatal(err)
+	}
+	response.Body.Close()
+
+	location, err := response.Location()
+	if err != nil {
+		api.t.Fatalf("GET %s = %d, want a redirect", rawURL, response.StatusCode)
+	}
+	return location
+}
+
+// oidcCallback starts a login, logs email in at the provider and returns the query of
+// the provider's redirect back to the callback.
+func (api *testAPI) oidcCallback(email string) string {
+	api.t.Helper()
+
+	authURL := api.redirect(api.server.URL + "/auth/oidc/login")
+	callback := api.redirect(authURL.String() + "&email=" + url.QueryEscape(email))
+
+	return callback.RawQuery
+}
+
+func TestOIDCLoginCreatesAccount(t *testing.T) {
+	api := newOIDCTestAPI(t)
+
+	var first models.AuthResponse
+	api.expect(http.StatusOK, "GET", "/auth/oidc/callback?"+api.oidcCallback("carol@example.com"), "", nil, &first)
+	if !first.User.Email_verified || first.Tokens.Access_token == "" {
+		t.Fatalf("login response = %+v, want a verified account and tokens", first)
+	}
+	api.expect(http.StatusOK, "GET", "/users/"+first.User.User_id, first.Tokens.Access_token, nil, nil)
+
+	// The identity logs in to the same account next time
+	var second models.AuthResponse
+	api.expect(http.StatusOK, "GET", "/auth/oidc/callback?"+api.oidcCallback("carol@example.com"), "", nil, &second)
+	if second.User.User_id != first.User.User_id {
+		t.Errorf("second login as user %s, want %s", second.User.User_id, first.User.User_id)
+	}
+}
+
+func TestOIDCLoginLinksOnlyVerifiedAccounts(t *testing.T) {
+	api := newOIDCTestAPI(t)
+	alice := api.register("alice")
+
+	// Until alice verifies the address, it may not be hers
+	api.expect(http.StatusConflict, "GET", "/auth/oidc/callback?"+api.oidcCallback(alice.email), "", nil, nil)
+
+	if _, err := api.app.Auth.Users.MarkEmailVerified(context.Background(), alice.id, alice.email, time.Now()); err != nil {
+		t.Fatal(err)
+	}
+
+	var response models.AuthResponse
+	api.expect(http.StatusOK, "GET", "/auth/oidc/callback?"+api.oidcCallback(alice.email), "", nil, &response)
+	if response.User.User_id != alice.id {
+		t.Errorf("logged in as user %s, want alice %s", response.User.User_id, alice.id)
+	}
+}
+
+func TestOIDCCallbackIsSingleUse(t *testing.T) {
+	api := newOIDCTestAPI(t)
+	callback := api.oidcCallback("carol@example.com")
+	cookies := api.oidcStateCookies()
+
+	api.expect(http.StatusOK, "GET", "/auth/oidc/callback?"+callback, "", nil, nil)
+	if len(api.oidcStateCookies()) != 0 {
+		t.Error("state cookie kept after the login")
+	}
+
+	// Even with the state cookie back in place
+	callbackURL, _ := url.Parse(api.server.URL + "/auth/oidc/callback")
+	api.server.Client().Jar.SetCookies(callbackURL, cookies)
+	api.expect(http.StatusBadRequest, "GET", "/auth/oidc/callback?"+callback, "", nil, nil)
+}
+
+func TestOIDCCallbackNeedsTheBrowserThatStartedTheLogin(t *testing.T) {
+	api := newOIDCTestAPI(t)
+	callback := api.oidcCallback("mallory@example.com")
+
+	// Someone else following mallory's callback link is not logged in to mallory's account
+	response, err := http.Get(api.server.URL + "/auth/oidc/callback?" + callback)
+	if err != nil {
+		t.Fatal(err)
+	}
+	response.Body.Close()
+	if response.StatusCode != http.StatusBadRequest {
+		t.Fatalf("callback in another browser = %d, want 400", response.StatusCode)
+	}
+
+	// Nor does a stranger's state do for the pending login of this browser
+	api.oidcCallback("carol@example.com")
+	api.expect(http.StatusBadRequest, "GET", "/auth/oidc/callback?"+callback, "", nil, nil)
+}
+
+// oidcStateCookies returns the state cookies the client would send to the callback.
+func (api *testAPI) oidcStateCookies() []*http.Cookie {
+	api.t.Helper()
+
+	callbackURL, err := url.Parse(api.server.URL + "/auth/oidc/callback")
+	if err != nil {
+		api.t.Fatal(err)
+	}
+
+	var cookies []*http.Cookie
+	for _, cookie := range api.server.Client().Jar.Cookies(callbackURL) {
+		if cookie.Name == "oidc_state" {
+			cookies = append(cookies, cookie)
+		}
+	}
+	return cookies
+}
+
+func TestOIDCCallbackRejectsUnknownState(t *testing.T) {
+	api := newOIDCTestAPI(t)
+
+	api.expect(http.StatusBadRequest, "GET", "/auth/oidc/callback?state=forged&code=forged", "", nil, nil)
+	api.expect(http.StatusUnauthorized, "GET", "/auth/oidc/callback?error=access_denied", "", nil, nil)
+}
+
+func TestOIDCNotConfigured(t *testing.T) {
+	api := newTestAPI(t, nil)
+
+	api.expect(http.StatusNotFound, "GET", "/auth/oidc/login", "", nil, nil)
+}
@@ -0,0 +1,164 @@
+package app
+
+import (
+	"net/http"
+	"testing"
+
+	"movie-api/api/config"
+	models "movie-api/api/resource/user/model"
+)
+
+// createAPIKey creates an API key of user limited to scopes and returns the key.
+func (api *testAPI) createAPIKey(user *testUser, scopes ...string) string {
+	api.t.Helper()
+
+	var created models.CreatedAPIKey
+	api.expect(http.StatusCreated, "POST", "/users/"+user.id+"/api-keys", user.tokens.Access_token, map[string]any{"name": "test", "scopes": scopes}, &created)
+	if created.Key == "" {
+		api.t.Fatalf("created key = %+v, want the key", created)
+	}
+
+	return created.Key
+}
+
+func TestRolePermissions(t *testing.T) {
+	api := newTestAPI(t, nil)
+	admin := api.registerAdmin("admin")
+	alice := api.register("alice")
+	bob := api.register("bob")
+
+	tests := []struct {
+		name   string
+		user   *testUser
+		method string
+		path   string
+		body   any
+		want   int
+	}{
+		{"user reads movies", alice, "GET", "/movies/", nil, http.StatusOK},
+		{"user reads own profile", alice, "GET", "/users/" + alice.id, nil, http.StatusOK},
+		{"user reads another profile", alice, "GET", "/users/" + bob.id, nil, http.StatusForbidden},
+		{"user lists users", alice, "GET", "/users/", nil, http.StatusForbidden},
+		{"user updates another profile", alice, "PATCH", "/users/" + bob.id, map[string]any{"first_name": "Robert"}, http.StatusForbidden},
+		{"user changes own role", alice, "PATCH", "/users/" + alice.id, map[string]any{"user_type": "ADMIN"}, http.StatusForbidden},
+		{"user creates a movie", alice, "POST", "/movies/", map[string]any{"title": "Alice's Movie"}, http.StatusForbidden},
+		{"user reads security settings", alice, "GET", "/admin/settings/security", nil, http.StatusForbidden},
+		{"admin reads another profile", admin, "GET", "/users/" + bob.id, nil, http.StatusOK},
+		{"admin lists users", admin, "GET", "/users/", nil, http.StatusOK},
+		{"admin updates another profile", admin, "PATCH", "/users/" + bob.id, map[string]any{"first_name": "Robert"}, http.StatusOK},
+		{"admin creates a movie", admin, "POST", "/movies/", map[string]any{"title": "Admin's Movie"}, http.StatusCreated},
+		{"admin reads security settings", admin, "GET", "/admin/settings/security", nil, http.StatusOK},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := api.call(tt.method, tt.path, tt.user.tokens.Access_token, tt.body, nil); got != tt.want {
+				t.Errorf("%s %s = %d, want %d", tt.method, tt.path, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCreatedMoviesGetDistinctIDs(t *testing.T) {
+	api := newTestAPI(t, nil)
+	admin := api.registerAdmin("admin")
+
+	seen := map[uint64]bool{}
+	for i := 0; i < 3; i++ {
+		var movie struct {
+			Movie_id uint64 `json:"movie_id"`
+		}
+		api.expect(http.StatusCreated, "POST", "/movies/", admin.tokens.Access_token, map[string]any{"title": "New Movie"}, &movie)
+		if movie.Movie_id == 0 || seen[movie.Movie_id] {
+			t.Fatalf("movie_id %d allocated twice or not at all", movie.Movie_id)
+		}
+		seen[movie.Movie_id] = true
+	}
+
+	// A requested movie_id that is taken is a conflict rather than a new ID
+	for movieID := range seen {
+		api.expect(http.StatusConflict, "POST", "/movies/", admin.tokens.Access_token, map[string]any{"movie_id": movieID, "title": "Clash"}, nil)
+		break
+	}
+}
+
+func TestUnverifiedUserPermissions(t *testing.T) {
+	api := newTestAPI(t, func(settings *config.Config) {
+		settings.Auth.UnverifiedPermissions = "none"
+	})
+	alice := api.register("alice")
+
+	api.expect(http.StatusForbidden, "GET", "/movies/", alice.tokens.Access_token, nil, nil)
+
+	// Their own resources stay reachable
+	api.expect(http.StatusOK, "GET", "/users/"+alice.id, alice.tokens.Access_token, nil, nil)
+}
+
+func TestAPIKeyScopes(t *testing.T) {
+	api := newTestAPI(t, nil)
+	admin := api.registerAdmin("admin")
+	alice := api.register("alice")
+
+	readMovies := api.createAPIKey(admin, "movies:read")
+	readUsers := api.createAPIKey(admin, "users:read")
+
+	tests := []struct {
+		name   string
+		key    string
+		method string
+		path   string
+		want   int
+	}{
+		{"scope allows", readMovies, "GET", "/movies/", http.StatusOK},
+		{"scope does not allow", readMovies, "POST", "/movies/", http.StatusForbidden},
+		{"listing users", readMovies, "GET", "/users/", http.StatusForbidden},
+		{"another profile without users:read", readMovies, "GET", "/users/" + alice.id, http.StatusForbidden},
+		{"another profile with users:read", readUsers, "GET", "/users/" + alice.id, http.StatusOK},
+		{"own profile, as keys do not act as their owner", readMovies, "GET", "/users/" + admin.id, http.StatusForbidden},
+		{"managing API keys", readUsers, "GET", "/users/" + admin.id + "/api-keys", http.StatusForbidden},
+		{"revoked or unknown key", "mk_unknown", "GET", "/movies/", http.StatusUnauthorized},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := api.call(tt.method, tt.path, tt.key, nil, nil); got != tt.want {
+				t.Errorf("%s %s = %d, want %d", tt.method, tt.path, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestAPIKeyScopesPickTheProfileView(t *testing.T) {
+	api := newTestAPI(t, nil)
+	admin := api.registerAdmin("admin")
+	bob := api.register("bob")
+
+	// A deleted account is only visible in the admin view
+	api.expect(http.StatusOK, "DELETE", "/users/"+bob.id, admin.tokens.Access_token, nil, nil)
+
+	var view models.AdminUserView
+	api.expect(http.StatusOK, "GET", "/users/"+bob.id, admin.tokens.Access_token, nil, &view)
+	if view.Deleted_at == nil {
+		t.Errorf("admin view = %+v, want deleted_at", view)
+	}
+
+	readUsers := api.createAPIKey(admin, "users:read")
+	api.expect(http.StatusOK, "GET", "/users/"+bob.id, readUsers, nil, nil)
+
+	readMovies := api.createAPIKey(admin, "movies:read")
+	api.expect(http.StatusForbidden, "GET", "/users/"+bob.id, readMovies, nil, nil)
+
+	// Without users:manage the key cannot change roles either, not even its owner's
+	api.expect(http.StatusForbidden, "PATCH", "/users/"+admin.id, readMovies, map[string]any{"user_type": "USER"}, nil)
+}
+
+func TestAPIKeyScopesMustBeHeld(t *testing.T) {
+	api := newTestAPI(t, nil)
+	alice := api.register("alice")
+
+	api.expect(http.StatusBadRequest, "POST", "/users/"+alice.id+"/api-keys", alice.tokens.Access_token, map[string]any{"name": "test", "scopes": []string{"users:read"}}, nil)
+	api.expect(http.StatusBadRequest, "POST", "/users/"+alice.id+"/api-keys", alice.tokens.Access_token, map[string]any{"name": "test", "scopes": []string{"everything"}}, nil)
+
+	key := api.createAPIKey(alice, "movies:read")
+	api.expect(http.StatusOK, "GET", "/movies/", key, nil, nil)
+}
@@ -0,0 +1,262 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"time"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+// Config holds every setting of the API. Load fills it from, in increasing priority, the
+// defaults, an optional YAML or TOML file and the environment (including a .env file).
+//
+// Each setting has two names: the `key` tags joined with dots name it in the file
+// (e.g. server.port), and the `env` tag names its environment variable (e.g. PORT).
+// Settings tagged `secret` are redacted when the config is printed.
+type Config struct {
+	Server    Server    `key:"server"`
+	Database  Database  `key:"database"`
+	Auth      Auth      `key:"auth"`
+	Mail      Mail      `key:"mail"`
+	OIDC      OIDC      `key:"oidc"`
+	CORS      CORS      `key:"cors"`
+	RateLimit RateLimit `key:"rate_limit"`
+}
+
+type Server struct {
+	Port int `key:"port" env:"PORT"`
+
+	// BaseURL is the public address links in emails point to.
+	BaseURL string `key:"base_url" env:"APP_BASE_URL"`
+
+	// TrustedProxies are the only proxies X-Forwarded-For is honoured from, since client
+	// IPs feed the login throttling and the rate limit.
+	TrustedProxies []string `key:"trusted_proxies" env:"TRUSTED_PROXIES"`
+
+	// ReadTimeout, WriteTimeout and IdleTimeout bound reading a request, writing its
+	// response and keeping an idle connection open. 0 means no limit.
+	ReadTimeout  time.Duration `key:"read_timeout" env:"SERVER_READ_TIMEOUT"`
+	WriteTimeout time.Duration `key:"write_timeout" env:"SERVER_WRITE_TIMEOUT"`
+	IdleTimeout  time.Duration `key:"idle_timeout" env:"SERVER_IDLE_TIMEOUT"`
+
+	// ShutdownTimeout is how long a stopping server waits for in-flight requests, background
+	// jobs and the database connection before giving up on them.
+	ShutdownTimeout time.Duration `key:"shutdown_timeout" env:"SHUTDOWN_TIMEOUT"`
+
+	// ShutdownDelay is how long a stopping server keeps serving, while /readyz already
+	// fails, so that load balancers take it out of rotation before it stops accepting
+	// connections. It is part of ShutdownTimeout, so it must be shorter.
+	ShutdownDelay time.Duration `key:"shutdown_delay" env:"SHUTDOWN_DELAY"`
+
+	// HealthCheckTimeout bounds each dependency check of /readyz.
+	HealthCheckTimeout time.Duration `key:"health_check_timeout" env:"HEALTH_CHECK_TIMEOUT"`
+}
+
+type Database struct {
+	// Backend is "mongo", or "memory" to keep all data in process memory so the API
+	// runs without MongoDB.
+	Backend string `key:"backend" env:"STORAGE_BACKEND"`
+
+	URI  string `key:"uri" env:"MONGODB_URI" secret:"password"`
+	Name string `key:"name" env:"MONGODB_DATABASE"`
+}
+
+type Auth struct {
+	// JWTKeyDir holds the private keys that sign and verify tokens, one <kid>.pem file per key.
+	JWTKeyDir string `key:"jwt_key_dir" env:"JWT_KEY_DIR"`
+
+	AccessTokenLifetime            time.Duration `key:"access_token_lifetime" env:"ACCESS_TOKEN_LIFETIME"`
+	RefreshTokenLifetime           time.Duration `key:"refresh_token_lifetime" env:"REFRESH_TOKEN_LIFETIME"`
+	EmailVerificationTokenLifetime time.Duration `key:"email_verification_token_lifetime" env:"EMAIL_VERIFICATION_TOKEN_LIFETIME"`
+	PasswordResetTokenLifetime     time.Duration `key:"password_reset_token_lifetime" env:"PASSWORD_RESET_TOKEN_LIFETIME"`
+
+	// BcryptCost is the work factor of password and recovery code hashes. Raising it
+	// only affects hashes created afterwards.
+	BcryptCost int `key:"bcrypt_cost" env:"BCRYPT_COST"`
+
+	// UnverifiedPermissions are the permissions a user keeps until their email address is
+	// verified, comma separated. Leave it empty for the default, or set "none" to require
+	// verification for all of them.
+	UnverifiedPermissions string `key:"unverified_permissions" env:"UNVERIFIED_USER_PERMISSIONS"`
+
+	// MFAIssuer names the service in authenticator apps.
+	MFAIssuer string `key:"mfa_issuer" env:"MFA_ISSUER"`
+
+	// LoginGuardStore is where failed logins are counted: "mongo" uses the storage backend,
+	// so that all replicas share the counters, and "memory" keeps them in the instance.
+	LoginGuardStore string `key:"login_guard_store" env:"LOGIN_GUARD_STORE"`
+
+	// DeletionGracePeriod is how long a deleted account can still be restored before it is purged.
+	DeletionGracePeriod time.Duration `key:"account_deletion_grace_period" env:"ACCOUNT_DELETION_GRACE_PERIOD"`
+}
+
+type Mail struct {
+	// Sender is "log" (print messages to the server log), "file" (write .eml files into
+	// Dir) or "smtp".
+	Sender string `key:"sender" env:"MAIL_SENDER"`
+	From   string `key:"from" env:"MAIL_FROM"`
+	Dir    string `key:"dir" env:"MAIL_DIR"`
+
+	SMTPHost     string `key:"smtp_host" env:"SMTP_HOST"`
+	SMTPPort     int    `key:"smtp_port" env:"SMTP_PORT"`
+	SMTPUsername string `key:"smtp_username" env:"SMTP_USERNAME"`
+	SMTPPassword string `key:"smtp_password" env:"SMTP_PASSWORD" secret:"true"`
+}
+
+// OIDC configures login with an OpenID Connect provider. It is disabled while Issuer is empty.
+type OIDC struct {
+	Issuer   string `key:"issuer" env:"OIDC_ISSUER"`
+	ClientID string `key:"client_id" env:"OIDC_CLIENT_ID"`
+
+	// ClientSecret is optional for public clients, which rely on PKCE alone.
+	ClientSecret string   `key:"client_secret" env:"OIDC_CLIENT_SECRET" secret:"true"`
+	RedirectURL  string   `key:"redirect_url" env:"OIDC_REDIRECT_URL"`
+	Scopes       []string `key:"scopes" env:"OIDC_SCOPES" sep:" "`
+}
+
+// CORS lets browser apps on other origins call the API. It is disabled while AllowedOrigins
+// is empty; "*" allows every origin.
+type CORS struct {
+	AllowedOrigins   []string      `key:"allowed_origins" env:"CORS_ALLOWED_ORIGINS"`
+	AllowedMethods   []string      `key:"allowed_methods" env:"CORS_ALLOWED_METHODS"`
+	AllowedHeaders   []string      `key:"allowed_headers" env:"CORS_ALLOWED_HEADERS"`
+	AllowCredentials bool          `key:"allow_credentials" env:"CORS_ALLOW_CREDENTIALS"`
+	MaxAge           time.Duration `key:"max_age" env:"CORS_MAX_AGE"`
+}
+
+// RateLimit caps the requests per client IP address. It is disabled while
+// RequestsPerMinute is 0.
+type RateLimit struct {
+	RequestsPerMinute int `key:"requests_per_minute" env:"RATE_LIMIT_REQUESTS_PER_MINUTE"`
+
+	// Burst is how many requests a client may send at once before being slowed down to
+	// RequestsPerMinute.
+	Burst int `key:"burst" env:"RATE_LIMIT_BURST"`
+}
+
+// Default returns the settings used where neither the file nor the environment sets a value.
+func Default() Config {
+	return Config{
+		Server: Server{
+			Port:    8080,
+			BaseURL: "http://localhost:8080",
+
+			ReadTimeout:     15 * time.Second,
+			WriteTimeout:    30 * time.Second,
+			IdleTimeout:     time.Minute,
+			ShutdownTimeout: 30 * time.Second,
+
+			HealthCheckTimeout: 2 * time.Second,
+		},
+		Database: Database{
+			Backend: "mongo",
+			Name:    "cluster1",
+		},
+		Auth: Auth{
+			JWTKeyDir:                      "keys",
+			AccessTokenLifetime:            24 * time.Hour,
+			RefreshTokenLifetime:           168 * time.Hour,
+			EmailVerificationTokenLifetime: 24 * time.Hour,
+			PasswordResetTokenLifetime:     time.Hour,
+			BcryptCost:                     10,
+			MFAIssuer:                      "movie-api",
+			LoginGuardStore:                "mongo",
+			DeletionGracePeriod:            30 * 24 * time.Hour,
+		},
+		Mail: Mail{
+			Sender:   "log",
+			From:     "no-reply@movie-api.local",
+			Dir:      "mail",
+			SMTPPort: 587,
+		},
+		OIDC: OIDC{
+			Scopes: []string{"openid", "email", "profile"},
+		},
+		CORS: CORS{
+			AllowedMethods: []string{"GET", "POST", "PATCH", "DELETE"},
+			AllowedHeaders: []string{"Authorization", "Content-Type"},
+			MaxAge:         12 * time.Hour,
+		},
+		RateLimit: RateLimit{
+			Burst: 20,
+		},
+	}
+}
+
+// Validate checks the settings against each other and returns all problems at once.
+func (c Config) Validate() error {
+	var errs []error
+	check := func(ok bool, format string, args ...any) {
+		if !ok {
+			errs = append(errs, fmt.Errorf(format, args...))
+		}
+	}
+
+	check(c.Server.Port > 0 && c.Server.Port <= 65535, "server.port (PORT) must be between 1 and 65535, got %d", c.Server.Port)
+	check(isAbsoluteURL(c.Server.BaseURL), "server.base_url (APP_BASE_URL) must be an absolute URL, got %q", c.Server.BaseURL)
+	check(c.Server.ReadTimeout >= 0, "server.read_timeout (SERVER_READ_TIMEOUT) must not be negative")
+	check(c.Server.WriteTimeout >= 0, "server.write_timeout (SERVER_WRITE_TIMEOUT) must not be negative")
+	check(c.Server.IdleTimeout >= 0, "server.idle_timeout (SERVER_IDLE_TIMEOUT) must not be negative")
+	check(c.Server.ShutdownTimeout > 0, "server.shutdown_timeout (SHUTDOWN_TIMEOUT) must be positive")
+	check(c.Server.ShutdownDelay >= 0, "server.shutdown_delay (SHUTDOWN_DELAY) must not be negative")
+	check(c.Server.ShutdownDelay < c.Server.ShutdownTimeout,
+		"server.shutdown_delay (SHUTDOWN_DELAY) must be shorter than server.shutdown_timeout (SHUTDOWN_TIMEOUT), which includes it")
+	check(c.Server.HealthCheckTimeout > 0, "server.health_check_timeout (HEALTH_CHECK_TIMEOUT) must be positive")
+
+	switch c.Database.Backend {
+	case "mongo":
+		check(c.Database.URI != "", "database.uri (MONGODB_URI) must be set when database.backend is mongo")
+		check(c.Database.Name != "", "database.name (MONGODB_DATABASE) must not be empty")
+	case "memory":
+	default:
+		check(false, "database.backend (STORAGE_BACKEND) must be mongo or memory, got %q", c.Database.Backend)
+	}
+
+	check(c.Auth.JWTKeyDir != "", "auth.jwt_key_dir (JWT_KEY_DIR) must not be empty")
+	check(c.Auth.AccessTokenLifetime > 0, "auth.access_token_lifetime (ACCESS_TOKEN_LIFETIME) must be positive")
+	check(c.Auth.RefreshTokenLifetime >= c.Auth.AccessTokenLifetime, "auth.refresh_token_lifetime (REFRESH_TOKEN_LIFETIME) must not be shorter than the access token lifetime")
+	check(c.Auth.EmailVerificationTokenLifetime > 0, "auth.email_verification_token_lifetime (EMAIL_VERIFICATION_TOKEN_LIFETIME) must be positive")
+	check(c.Auth.PasswordResetTokenLifetime > 0, "auth.password_reset_token_lifetime (PASSWORD_RESET_TOKEN_LIFETIME) must be positive")
+	check(c.Auth.BcryptCost >= bcrypt.MinCost && c.Auth.BcryptCost <= bcrypt.MaxCost,
+		"auth.bcrypt_cost (BCRYPT_COST) must be between %d and %d, got %d", bcrypt.MinCost, bcrypt.MaxCost, c.Auth.BcryptCost)
+	check(c.Auth.MFAIssuer != "", "auth.mfa_issuer (MFA_ISSUER) must not be empty")
+	check(c.Auth.LoginGuardStore == "mongo" || c.Auth.LoginGuardStore == "memory",
+		"auth.login_guard_store (LOGIN_GUARD_STORE) must be mongo or memory, got %q", c.Auth.LoginGuardStore)
+	check(c.Auth.DeletionGracePeriod >= 0, "auth.account_deletion_grace_period (ACCOUNT_DELETION_GRACE_PERIOD) must not be negative")
+
+	switch c.Mail.Sender {
+	case "log":
+	case "file":
+		check(c.Mail.Dir != "", "mail.dir (MAIL_DIR) must be set when mail.sender is file")
+	case "smtp":
+		check(c.Mail.SMTPHost != "", "mail.smtp_host (SMTP_HOST) must be set when mail.sender is smtp")
+		check(c.Mail.SMTPPort > 0 && c.Mail.SMTPPort <= 65535, "mail.smtp_port (SMTP_PORT) must be between 1 and 65535, got %d", c.Mail.SMTPPort)
+	default:
+		check(false, "mail.sender (MAIL_SENDER) must be log, file or smtp, got %q", c.Mail.Sender)
+	}
+	check(c.Mail.From != "", "mail.from (MAIL_FROM) must not be empty")
+
+	if c.OIDC.Issuer != "" {
+		check(isAbsoluteURL(c.OIDC.Issuer), "oidc.issuer (OIDC_ISSUER) must be an absolute URL, got %q", c.OIDC.Issuer)
+		check(c.OIDC.ClientID != "", "oidc.client_id (OIDC_CLIENT_ID) must be set when oidc.issuer is set")
+		check(isAbsoluteURL(c.OIDC.RedirectURL), "oidc.redirect_url (OIDC_REDIRECT_URL) must be an absolute URL when oidc.issuer is set, got %q", c.OIDC.RedirectURL)
+	}
+
+	for _, origin := range c.CORS.AllowedOrigins {
+		check(origin == "*" || isAbsoluteURL(origin), "cors.allowed_origins (CORS_ALLOWED_ORIGINS) must hold \"*\" or absolute URLs, got %q", origin)
+		check(origin != "*" || !c.CORS.AllowCredentials, "cors.allow_credentials (CORS_ALLOW_CREDENTIALS) cannot be combined with the \"*\" origin")
+	}
+	check(c.CORS.MaxAge >= 0, "cors.max_age (CORS_MAX_AGE) must not be negative")
+
+	check(c.RateLimit.RequestsPerMinute >= 0, "rate_limit.requests_per_minute (RATE_LIMIT_REQUESTS_PER_MINUTE) must not be negative")
+	check(c.RateLimit.RequestsPerMinute == 0 || c.RateLimit.Burst > 0, "rate_limit.burst (RATE_LIMIT_BURST) must be positive when the rate limit is enabled")
+
+	return errors.Join(errs...)
+}
+
+func isAbsoluteURL(value string) bool {
+	parsed, err := url.Parse(value)
+	return err == nil && parsed.Scheme != "" && parsed.Host != ""
+}
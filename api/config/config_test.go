@@ -0,0 +1,135 @@
+package config
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+// valid returns the defaults with the in-memory backend, which need nothing else to pass Validate.
+func valid() Config {
+	config := Default()
+	config.Database.Backend = "memory"
+	return config
+}
+
+func TestValidateAcceptsDefaults(t *testing.T) {
+	if err := valid().Validate(); err != nil {
+		t.Fatalf("Validate() = %v, want nil", err)
+	}
+
+	config := Default()
+	config.Database.URI = "mongodb://localhost:27017"
+	if err := config.Validate(); err != nil {
+		t.Fatalf("Validate() with a MongoDB URI = %v, want nil", err)
+	}
+}
+
+func TestValidateRejects(t *testing.T) {
+	tests := []struct {
+		name   string
+		change func(c *Config)
+		want   string // part of the error
+	}{
+		{"port zero", func(c *Config) { c.Server.Port = 0 }, "PORT"},
+		{"port too high", func(c *Config) { c.Server.Port = 65536 }, "PORT"},
+		{"relative base URL", func(c *Config) { c.Server.BaseURL = "/movies" }, "APP_BASE_URL"},
+		{"negative read timeout", func(c *Config) { c.Server.ReadTimeout = -time.Second }, "SERVER_READ_TIMEOUT"},
+		{"negative write timeout", func(c *Config) { c.Server.WriteTimeout = -time.Second }, "SERVER_WRITE_TIMEOUT"},
+		{"negative idle timeout", func(c *Config) { c.Server.IdleTimeout = -time.Second }, "SERVER_IDLE_TIMEOUT"},
+		{"no shutdown timeout", func(c *Config) { c.Server.ShutdownTimeout = 0 }, "SHUTDOWN_TIMEOUT"},
+		{"negative shutdown delay", func(c *Config) { c.Server.ShutdownDelay = -time.Second }, "SHUTDOWN_DELAY"},
+		{"shutdown delay filling the timeout", func(c *Config) { c.Server.ShutdownDelay = c.Server.ShutdownTimeout }, "SHUTDOWN_DELAY"},
+		{"no health check timeout", func(c *Config) { c.Server.HealthCheckTimeout = 0 }, "HEALTH_CHECK_TIMEOUT"},
+
+		{"mongo without URI", func(c *Config) { c.Database.Backend = "mongo" }, "MONGODB_URI"},
+		{"mongo without database name", func(c *Config) {
+			c.Database.Backend, c.Database.URI, c.Database.Name = "mongo", "mongodb://localhost:27017", ""
+		}, "MONGODB_DATABASE"},
+		{"unknown backend", func(c *Config) { c.Database.Backend = "postgres" }, "STORAGE_BACKEND"},
+
+		{"no key directory", func(c *Config) { c.Auth.JWTKeyDir = "" }, "JWT_KEY_DIR"},
+		{"no access token lifetime", func(c *Config) { c.Auth.AccessTokenLifetime = 0 }, "ACCESS_TOKEN_LIFETIME"},
+		{"refresh tokens shorter than access tokens", func(c *Config) { c.Auth.RefreshTokenLifetime = time.Hour }, "REFRESH_TOKEN_LIFETIME"},
+		{"no email verification token lifetime", func(c *Config) { c.Auth.EmailVerificationTokenLifetime = 0 }, "EMAIL_VERIFICATION_TOKEN_LIFETIME"},
+		{"no password reset token lifetime", func(c *Config) { c.Auth.PasswordResetTokenLifetime = 0 }, "PASSWORD_RESET_TOKEN_LIFETIME"},
+		{"bcrypt cost too low", func(c *Config) { c.Auth.BcryptCost = 3 }, "BCRYPT_COST"},
+		{"bcrypt cost too high", func(c *Config) { c.Auth.BcryptCost = 32 }, "BCRYPT_COST"},
+		{"no MFA issuer", func(c *Config) { c.Auth.MFAIssuer = "" }, "MFA_ISSUER"},
+		{"unknown login guard store", func(c *Config) { c.Auth.LoginGuardStore = "redis" }, "LOGIN_GUARD_STORE"},
+		{"negative deletion grace period", func(c *Config) { c.Auth.DeletionGracePeriod = -time.Hour }, "ACCOUNT_DELETION_GRACE_PERIOD"},
+
+		{"file sender without directory", func(c *Config) { c.Mail.Sender, c.Mail.Dir = "file", "" }, "MAIL_DIR"},
+		{"SMTP sender without host", func(c *Config) { c.Mail.Sender = "smtp" }, "SMTP_HOST"},
+		{"SMTP port out of range", func(c *Config) {
+			c.Mail.Sender, c.Mail.SMTPHost, c.Mail.SMTPPort = "smtp", "smtp.example.com", 0
+		}, "SMTP_PORT"},
+		{"unknown sender", func(c *Config) { c.Mail.Sender = "pigeon" }, "MAIL_SENDER"},
+		{"no sender address", func(c *Config) { c.Mail.From = "" }, "MAIL_FROM"},
+
+		{"relative issuer", func(c *Config) {
+			c.OIDC = OIDC{Issuer: "accounts", ClientID: "movie-api", RedirectURL: "https://movies.example.com/auth/oidc/callback"}
+		}, "OIDC_ISSUER"},
+		{"issuer without client ID", func(c *Config) {
+			c.OIDC = OIDC{Issuer: "https://accounts.example.com", RedirectURL: "https://movies.example.com/auth/oidc/callback"}
+		}, "OIDC_CLIENT_ID"},
+		{"issuer without redirect URL", func(c *Config) {
+			c.OIDC = OIDC{Issuer: "https://accounts.example.com", ClientID: "movie-api"}
+		}, "OIDC_REDIRECT_URL"},
+
+		{"relative origin", func(c *Config) { c.CORS.AllowedOrigins = []string{"movies.example.com"} }, "CORS_ALLOWED_ORIGINS"},
+		{"any origin with credentials", func(c *Config) {
+			c.CORS.AllowedOrigins, c.CORS.AllowCredentials = []string{"*"}, true
+		}, "CORS_ALLOW_CREDENTIALS"},
+		{"negative CORS max age", func(c *Config) { c.CORS.MaxAge = -time.Second }, "CORS_MAX_AGE"},
+
+		{"negative rate limit", func(c *Config) { c.RateLimit.RequestsPerMinute = -1 }, "RATE_LIMIT_REQUESTS_PER_MINUTE"},
+		{"rate limit without burst", func(c *Config) {
+			c.RateLimit.RequestsPerMinute, c.RateLimit.Burst = 60, 0
+		}, "RATE_LIMIT_BURST"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			config := valid()
+			tt.change(&config)
+
+			err := config.Validate()
+			if err == nil {
+				t.Fatalf("Validate() = nil, want an error about %s", tt.want)
+			}
+			if !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("Validate() = %q, want it to name %s", err, tt.want)
+			}
+		})
+	}
+}
+
+func TestValidateReportsEveryProblem(t *testing.T) {
+	config := valid()
+	config.Server.Port = 0
+	config.Auth.JWTKeyDir = ""
+	config.Mail.Sender = "pigeon"
+
+	err := config.Validate()
+	if err == nil {
+		t.Fatal("Validate() = nil, want errors")
+	}
+	for _, want := range []string{"PORT", "JWT_KEY_DIR", "MAIL_SENDER"} {
+		if !strings.Contains(err.Error(), want) {
+			t.Errorf("Validate() = %q, want it to name %s", err, want)
+		}
+	}
+}
+
+func TestValidateAcceptsOptionalSettings(t *testing.T) {
+	config := valid()
+	config.OIDC = OIDC{Issuer: "https://accounts.example.com", ClientID: "movie-api", RedirectURL: "https://movies.example.com/auth/oidc/callback"}
+	config.CORS.AllowedOrigins = []string{"*"}
+	config.RateLimit.RequestsPerMinute = 60
+	config.Mail = Mail{Sender: "smtp", From: "movies@example.com", SMTPHost: "smtp.example.com", SMTPPort: 587}
+
+	if err := config.Validate(); err != nil {
+		t.Fatalf("Validate() = %v, want nil", err)
+	}
+}
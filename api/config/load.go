@@ -0,0 +1,232 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/joho/godotenv"
+	"github.com/pelletier/go-toml/v2"
+	"gopkg.in/yaml.v3"
+)
+
+// Load reads the configuration and validates it. A .env file in the working directory is
+// loaded into the environment first, without overriding variables that are already set.
+//
+// path names a YAML (.yaml, .yml) or TOML (.toml) file to read. When it is empty the
+// CONFIG_FILE variable is used, and without either only the defaults and the environment apply.
+func Load(path string) (Config, error) {
+	if err := godotenv.Load(); err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return Config{}, fmt.Errorf("Error loading .env file: %w", err)
+	}
+
+	if path == "" {
+		path = os.Getenv("CONFIG_FILE")
+	}
+
+	config := Default()
+	if path != "" {
+		if err := config.loadFile(path); err != nil {
+			return config, err
+		}
+	}
+
+	if err := config.loadEnv(); err != nil {
+		return config, err
+	}
+
+	if err := config.Validate(); err != nil {
+		return config, fmt.Errorf("Invalid configuration:\n%w", err)
+	}
+
+	return config, nil
+}
+
+// setting is one leaf field of a Config together with its tags.
+type setting struct {
+	key    string
+	env    string
+	secret string
+	sep    string
+	value  reflect.Value
+}
+
+// settings lists the leaf fields of c in declaration order. Their values point into c.
+func (c *Config) settings() []setting {
+	var settings []setting
+
+	var walk func(value reflect.Value, prefix string)
+	walk = func(value reflect.Value, prefix string) {
+		for i := 0; i < value.NumField(); i++ {
+			field := value.Type().Field(i)
+			key := prefix + field.Tag.Get("key")
+
+			if field.Type.Kind() == reflect.Struct {
+				walk(value.Field(i), key+".")
+				continue
+			}
+
+			sep := field.Tag.Get("sep")
+			if sep == "" {
+				sep = ","
+			}
+
+			settings = append(settings, setting{
+				key:    key,
+				env:    field.Tag.Get("env"),
+				secret: field.Tag.Get("secret"),
+				sep:    sep,
+				value:  value.Field(i),
+			})
+		}
+	}
+	walk(reflect.ValueOf(c).Elem(), "")
+
+	return settings
+}
+
+// set parses text into the setting. Lists are split at the setting's separator.
+func (s setting) set(text string) error {
+	text = strings.TrimSpace(text)
+
+	switch s.value.Interface().(type) {
+	case string:
+		s.value.SetString(text)
+	case int:
+		number, err := strconv.Atoi(text)
+		if err != nil {
+			return fmt.Errorf("%q is not a whole number", text)
+		}
+		s.value.SetInt(int64(number))
+	case bool:
+		flag, err := strconv.ParseBool(text)
+		if err != nil {
+			return fmt.Errorf("%q is not true or false", text)
+		}
+		s.value.SetBool(flag)
+	case time.Duration:
+		duration, err := time.ParseDuration(text)
+		if err != nil {
+			return fmt.Errorf("%q is not a duration such as \"90m\" or \"24h\"", text)
+		}
+		s.value.SetInt(int64(duration))
+	case []string:
+		var items []string
+		if s.sep == " " {
+			items = strings.Fields(text)
+		} else {
+			for _, item := range strings.Split(text, s.sep) {
+				if item = strings.TrimSpace(item); item != "" {
+					items = append(items, item)
+				}
+			}
+		}
+		s.value.Set(reflect.ValueOf(items))
+	default:
+		return fmt.Errorf("unsupported type %s", s.value.Type())
+	}
+
+	return nil
+}
+
+// loadEnv applies the environment variables that are set and not empty.
+func (c *Config) loadEnv() error {
+	var errs []error
+	for _, setting := range c.settings() {
+		value := os.Getenv(setting.env)
+		if value == "" {
+			continue
+		}
+		if err := setting.set(value); err != nil {
+			errs = append(errs, fmt.Errorf("Invalid %s: %w", setting.env, err))
+		}
+	}
+
+	return errors.Join(errs...)
+}
+
+// loadFile applies the settings of a YAML or TOML file, chosen by its extension.
+// Unknown keys are reported, as they are most likely misspelt settings.
+func (c *Config) loadFile(path string) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("Error reading config file: %w", err)
+	}
+
+	document := map[string]any{}
+	switch extension := strings.ToLower(filepath.Ext(path)); extension {
+	case ".yaml", ".yml":
+		err = yaml.Unmarshal(data, &document)
+	case ".toml":
+		err = toml.Unmarshal(data, &document)
+	default:
+		return fmt.Errorf("Unsupported config file %s, expected a .yaml, .yml or .toml file", path)
+	}
+	if err != nil {
+		return fmt.Errorf("Error parsing config file %s: %w", path, err)
+	}
+
+	values := map[string]any{}
+	flatten(document, "", values)
+
+	var errs []error
+	for _, setting := range c.settings() {
+		value, ok := values[setting.key]
+		if !ok {
+			continue
+		}
+		delete(values, setting.key)
+
+		if err := setting.setFileValue(value); err != nil {
+			errs = append(errs, fmt.Errorf("Invalid %s in %s: %w", setting.key, path, err))
+		}
+	}
+
+	unknown := make([]string, 0, len(values))
+	for key := range values {
+		unknown = append(unknown, key)
+	}
+	sort.Strings(unknown)
+	for _, key := range unknown {
+		errs = append(errs, fmt.Errorf("Unknown setting %s in %s", key, path))
+	}
+
+	return errors.Join(errs...)
+}
+
+// setFileValue applies a value decoded from a config file. Lists may be given either as
+// a list or as one string with the separator of the environment variable.
+func (s setting) setFileValue(value any) error {
+	if list, ok := value.([]any); ok {
+		if s.value.Type() != reflect.TypeOf([]string(nil)) {
+			return fmt.Errorf("expected a single value, got a list")
+		}
+
+		items := make([]string, len(list))
+		for i, item := range list {
+			items[i] = fmt.Sprint(item)
+		}
+		s.value.Set(reflect.ValueOf(items))
+		return nil
+	}
+
+	return s.set(fmt.Sprint(value))
+}
+
+// flatten collects the values of a decoded document under their dotted keys.
+func flatten(document map[string]any, prefix string, values map[string]any) {
+	for key, value := range document {
+		if section, ok := value.(map[string]any); ok {
+			flatten(section, prefix+key+".", values)
+			continue
+		}
+		values[prefix+key] = value
+	}
+}
@@ -0,0 +1,252 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+// clearEnv hides the settings' variables of the surrounding environment for the test.
+// Load treats empty variables as unset.
+func clearEnv(t *testing.T) {
+	t.Helper()
+
+	var config Config
+	for _, setting := range config.settings() {
+		t.Setenv(setting.env, "")
+	}
+	t.Setenv("CONFIG_FILE", "")
+}
+
+// writeFile writes content to a file named name in a temporary directory and returns its path.
+func writeFile(t *testing.T, name, content string) string {
+	t.Helper()
+
+	path := filepath.Join(t.TempDir(), name)
+	if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestLoadPrecedence(t *testing.T) {
+	clearEnv(t)
+	path := writeFile(t, "config.yaml", `
+server:
+  port: 9000
+  shutdown_timeout: 10s
+database:
+  backend: memory
+mail:
+  from: movies@example.com
+`)
+	t.Setenv("PORT", "9100")
+
+	config, err := Load(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name      string
+		got, want any
+	}{
+		{"environment over file", config.Server.Port, 9100},
+		{"file over default", config.Server.ShutdownTimeout, 10 * time.Second},
+		{"file over default", config.Mail.From, "movies@example.com"},
+		{"default", config.Database.Name, "cluster1"},
+		{"default", config.Auth.BcryptCost, 10},
+	}
+	for _, tt := range tests {
+		if tt.got != tt.want {
+			t.Errorf("%s: got %v, want %v", tt.name, tt.got, tt.want)
+		}
+	}
+}
+
+func TestLoadUsesConfigFileVariable(t *testing.T) {
+	clearEnv(t)
+	t.Setenv("CONFIG_FILE", writeFile(t, "config.toml", "[database]\nbackend = \"memory\"\n\n[server]\nport = 9200\n"))
+
+	config, err := Load("")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if config.Server.Port != 9200 {
+		t.Errorf("port = %d, want 9200 from CONFIG_FILE", config.Server.Port)
+	}
+}
+
+func TestLoadWithoutFile(t *testing.T) {
+	clearEnv(t)
+	t.Setenv("STORAGE_BACKEND", "memory")
+
+	config, err := Load("")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := Default()
+	want.Database.Backend = "memory"
+	if !reflect.DeepEqual(config, want) {
+		t.Errorf("Load() = %+v, want the defaults %+v", config, want)
+	}
+}
+
+func TestLoadFileFormats(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+	}{
+		{"config.yaml", `
+server:
+  port: 9000
+  trusted_proxies: [10.0.0.1, 10.0.0.2]
+  shutdown_delay: 5s
+database:
+  backend: memory
+cors:
+  allowed_origins: https://a.example.com, https://b.example.com
+  allow_credentials: true
+oidc:
+  scopes: openid email
+`},
+		{"config.yml", `
+server: {port: 9000, trusted_proxies: ["10.0.0.1", "10.0.0.2"], shutdown_delay: "5s"}
+database: {backend: memory}
+cors: {allowed_origins: ["https://a.example.com", "https://b.example.com"], allow_credentials: true}
+oidc: {scopes: [openid, email]}
+`},
+		{"config.toml", `
+[server]
+port = 9000
+trusted_proxies = ["10.0.0.1", "10.0.0.2"]
+shutdown_delay = "5s"
+
+[database]
+backend = "memory"
+
+[cors]
+allowed_origins = "https://a.example.com,https://b.example.com"
+allow_credentials = true
+
+[oidc]
+scopes = "openid email"
+`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			clearEnv(t)
+
+			config, err := Load(writeFile(t, tt.name, tt.content))
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if config.Server.Port != 9000 {
+				t.Errorf("port = %d, want 9000", config.Server.Port)
+			}
+			if want := []string{"10.0.0.1", "10.0.0.2"}; !reflect.DeepEqual(config.Server.TrustedProxies, want) {
+				t.Errorf("trusted proxies = %q, want %q", config.Server.TrustedProxies, want)
+			}
+			if config.Server.ShutdownDelay != 5*time.Second {
+				t.Errorf("shutdown delay = %s, want 5s", config.Server.ShutdownDelay)
+			}
+			if want := []string{"https://a.example.com", "https://b.example.com"}; !reflect.DeepEqual(config.CORS.AllowedOrigins, want) {
+				t.Errorf("allowed origins = %q, want %q", config.CORS.AllowedOrigins, want)
+			}
+			if !config.CORS.AllowCredentials {
+				t.Error("allow credentials = false, want true")
+			}
+			if want := []string{"openid", "email"}; !reflect.DeepEqual(config.OIDC.Scopes, want) {
+				t.Errorf("scopes = %q, want %q", config.OIDC.Scopes, want)
+			}
+		})
+	}
+}
+
+func TestLoadListSeparators(t *testing.T) {
+	tests := []struct {
+		env   string
+		value string
+		get   func(c Config) []string
+		want  []string
+	}{
+		{"CORS_ALLOWED_ORIGINS", "https://a.example.com, https://b.example.com", func(c Config) []string { return c.CORS.AllowedOrigins },
+			[]string{"https://a.example.com", "https://b.example.com"}},
+		{"CORS_ALLOWED_METHODS", ",GET,,POST,", func(c Config) []string { return c.CORS.AllowedMethods }, []string{"GET", "POST"}},
+		{"TRUSTED_PROXIES", "10.0.0.1", func(c Config) []string { return c.Server.TrustedProxies }, []string{"10.0.0.1"}},
+		{"OIDC_SCOPES", " openid  email\tprofile ", func(c Config) []string { return c.OIDC.Scopes }, []string{"openid", "email", "profile"}},
+		{"OIDC_SCOPES", "openid,email", func(c Config) []string { return c.OIDC.Scopes }, []string{"openid,email"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.env+"="+tt.value, func(t *testing.T) {
+			clearEnv(t)
+			t.Setenv("STORAGE_BACKEND", "memory")
+			t.Setenv(tt.env, tt.value)
+
+			config, err := Load("")
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := tt.get(config); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("%s = %q, want %q", tt.env, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestLoadRejects(t *testing.T) {
+	tests := []struct {
+		name    string
+		file    string // name of the config file; empty for none
+		content string // content of the file; empty to leave it missing
+		env     map[string]string
+		want    string // part of the error
+	}{
+		{"unknown key", "config.yaml", "server:\n  prot: 9000\n", nil, "Unknown setting server.prot"},
+		{"unknown section", "config.toml", "[sever]\nport = 9000\n", nil, "Unknown setting sever.port"},
+		{"unsupported format", "config.json", `{"server": {"port": 9000}}`, nil, "Unsupported config file"},
+		{"malformed YAML", "config.yaml", "server: [port\n", nil, "Error parsing config file"},
+		{"malformed TOML", "config.toml", "[server\n", nil, "Error parsing config file"},
+		{"number in file", "config.yaml", "server:\n  port: eighty\n", nil, "Invalid server.port"},
+		{"list for a single value", "config.yaml", "server:\n  port: [80, 81]\n", nil, "expected a single value"},
+		{"duration in file", "config.toml", "[server]\nshutdown_timeout = \"soon\"\n", nil, "Invalid server.shutdown_timeout"},
+		{"number in environment", "", "", map[string]string{"PORT": "eighty"}, "Invalid PORT"},
+		{"duration in environment", "", "", map[string]string{"SHUTDOWN_TIMEOUT": "30"}, "Invalid SHUTDOWN_TIMEOUT"},
+		{"flag in environment", "", "", map[string]string{"CORS_ALLOW_CREDENTIALS": "maybe"}, "Invalid CORS_ALLOW_CREDENTIALS"},
+		{"invalid settings", "", "", map[string]string{"STORAGE_BACKEND": "mongo"}, "Invalid configuration"},
+		{"missing file", "config.yaml", "", nil, "Error reading config file"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			clearEnv(t)
+			t.Setenv("STORAGE_BACKEND", "memory")
+			for name, value := range tt.env {
+				t.Setenv(name, value)
+			}
+
+			path := ""
+			switch {
+			case tt.content != "":
+				path = writeFile(t, tt.file, tt.content)
+			case tt.file != "":
+				path = filepath.Join(t.TempDir(), tt.file)
+			}
+
+			_, err := Load(path)
+			if err == nil {
+				t.Fatalf("Load() = nil, want an error containing %q", tt.want)
+			}
+			if !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("Load() = %q, want it to contain %q", err, tt.want)
+			}
+		})
+	}
+}
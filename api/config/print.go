@@ -0,0 +1,55 @@
+package config
+
+import (
+	"fmt"
+	"io"
+	"net/url"
+	"strings"
+	"text/tabwriter"
+	"time"
+)
+
+// redacted replaces secrets when the config is printed.
+const redacted string = "REDACTED"
+
+// Print writes the effective settings to w, one per line with their environment
+// variable, so operators can check what a running instance uses. Secrets are redacted.
+func (c Config) Print(w io.Writer) error {
+	table := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+
+	for _, setting := range c.settings() {
+		fmt.Fprintf(table, "%s\t%s\t%s\n", setting.key, setting.env, setting.display())
+	}
+
+	return table.Flush()
+}
+
+// display formats the value of the setting, redacting it if it is a secret.
+func (s setting) display() string {
+	var text string
+	switch value := s.value.Interface().(type) {
+	case time.Duration:
+		text = value.String()
+	case []string:
+		text = strings.Join(value, s.sep)
+	default:
+		text = fmt.Sprint(value)
+	}
+
+	switch {
+	case text == "" || s.secret == "":
+		return text
+	case s.secret == "password":
+		// Only the password of a connection string is secret, the host helps debugging
+		parsed, err := url.Parse(text)
+		if err != nil {
+			return redacted
+		}
+		if _, ok := parsed.User.Password(); ok {
+			parsed.User = url.UserPassword(parsed.User.Username(), redacted)
+		}
+		return parsed.String()
+	default:
+		return redacted
+	}
+}
@@ -0,0 +1,141 @@
+package health
+
+import (
+	"context"
+	"net/http"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Values of Report.Status and CheckResult.Status
+const (
+	StatusOK           string = "ok"
+	StatusFail         string = "fail"
+	StatusShuttingDown string = "shutting_down"
+)
+
+// Check reports whether a dependency can be used, returning nil when it can. It must
+// give up when ctx is done.
+type Check func(ctx context.Context) error
+
+// CheckResult is the outcome of one Check.
+type CheckResult struct {
+	Status      string `json:"status"`
+	Error       string `json:"error,omitempty"`
+	Duration_ms int64  `json:"duration_ms"`
+}
+
+// Report is the answer of the readiness endpoint.
+type Report struct {
+	Status string                 `json:"status"`
+	Checks map[string]CheckResult `json:"checks,omitempty"`
+}
+
+// Checker runs the readiness checks of one API instance. Checks can be added at any
+// time, e.g. for caches or queues an instance starts to depend on.
+type Checker struct {
+	// Timeout bounds each check, so one hanging dependency cannot stall the report.
+	Timeout time.Duration
+
+	mu           sync.RWMutex
+	checks       map[string]Check
+	shuttingDown atomic.Bool
+}
+
+func NewChecker(timeout time.Duration) *Checker {
+	return &Checker{Timeout: timeout, checks: map[string]Check{}}
+}
+
+// Register adds check under name, replacing any check of that name.
+func (checker *Checker) Register(name string, check Check) {
+	checker.mu.Lock()
+	defer checker.mu.Unlock()
+
+	checker.checks[name] = check
+}
+
+// SetShuttingDown makes the instance report itself as not ready from now on, so that
+// load balancers stop sending it requests while it drains the ones in flight.
+func (checker *Checker) SetShuttingDown() {
+	checker.shuttingDown.Store(true)
+}
+
+// Ready runs all checks concurrently and reports whether every one passed.
+func (checker *Checker) Ready(ctx context.Context) Report {
+	if checker.shuttingDown.Load() {
+		return Report{Status: StatusShuttingDown}
+	}
+
+	checker.mu.RLock()
+	checks := make(map[string]Check, len(checker.checks))
+	for name, check := range checker.checks {
+		checks[name] = check
+	}
+	checker.mu.RUnlock()
+
+	report := Report{Status: StatusOK, Checks: make(map[string]CheckResult, len(checks))}
+
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	for name, check := range checks {
+		wg.Add(1)
+		go func(name string, check Check) {
+			defer wg.Done()
+			result := checker.run(ctx, check)
+
+			mu.Lock()
+			defer mu.Unlock()
+			report.Checks[name] = result
+			if result.Status != StatusOK {
+				report.Status = StatusFail
+			}
+		}(name, check)
+	}
+	wg.Wait()
+
+	return report
+}
+
+// run runs one check within the Timeout.
+func (checker *Checker) run(ctx context.Context, check Check) CheckResult {
+	ctx, cancel := context.WithTimeout(ctx, checker.Timeout)
+	defer cancel()
+
+	start := time.Now()
+	err := check(ctx)
+	result := CheckResult{Status: StatusOK, Duration_ms: time.Since(start).Milliseconds()}
+
+	if err != nil {
+		result.Status = StatusFail
+		result.Error = err.Error()
+	}
+
+	return result
+}
+
+// Liveness answers whether the process is up. It checks no dependencies, so an
+// orchestrator does not restart instances that merely wait for MongoDB.
+func (checker *Checker) Liveness() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		c.IndentedJSON(http.StatusOK, Report{Status: StatusOK})
+	}
+}
+
+// Readiness answers whether the instance can serve requests, with the result of every
+// check. It responds 503 when a check fails or the instance is shutting down.
+func (checker *Checker) Readiness() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		report := checker.Ready(c.Request.Context())
+
+		status := http.StatusOK
+		if report.Status != StatusOK {
+			status = http.StatusServiceUnavailable
+		}
+
+		c.Header("Cache-Control", "no-store")
+		c.IndentedJSON(status, report)
+	}
+}
@@ -0,0 +1,131 @@
+package jwtkeys
+
+import (
+	"crypto"
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"time"
+
+	jwt "github.com/golang-jwt/jwt/v5"
+)
+
+// Supported signing algorithms
+const (
+	RS256 string = "RS256"
+	EdDSA string = "EdDSA" // Ed25519
+)
+
+// notBeforeHeader is the PEM header holding the time a key starts signing tokens.
+// Keys without it sign as soon as they are loaded.
+const notBeforeHeader string = "Not-Before"
+
+// minRSABits is the smallest RSA key accepted.
+const minRSABits int = 2048
+
+// Key is a private signing key. Its kid is the name of the file it was loaded from.
+type Key struct {
+	ID         string
+	Algorithm  string
+	Not_before time.Time
+	Path       string
+
+	signer crypto.Signer
+}
+
+// PublicKey returns the key tokens signed with k are verified with.
+func (k *Key) PublicKey() crypto.PublicKey {
+	return k.signer.Public()
+}
+
+// SigningMethod returns the JWT signing method of k.
+func (k *Key) SigningMethod() jwt.SigningMethod {
+	if k.Algorithm == EdDSA {
+		return jwt.SigningMethodEdDSA
+	}
+	return jwt.SigningMethodRS256
+}
+
+// signingKey returns k in the form its signing method expects.
+func (k *Key) signingKey() interface{} {
+	if k.Algorithm == EdDSA {
+		return k.signer
+	}
+	return k.signer.(*rsa.PrivateKey)
+}
+
+// GenerateKey returns a new private key for algorithm.
+func GenerateKey(algorithm string) (crypto.Signer, error) {
+	switch algorithm {
+	case RS256:
+		return rsa.GenerateKey(rand.Reader, 3072)
+	case EdDSA:
+		_, privateKey, err := ed25519.GenerateKey(rand.Reader)
+		return privateKey, err
+	default:
+		return nil, fmt.Errorf("unsupported algorithm %q, expected %s or %s", algorithm, RS256, EdDSA)
+	}
+}
+
+// EncodeKey returns signer as a PKCS #8 PEM block. A non-zero notBefore is written
+// as a header so the key is only used for signing from then on.
+func EncodeKey(signer crypto.Signer, notBefore time.Time) ([]byte, error) {
+	der, err := x509.MarshalPKCS8PrivateKey(signer)
+	if err != nil {
+		return nil, err
+	}
+
+	block := &pem.Block{Type: "PRIVATE KEY", Bytes: der}
+	if !notBefore.IsZero() {
+		block.Headers = map[string]string{notBeforeHeader: notBefore.UTC().Format(time.RFC3339)}
+	}
+
+	return pem.EncodeToMemory(block), nil
+}
+
+// ParseKey reads a PEM encoded RSA (PKCS #1 or PKCS #8) or Ed25519 (PKCS #8) private key.
+func ParseKey(id string, data []byte) (*Key, error) {
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, errors.New("no PEM block found")
+	}
+
+	var parsed interface{}
+	var err error
+	switch block.Type {
+	case "PRIVATE KEY":
+		parsed, err = x509.ParsePKCS8PrivateKey(block.Bytes)
+	case "RSA PRIVATE KEY":
+		parsed, err = x509.ParsePKCS1PrivateKey(block.Bytes)
+	default:
+		return nil, fmt.Errorf("unsupported PEM block %q", block.Type)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	key := &Key{ID: id}
+	switch privateKey := parsed.(type) {
+	case *rsa.PrivateKey:
+		if privateKey.N.BitLen() < minRSABits {
+			return nil, fmt.Errorf("RSA key has %d bits, at least %d are required", privateKey.N.BitLen(), minRSABits)
+		}
+		key.Algorithm, key.signer = RS256, privateKey
+	case ed25519.PrivateKey:
+		key.Algorithm, key.signer = EdDSA, privateKey
+	default:
+		return nil, fmt.Errorf("unsupported key type %T, expected RSA or Ed25519", parsed)
+	}
+
+	if value, ok := block.Headers[notBeforeHeader]; ok {
+		if key.Not_before, err = time.Parse(time.RFC3339, value); err != nil {
+			return nil, fmt.Errorf("invalid %s header: %w", notBeforeHeader, err)
+		}
+	}
+
+	return key, nil
+}
@@ -0,0 +1,193 @@
+package jwtkeys
+
+import (
+	"crypto/ed25519"
+	"crypto/rsa"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"math/big"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	jwt "github.com/golang-jwt/jwt/v5"
+)
+
+// ErrNoKeys is returned when no usable key material is configured.
+var ErrNoKeys = errors.New("no JWT signing keys found")
+
+// Keyring holds the keys of a directory of <kid>.pem files. The key with the latest
+// Not-Before that has passed signs new tokens; all keys verify tokens, so a key can be
+// published (and fetched from the JWKS by other services) before it starts signing,
+// and keeps verifying after it has been superseded until its file is removed.
+type Keyring struct {
+	Dir string
+
+	mu   sync.RWMutex
+	keys []*Key // sorted by Not_before, then kid
+}
+
+// LoadDir loads the keys in dir. It fails unless at least one key can sign now.
+func LoadDir(dir string) (*Keyring, error) {
+	keyring := &Keyring{Dir: dir}
+	if err := keyring.Reload(); err != nil {
+		return nil, err
+	}
+
+	return keyring, nil
+}
+
+// ReadDir parses every .pem file in dir, sorted by Not_before and kid.
+func ReadDir(dir string) ([]*Key, error) {
+	paths, err := filepath.Glob(filepath.Join(dir, "*.pem"))
+	if err != nil {
+		return nil, err
+	}
+
+	var keys []*Key
+	for _, path := range paths {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return nil, err
+		}
+
+		key, err := ParseKey(strings.TrimSuffix(filepath.Base(path), ".pem"), data)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", path, err)
+		}
+		key.Path = path
+		keys = append(keys, key)
+	}
+
+	sort.Slice(keys, func(i, j int) bool {
+		if !keys[i].Not_before.Equal(keys[j].Not_before) {
+			return keys[i].Not_before.Before(keys[j].Not_before)
+		}
+		return keys[i].ID < keys[j].ID
+	})
+
+	return keys, nil
+}
+
+// Reload reads the directory again, picking up added and removed keys. The loaded
+// keys are kept when the directory has no key that can sign now.
+func (keyring *Keyring) Reload() error {
+	keys, err := ReadDir(keyring.Dir)
+	if err != nil {
+		return err
+	}
+	if activeKey(keys, time.Now()) == nil {
+		return fmt.Errorf("%w in %s: add a PEM private key, e.g. with `go run ./cmd/keygen -dir %s`", ErrNoKeys, keyring.Dir, keyring.Dir)
+	}
+
+	keyring.mu.Lock()
+	keyring.keys = keys
+	keyring.mu.Unlock()
+
+	return nil
+}
+
+// SigningKey returns the key that signs tokens at now.
+func (keyring *Keyring) SigningKey(now time.Time) (*Key, error) {
+	keyring.mu.RLock()
+	defer keyring.mu.RUnlock()
+
+	if key := activeKey(keyring.keys, now); key != nil {
+		return key, nil
+	}
+	return nil, ErrNoKeys
+}
+
+// Sign signs claims with the current signing key, naming it in the kid header.
+func (keyring *Keyring) Sign(claims jwt.Claims) (string, error) {
+	key, err := keyring.SigningKey(time.Now())
+	if err != nil {
+		return "", err
+	}
+
+	token := jwt.NewWithClaims(key.SigningMethod(), claims)
+	token.Header["kid"] = key.ID
+
+	return token.SignedString(key.signingKey())
+}
+
+// Keyfunc finds the public key of the token's kid for jwt.Parse. The token's algorithm
+// must be the key's, so a key cannot be used with another algorithm.
+func (keyring *Keyring) Keyfunc(token *jwt.Token) (interface{}, error) {
+	kid, _ := token.Header["kid"].(string)
+
+	keyring.mu.RLock()
+	defer keyring.mu.RUnlock()
+
+	for _, key := range keyring.keys {
+		if key.ID != kid {
+			continue
+		}
+		if token.Method.Alg() != key.SigningMethod().Alg() {
+			return nil, fmt.Errorf("key %q does not sign with %s", kid, token.Method.Alg())
+		}
+		return key.PublicKey(), nil
+	}
+
+	return nil, fmt.Errorf("unknown signing key %q", kid)
+}
+
+// Algorithms are the JWT algorithms keyrings sign with, for jwt.WithValidMethods.
+var Algorithms = []string{RS256, EdDSA}
+
+// JSONWebKey is a public key in JWK form (RFC 7517, RFC 7518 section 6.3, RFC 8037).
+type JSONWebKey struct {
+	Kty string `json:"kty"`
+	Kid string `json:"kid"`
+	Use string `json:"use"`
+	Alg string `json:"alg"`
+	N   string `json:"n,omitempty"`
+	E   string `json:"e,omitempty"`
+	Crv string `json:"crv,omitempty"`
+	X   string `json:"x,omitempty"`
+}
+
+type JSONWebKeySet struct {
+	Keys []JSONWebKey `json:"keys"`
+}
+
+// JWKS returns the public keys of all keys, including those not signing yet.
+func (keyring *Keyring) JWKS() JSONWebKeySet {
+	keyring.mu.RLock()
+	defer keyring.mu.RUnlock()
+
+	set := JSONWebKeySet{Keys: []JSONWebKey{}}
+	for _, key := range keyring.keys {
+		jwk := JSONWebKey{Kid: key.ID, Use: "sig", Alg: key.Algorithm}
+
+		switch publicKey := key.PublicKey().(type) {
+		case *rsa.PublicKey:
+			jwk.Kty = "RSA"
+			jwk.N = base64.RawURLEncoding.EncodeToString(publicKey.N.Bytes())
+			jwk.E = base64.RawURLEncoding.EncodeToString(big.NewInt(int64(publicKey.E)).Bytes())
+		case ed25519.PublicKey:
+			jwk.Kty = "OKP"
+			jwk.Crv = "Ed25519"
+			jwk.X = base64.RawURLEncoding.EncodeToString(publicKey)
+		}
+
+		set.Keys = append(set.Keys, jwk)
+	}
+
+	return set
+}
+
+// activeKey returns the last key in keys whose Not_before has passed.
+func activeKey(keys []*Key, now time.Time) *Key {
+	for i := len(keys) - 1; i >= 0; i-- {
+		if !keys[i].Not_before.After(now) {
+			return keys[i]
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,282 @@
+package jwtkeys
+
+import (
+	"crypto"
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/base64"
+	"errors"
+	"math/big"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	jwt "github.com/golang-jwt/jwt/v5"
+)
+
+// writeKey stores signer as <kid>.pem in dir.
+func writeKey(t *testing.T, dir, kid string, signer crypto.Signer, notBefore time.Time) {
+	t.Helper()
+
+	data, err := EncodeKey(signer, notBefore)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, kid+".pem"), data, 0o600); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func newEd25519Key(t *testing.T) ed25519.PrivateKey {
+	t.Helper()
+
+	_, privateKey, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return privateKey
+}
+
+func newRSAKey(t *testing.T, bits int) *rsa.PrivateKey {
+	t.Helper()
+
+	privateKey, err := rsa.GenerateKey(rand.Reader, bits)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return privateKey
+}
+
+func parse(keyring *Keyring, token string) (*jwt.Token, error) {
+	return jwt.Parse(token, keyring.Keyfunc, jwt.WithValidMethods(Algorithms))
+}
+
+func TestLoadDirWithoutKeys(t *testing.T) {
+	if _, err := LoadDir(t.TempDir()); !errors.Is(err, ErrNoKeys) {
+		t.Fatalf("LoadDir of an empty directory: err = %v, want ErrNoKeys", err)
+	}
+}
+
+func TestLoadDirWithOnlyFutureKeys(t *testing.T) {
+	dir := t.TempDir()
+	writeKey(t, dir, "next", newEd25519Key(t), time.Now().Add(time.Hour))
+
+	if _, err := LoadDir(dir); !errors.Is(err, ErrNoKeys) {
+		t.Fatalf("LoadDir without a key signing now: err = %v, want ErrNoKeys", err)
+	}
+}
+
+func TestSignAndVerify(t *testing.T) {
+	for _, tt := range []struct {
+		name   string
+		signer crypto.Signer
+		alg    string
+	}{
+		{"Ed25519", newEd25519Key(t), EdDSA},
+		{"RSA", newRSAKey(t, 2048), RS256},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			writeKey(t, dir, "current", tt.signer, time.Time{})
+
+			keyring, err := LoadDir(dir)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			signed, err := keyring.Sign(jwt.MapClaims{"sub": "alice"})
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			token, err := parse(keyring, signed)
+			if err != nil {
+				t.Fatalf("verifying a token of the keyring: %v", err)
+			}
+			if token.Header["kid"] != "current" || token.Method.Alg() != tt.alg {
+				t.Errorf("header = %v, want kid current and alg %s", token.Header, tt.alg)
+			}
+		})
+	}
+}
+
+func TestRotation(t *testing.T) {
+	dir := t.TempDir()
+	now := time.Now()
+	writeKey(t, dir, "old", newEd25519Key(t), now.Add(-time.Hour))
+	writeKey(t, dir, "next", newEd25519Key(t), now.Add(time.Hour))
+
+	keyring, err := LoadDir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The next key is published before it signs
+	if key, _ := keyring.SigningKey(now); key.ID != "old" {
+		t.Errorf("signing key now = %s, want old", key.ID)
+	}
+	if key, _ := keyring.SigningKey(now.Add(2 * time.Hour)); key.ID != "next" {
+		t.Errorf("signing key after Not-Before = %s, want next", key.ID)
+	}
+
+	signed, err := keyring.Sign(jwt.MapClaims{"sub": "alice"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Tokens of the old key still verify once a new key is added and loaded
+	writeKey(t, dir, "newest", newEd25519Key(t), now.Add(-time.Minute))
+	if err := keyring.Reload(); err != nil {
+		t.Fatal(err)
+	}
+	if key, _ := keyring.SigningKey(now); key.ID != "newest" {
+		t.Errorf("signing key after reload = %s, want newest", key.ID)
+	}
+	if _, err := parse(keyring, signed); err != nil {
+		t.Errorf("token of a superseded key: %v", err)
+	}
+
+	// ...until its file is removed
+	if err := os.Remove(filepath.Join(dir, "old.pem")); err != nil {
+		t.Fatal(err)
+	}
+	if err := keyring.Reload(); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := parse(keyring, signed); err == nil {
+		t.Error("token of a removed key still verifies")
+	}
+}
+
+func TestReloadKeepsKeysWhenNoneCanSign(t *testing.T) {
+	dir := t.TempDir()
+	writeKey(t, dir, "current", newEd25519Key(t), time.Time{})
+
+	keyring, err := LoadDir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := os.Remove(filepath.Join(dir, "current.pem")); err != nil {
+		t.Fatal(err)
+	}
+	if err := keyring.Reload(); !errors.Is(err, ErrNoKeys) {
+		t.Fatalf("Reload of an empty directory: err = %v, want ErrNoKeys", err)
+	}
+	if _, err := keyring.Sign(jwt.MapClaims{}); err != nil {
+		t.Errorf("Sign after a failed reload: %v", err)
+	}
+}
+
+func TestKeyfuncRejectsOtherTokens(t *testing.T) {
+	dir := t.TempDir()
+	writeKey(t, dir, "current", newEd25519Key(t), time.Time{})
+
+	keyring, err := LoadDir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Signed by a key the keyring does not hold, under a known kid
+	foreign := jwt.NewWithClaims(jwt.SigningMethodEdDSA, jwt.MapClaims{"sub": "mallory"})
+	foreign.Header["kid"] = "current"
+	forged, err := foreign.SignedString(newEd25519Key(t))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := parse(keyring, forged); err == nil {
+		t.Error("token signed by a foreign key verified")
+	}
+
+	// Unknown kid
+	foreign.Header["kid"] = "missing"
+	unknown, err := foreign.SignedString(newEd25519Key(t))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := parse(keyring, unknown); err == nil {
+		t.Error("token with an unknown kid verified")
+	}
+
+	// An HMAC token must not be verified with a public key as the secret
+	hmac := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.MapClaims{"sub": "mallory"})
+	hmac.Header["kid"] = "current"
+	hmacSigned, err := hmac.SignedString([]byte("secret"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := parse(keyring, hmacSigned); err == nil {
+		t.Error("HS256 token verified")
+	}
+}
+
+func TestJWKS(t *testing.T) {
+	dir := t.TempDir()
+	rsaKey := newRSAKey(t, 2048)
+	edKey := newEd25519Key(t)
+	writeKey(t, dir, "rsa", rsaKey, time.Now().Add(-time.Hour))
+	writeKey(t, dir, "ed", edKey, time.Now().Add(time.Hour))
+
+	keyring, err := LoadDir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	set := keyring.JWKS()
+	if len(set.Keys) != 2 {
+		t.Fatalf("JWKS has %d keys, want 2 including the one not signing yet", len(set.Keys))
+	}
+
+	for _, jwk := range set.Keys {
+		switch jwk.Kid {
+		case "rsa":
+			if jwk.Kty != "RSA" || jwk.Alg != RS256 || jwk.Use != "sig" {
+				t.Errorf("RSA key = %+v", jwk)
+			}
+			n, _ := base64.RawURLEncoding.DecodeString(jwk.N)
+			e, _ := base64.RawURLEncoding.DecodeString(jwk.E)
+			if new(big.Int).SetBytes(n).Cmp(rsaKey.N) != 0 || new(big.Int).SetBytes(e).Int64() != int64(rsaKey.E) {
+				t.Error("RSA JWK does not hold the public key")
+			}
+		case "ed":
+			if jwk.Kty != "OKP" || jwk.Crv != "Ed25519" || jwk.Alg != EdDSA {
+				t.Errorf("Ed25519 key = %+v", jwk)
+			}
+			x, _ := base64.RawURLEncoding.DecodeString(jwk.X)
+			if !edKey.Public().(ed25519.PublicKey).Equal(ed25519.PublicKey(x)) {
+				t.Error("Ed25519 JWK does not hold the public key")
+			}
+		default:
+			t.Errorf("unexpected kid %q", jwk.Kid)
+		}
+	}
+}
+
+func TestParseKeyRejectsWeakRSA(t *testing.T) {
+	data, err := EncodeKey(newRSAKey(t, 1024), time.Time{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := ParseKey("weak", data); err == nil {
+		t.Error("ParseKey accepted a 1024 bit RSA key")
+	}
+}
+
+func TestParseKeyReadsNotBefore(t *testing.T) {
+	notBefore := time.Date(2030, time.January, 2, 3, 4, 5, 0, time.UTC)
+	data, err := EncodeKey(newEd25519Key(t), notBefore)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	key, err := ParseKey("next", data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !key.Not_before.Equal(notBefore) || key.Algorithm != EdDSA {
+		t.Errorf("key = %+v, want EdDSA not before %s", key, notBefore)
+	}
+}
@@ -0,0 +1,217 @@
+package loginguard
+
+import (
+	"context"
+	"strings"
+	"time"
+)
+
+// Counter tracks the recent failed logins for one key (an account or an IP address).
+type Counter struct {
+	Key           string    `json:"key" bson:"key"`
+	Failures      int       `json:"failures" bson:"failures"`
+	Last_failure  time.Time `json:"last_failure" bson:"last_failure"`
+	Blocked_until time.Time `json:"blocked_until" bson:"blocked_until"`
+}
+
+// AuditEntry records one failed login.
+type AuditEntry struct {
+	Email_address string    `json:"email_address" bson:"email_address"`
+	Ip_address    string    `json:"ip_address" bson:"ip_address"`
+	Reason        string    `json:"reason" bson:"reason"`
+	Occurred_at   time.Time `json:"occurred_at" bson:"occurred_at"`
+}
+
+// Reasons recorded in the audit trail
+const (
+	ReasonUnknownEmail  string = "unknown_email"
+	ReasonWrongPassword string = "wrong_password"
+	ReasonWrongMFACode  string = "wrong_mfa_code"
+	ReasonPasswordReset string = "password_reset" // a password reset was requested
+)
+
+// Store keeps counters and the audit trail. MemoryStore suits a single instance;
+// MongoStore shares the state between replicas.
+type Store interface {
+	// Get returns the counter for key, or a zero Counter when there is none.
+	Get(ctx context.Context, key string) (Counter, error)
+	// RecordFailure adds a failure to the counter for key and returns it. Failures older
+	// than window are forgotten first.
+	RecordFailure(ctx context.Context, key string, now time.Time, window time.Duration) (Counter, error)
+	// Block stops logins for key until the given time.
+	Block(ctx context.Context, key string, until time.Time) error
+	// Reset forgets all failures for key.
+	Reset(ctx context.Context, key string) error
+	// Audit appends entry to the audit trail.
+	Audit(ctx context.Context, entry AuditEntry) error
+	// AuditTrail returns the newest entries for an email address, newest first.
+	AuditTrail(ctx context.Context, emailAddress string, limit int) ([]AuditEntry, error)
+	// DeleteByEmail removes every audit entry for an email address.
+	DeleteByEmail(ctx context.Context, emailAddress string) error
+}
+
+// Policy decides when a key is blocked. After FreeAttempts failures every further
+// failure blocks the key for BaseDelay, doubled per failure up to MaxDelay. From
+// LockoutThreshold failures on (0 disables it) the key is locked for LockoutDuration.
+// Failures are forgotten after Window without a new one.
+type Policy struct {
+	FreeAttempts     int
+	BaseDelay        time.Duration
+	MaxDelay         time.Duration
+	LockoutThreshold int
+	LockoutDuration  time.Duration
+	Window           time.Duration
+}
+
+// DefaultAccountPolicy applies to failed logins per email address.
+var DefaultAccountPolicy = Policy{
+	FreeAttempts:     3,
+	BaseDelay:        time.Second,
+	MaxDelay:         time.Minute,
+	LockoutThreshold: 10,
+	LockoutDuration:  15 * time.Minute,
+	Window:           24 * time.Hour,
+}
+
+// DefaultIPPolicy applies to failed logins per client IP address. It is more lenient
+// because many users may share an address, and never locks out.
+var DefaultIPPolicy = Policy{
+	FreeAttempts: 20,
+	BaseDelay:    time.Second,
+	MaxDelay:     5 * time.Minute,
+	Window:       time.Hour,
+}
+
+// Guard applies the account and IP policies to login attempts.
+type Guard struct {
+	Store         Store
+	AccountPolicy Policy
+	IPPolicy      Policy
+}
+
+// New returns a Guard using the default policies.
+func New(store Store) *Guard {
+	return &Guard{Store: store, AccountPolicy: DefaultAccountPolicy, IPPolicy: DefaultIPPolicy}
+}
+
+// Decision tells whether a login attempt may go ahead.
+type Decision struct {
+	Allowed     bool
+	Locked      bool          // the account reached the lockout threshold
+	Retry_after time.Duration // how long until the next attempt is allowed
+}
+
+// Check is called before verifying credentials.
+func (g *Guard) Check(ctx context.Context, emailAddress, ipAddress string) (Decision, error) {
+	now := time.Now()
+
+	account, err := g.Store.Get(ctx, accountKey(emailAddress))
+	if err != nil {
+		return Decision{}, err
+	}
+
+	ip, err := g.Store.Get(ctx, ipKey(ipAddress))
+	if err != nil {
+		return Decision{}, err
+	}
+
+	wait := max(account.Blocked_until.Sub(now), ip.Blocked_until.Sub(now))
+	if wait <= 0 {
+		return Decision{Allowed: true}, nil
+	}
+
+	locked := g.AccountPolicy.LockoutThreshold > 0 &&
+		account.Failures >= g.AccountPolicy.LockoutThreshold &&
+		account.Blocked_until.After(now)
+
+	return Decision{Locked: locked, Retry_after: wait}, nil
+}
+
+// RecordFailure counts a failed login against the account and the IP address,
+// blocks them as the policies require and adds it to the audit trail.
+func (g *Guard) RecordFailure(ctx context.Context, emailAddress, ipAddress, reason string) error {
+	now := time.Now()
+
+	if err := g.recordFailure(ctx, accountKey(emailAddress), g.AccountPolicy, now); err != nil {
+		return err
+	}
+	if err := g.recordFailure(ctx, ipKey(ipAddress), g.IPPolicy, now); err != nil {
+		return err
+	}
+
+	return g.Store.Audit(ctx, AuditEntry{
+		Email_address: normalizeEmail(emailAddress),
+		Ip_address:    ipAddress,
+		Reason:        reason,
+		Occurred_at:   now,
+	})
+}
+
+// RecordSuccess clears the account's failures. The IP counter is kept, so logging in
+// to one's own account does not reset the budget for guessing other accounts.
+func (g *Guard) RecordSuccess(ctx context.Context, emailAddress string) error {
+	return g.Store.Reset(ctx, accountKey(emailAddress))
+}
+
+// Unlock lifts a lockout or backoff on the account, e.g. after an admin checked it.
+func (g *Guard) Unlock(ctx context.Context, emailAddress string) error {
+	return g.Store.Reset(ctx, accountKey(emailAddress))
+}
+
+// AuditTrail returns the newest failed logins for the email address.
+func (g *Guard) AuditTrail(ctx context.Context, emailAddress string, limit int) ([]AuditEntry, error) {
+	return g.Store.AuditTrail(ctx, normalizeEmail(emailAddress), limit)
+}
+
+// Forget removes the account's failures and audit trail, e.g. when the account is purged.
+func (g *Guard) Forget(ctx context.Context, emailAddress string) error {
+	if err := g.Store.Reset(ctx, accountKey(emailAddress)); err != nil {
+		return err
+	}
+
+	return g.Store.DeleteByEmail(ctx, normalizeEmail(emailAddress))
+}
+
+func (g *Guard) recordFailure(ctx context.Context, key string, policy Policy, now time.Time) error {
+	counter, err := g.Store.RecordFailure(ctx, key, now, policy.Window)
+	if err != nil {
+		return err
+	}
+
+	if delay := policy.delay(counter.Failures); delay > 0 {
+		return g.Store.Block(ctx, key, now.Add(delay))
+	}
+
+	return nil
+}
+
+// delay returns how long to block after the given number of failures.
+func (p Policy) delay(failures int) time.Duration {
+	if p.LockoutThreshold > 0 && failures >= p.LockoutThreshold {
+		return p.LockoutDuration
+	}
+
+	excess := failures - p.FreeAttempts
+	if excess <= 0 {
+		return 0
+	}
+
+	delay := p.BaseDelay
+	for i := 1; i < excess && delay < p.MaxDelay; i++ {
+		delay *= 2
+	}
+
+	return min(delay, p.MaxDelay)
+}
+
+func accountKey(emailAddress string) string {
+	return "account:" + normalizeEmail(emailAddress)
+}
+
+func ipKey(ipAddress string) string {
+	return "ip:" + ipAddress
+}
+
+func normalizeEmail(emailAddress string) string {
+	return strings.ToLower(strings.TrimSpace(emailAddress))
+}
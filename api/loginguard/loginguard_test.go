@@ -0,0 +1,216 @@
+package loginguard
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestPolicyDelay(t *testing.T) {
+	policy := Policy{
+		FreeAttempts:     3,
+		BaseDelay:        time.Second,
+		MaxDelay:         10 * time.Second,
+		LockoutThreshold: 8,
+		LockoutDuration:  time.Hour,
+	}
+
+	tests := []struct {
+		failures int
+		want     time.Duration
+	}{
+		{0, 0},
+		{3, 0},
+		{4, time.Second},
+		{5, 2 * time.Second},
+		{6, 4 * time.Second},
+		{7, 8 * time.Second},
+		{8, time.Hour},  // lockout
+		{20, time.Hour}, // still locked
+	}
+
+	for _, tt := range tests {
+		if got := policy.delay(tt.failures); got != tt.want {
+			t.Errorf("delay(%d) = %s, want %s", tt.failures, got, tt.want)
+		}
+	}
+}
+
+func TestPolicyDelayIsCapped(t *testing.T) {
+	policy := DefaultIPPolicy
+
+	if got := policy.delay(1000); got != policy.MaxDelay {
+		t.Errorf("delay(1000) = %s, want MaxDelay %s", got, policy.MaxDelay)
+	}
+}
+
+func newTestGuard() *Guard {
+	guard := New(NewMemoryStore())
+	guard.AccountPolicy = Policy{
+		FreeAttempts:     2,
+		BaseDelay:        time.Minute,
+		MaxDelay:         time.Hour,
+		LockoutThreshold: 4,
+		LockoutDuration:  time.Hour,
+		Window:           time.Hour,
+	}
+	guard.IPPolicy = Policy{FreeAttempts: 100, BaseDelay: time.Second, MaxDelay: time.Minute, Window: time.Hour}
+
+	return guard
+}
+
+func fail(t *testing.T, guard *Guard, emailAddress string, times int) {
+	t.Helper()
+
+	for i := 0; i < times; i++ {
+		if err := guard.RecordFailure(context.Background(), emailAddress, "192.0.2.1", ReasonWrongPassword); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func check(t *testing.T, guard *Guard, emailAddress, ipAddress string) Decision {
+	t.Helper()
+
+	decision, err := guard.Check(context.Background(), emailAddress, ipAddress)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return decision
+}
+
+func TestGuardBacksOffAfterFreeAttempts(t *testing.T) {
+	guard := newTestGuard()
+
+	fail(t, guard, "alice@example.com", 2)
+	if decision := check(t, guard, "alice@example.com", "192.0.2.1"); !decision.Allowed {
+		t.Fatalf("blocked within the free attempts: %+v", decision)
+	}
+
+	fail(t, guard, "alice@example.com", 1)
+	decision := check(t, guard, "alice@example.com", "192.0.2.1")
+	if decision.Allowed || decision.Locked {
+		t.Fatalf("decision after 3 failures = %+v, want a backoff without lockout", decision)
+	}
+	if decision.Retry_after <= 0 || decision.Retry_after > time.Minute {
+		t.Errorf("Retry_after = %s, want up to a minute", decision.Retry_after)
+	}
+
+	// The backoff is per account, whatever the case of the address
+	if decision := check(t, guard, "ALICE@example.com ", "198.51.100.7"); decision.Allowed {
+		t.Error("another spelling of the address is not blocked")
+	}
+	if decision := check(t, guard, "bob@example.com", "198.51.100.7"); !decision.Allowed {
+		t.Error("another account is blocked")
+	}
+}
+
+func TestGuardLocksOut(t *testing.T) {
+	guard := newTestGuard()
+
+	fail(t, guard, "alice@example.com", 4)
+	decision := check(t, guard, "alice@example.com", "192.0.2.1")
+	if decision.Allowed || !decision.Locked {
+		t.Fatalf("decision at the lockout threshold = %+v, want locked", decision)
+	}
+
+	if err := guard.Unlock(context.Background(), "alice@example.com"); err != nil {
+		t.Fatal(err)
+	}
+	if decision := check(t, guard, "alice@example.com", "192.0.2.1"); !decision.Allowed {
+		t.Errorf("decision after Unlock = %+v, want allowed", decision)
+	}
+}
+
+func TestGuardBlocksBusyIPAddress(t *testing.T) {
+	guard := newTestGuard()
+	guard.IPPolicy.FreeAttempts = 3
+
+	// One failure each on many accounts stays under the account policy...
+	for _, emailAddress := range []string{"a@example.com", "b@example.com", "c@example.com", "d@example.com"} {
+		fail(t, guard, emailAddress, 1)
+	}
+
+	// ...but not under the policy of the address they came from
+	if decision := check(t, guard, "e@example.com", "192.0.2.1"); decision.Allowed {
+		t.Error("address guessing many accounts is not blocked")
+	}
+	if decision := check(t, guard, "e@example.com", "198.51.100.7"); !decision.Allowed {
+		t.Error("another address is blocked")
+	}
+}
+
+func TestRecordSuccessKeepsIPFailures(t *testing.T) {
+	guard := newTestGuard()
+	ctx := context.Background()
+
+	fail(t, guard, "alice@example.com", 2)
+	if err := guard.RecordSuccess(ctx, "alice@example.com"); err != nil {
+		t.Fatal(err)
+	}
+
+	account, _ := guard.Store.Get(ctx, accountKey("alice@example.com"))
+	ip, _ := guard.Store.Get(ctx, ipKey("192.0.2.1"))
+	if account.Failures != 0 || ip.Failures != 2 {
+		t.Errorf("failures after success: account %d, ip %d, want 0 and 2", account.Failures, ip.Failures)
+	}
+}
+
+func TestAuditTrail(t *testing.T) {
+	guard := newTestGuard()
+	ctx := context.Background()
+
+	fail(t, guard, "Alice@Example.com", 2)
+	fail(t, guard, "bob@example.com", 1)
+
+	entries, err := guard.AuditTrail(ctx, "alice@example.com", 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 2 {
+		t.Fatalf("got %d entries, want 2", len(entries))
+	}
+	if entries[0].Email_address != "alice@example.com" || entries[0].Reason != ReasonWrongPassword || entries[0].Ip_address != "192.0.2.1" {
+		t.Errorf("entry = %+v", entries[0])
+	}
+
+	if entries, _ := guard.AuditTrail(ctx, "alice@example.com", 1); len(entries) != 1 {
+		t.Errorf("limit 1 returned %d entries", len(entries))
+	}
+}
+
+func TestForgetRemovesAccountData(t *testing.T) {
+	guard := newTestGuard()
+	ctx := context.Background()
+
+	fail(t, guard, "alice@example.com", 3)
+	fail(t, guard, "bob@example.com", 1)
+
+	if err := guard.Forget(ctx, "ALICE@example.com"); err != nil {
+		t.Fatal(err)
+	}
+
+	if entries, _ := guard.AuditTrail(ctx, "alice@example.com", 10); len(entries) != 0 {
+		t.Errorf("audit trail after Forget has %d entries", len(entries))
+	}
+	if decision := check(t, guard, "alice@example.com", "198.51.100.7"); !decision.Allowed {
+		t.Error("account still blocked after Forget")
+	}
+	if entries, _ := guard.AuditTrail(ctx, "bob@example.com", 10); len(entries) != 1 {
+		t.Errorf("Forget removed the audit trail of another account")
+	}
+}
+
+func TestMemoryStoreForgetsOldFailures(t *testing.T) {
+	store := NewMemoryStore()
+	ctx := context.Background()
+	start := time.Now()
+
+	store.RecordFailure(ctx, "account:alice@example.com", start, time.Minute)
+	store.RecordFailure(ctx, "account:alice@example.com", start.Add(30*time.Second), time.Minute)
+
+	counter, _ := store.RecordFailure(ctx, "account:alice@example.com", start.Add(5*time.Minute), time.Minute)
+	if counter.Failures != 1 {
+		t.Errorf("failures after the window = %d, want 1", counter.Failures)
+	}
+}
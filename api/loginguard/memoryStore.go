@@ -0,0 +1,124 @@
+package loginguard
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// maxMemoryAuditEntries bounds the audit trail kept by MemoryStore.
+const maxMemoryAuditEntries int = 10000
+
+// MemoryStore keeps counters in process memory. State is lost on restart and
+// not shared between replicas.
+type MemoryStore struct {
+	mu       sync.Mutex
+	counters map[string]memoryCounter
+	audit    []AuditEntry
+}
+
+type memoryCounter struct {
+	Counter
+	expiresAt time.Time // when the failures are forgotten
+}
+
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{counters: map[string]memoryCounter{}}
+}
+
+func (s *MemoryStore) Get(ctx context.Context, key string) (Counter, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.counters[key].Counter, nil
+}
+
+func (s *MemoryStore) RecordFailure(ctx context.Context, key string, now time.Time, window time.Duration) (Counter, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.pruneLocked(now)
+
+	counter := s.counters[key]
+	if counter.expiresAt.Before(now) {
+		counter = memoryCounter{Counter: Counter{Blocked_until: counter.Blocked_until}}
+	}
+	counter.Key = key
+	counter.Failures++
+	counter.Last_failure = now
+	counter.expiresAt = now.Add(window)
+	s.counters[key] = counter
+
+	return counter.Counter, nil
+}
+
+func (s *MemoryStore) Block(ctx context.Context, key string, until time.Time) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	counter := s.counters[key]
+	counter.Key = key
+	counter.Blocked_until = until
+	s.counters[key] = counter
+
+	return nil
+}
+
+func (s *MemoryStore) Reset(ctx context.Context, key string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	delete(s.counters, key)
+	return nil
+}
+
+func (s *MemoryStore) Audit(ctx context.Context, entry AuditEntry) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.audit = append(s.audit, entry)
+	if len(s.audit) > maxMemoryAuditEntries {
+		s.audit = s.audit[len(s.audit)-maxMemoryAuditEntries:]
+	}
+
+	return nil
+}
+
+func (s *MemoryStore) AuditTrail(ctx context.Context, emailAddress string, limit int) ([]AuditEntry, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	entries := []AuditEntry{}
+	for i := len(s.audit) - 1; i >= 0 && len(entries) < limit; i-- {
+		if s.audit[i].Email_address == emailAddress {
+			entries = append(entries, s.audit[i])
+		}
+	}
+
+	return entries, nil
+}
+
+func (s *MemoryStore) DeleteByEmail(ctx context.Context, emailAddress string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	kept := s.audit[:0]
+	for _, entry := range s.audit {
+		if entry.Email_address != emailAddress {
+			kept = append(kept, entry)
+		}
+	}
+	s.audit = kept
+
+	return nil
+}
+
+// pruneLocked drops counters that are neither recent nor blocked, so that
+// failed logins for random addresses cannot grow the map without bound.
+func (s *MemoryStore) pruneLocked(now time.Time) {
+	for key, counter := range s.counters {
+		if counter.expiresAt.Before(now) && counter.Blocked_until.Before(now) {
+			delete(s.counters, key)
+		}
+	}
+}
@@ -0,0 +1,134 @@
+package loginguard
+
+import (
+	"context"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// auditRetention is how long MongoStore keeps audit entries.
+const auditRetention time.Duration = 90 * 24 * time.Hour
+
+// MongoStore keeps counters and the audit trail in MongoDB, so every replica sees them.
+// Expired documents are removed by TTL indexes.
+type MongoStore struct {
+	attempts *mongo.Collection
+	audit    *mongo.Collection
+}
+
+func NewMongoStore(attempts, audit *mongo.Collection) *MongoStore {
+	return &MongoStore{attempts: attempts, audit: audit}
+}
+
+// EnsureIndexes creates the lookup and TTL indexes of both collections.
+func (s *MongoStore) EnsureIndexes(ctx context.Context) error {
+	_, err := s.attempts.Indexes().CreateMany(ctx, []mongo.IndexModel{
+		{
+			Keys:    bson.D{{Key: "key", Value: 1}},
+			Options: options.Index().SetUnique(true).SetName("key_unique"),
+		},
+		{
+			Keys:    bson.D{{Key: "expires_at", Value: 1}},
+			Options: options.Index().SetExpireAfterSeconds(0).SetName("expires_at_ttl"),
+		},
+	})
+	if err != nil {
+		return err
+	}
+
+	_, err = s.audit.Indexes().CreateMany(ctx, []mongo.IndexModel{
+		{
+			Keys:    bson.D{{Key: "email_address", Value: 1}, {Key: "occurred_at", Value: -1}},
+			Options: options.Index().SetName("email_address_occurred_at"),
+		},
+		{
+			Keys:    bson.D{{Key: "occurred_at", Value: 1}},
+			Options: options.Index().SetExpireAfterSeconds(int32(auditRetention.Seconds())).SetName("occurred_at_ttl"),
+		},
+	})
+	return err
+}
+
+func (s *MongoStore) Get(ctx context.Context, key string) (Counter, error) {
+	var counter Counter
+
+	err := s.attempts.FindOne(ctx, bson.M{"key": key}).Decode(&counter)
+	if err == mongo.ErrNoDocuments {
+		return Counter{Key: key}, nil
+	}
+
+	return counter, err
+}
+
+func (s *MongoStore) RecordFailure(ctx context.Context, key string, now time.Time, window time.Duration) (Counter, error) {
+	var counter Counter
+
+	// A pipeline update restarts the count atomically when the last failure is outside the window
+	update := mongo.Pipeline{
+		{{Key: "$set", Value: bson.M{
+			"key": key,
+			"failures": bson.M{"$cond": bson.A{
+				bson.M{"$gt": bson.A{"$last_failure", now.Add(-window)}},
+				bson.M{"$add": bson.A{bson.M{"$ifNull": bson.A{"$failures", 0}}, 1}},
+				1,
+			}},
+			"last_failure":  now,
+			"blocked_until": bson.M{"$ifNull": bson.A{"$blocked_until", time.Time{}}},
+			"expires_at": bson.M{"$max": bson.A{
+				now.Add(window),
+				bson.M{"$ifNull": bson.A{"$blocked_until", now}},
+			}},
+		}}},
+	}
+
+	opts := options.FindOneAndUpdate().SetUpsert(true).SetReturnDocument(options.After)
+	err := s.attempts.FindOneAndUpdate(ctx, bson.M{"key": key}, update, opts).Decode(&counter)
+
+	return counter, err
+}
+
+func (s *MongoStore) Block(ctx context.Context, key string, until time.Time) error {
+	update := bson.M{
+		"$set": bson.M{"blocked_until": until},
+		"$max": bson.M{"expires_at": until},
+	}
+
+	_, err := s.attempts.UpdateOne(ctx, bson.M{"key": key}, update, options.Update().SetUpsert(true))
+	return err
+}
+
+func (s *MongoStore) Reset(ctx context.Context, key string) error {
+	_, err := s.attempts.DeleteOne(ctx, bson.M{"key": key})
+	return err
+}
+
+func (s *MongoStore) Audit(ctx context.Context, entry AuditEntry) error {
+	_, err := s.audit.InsertOne(ctx, entry)
+	return err
+}
+
+func (s *MongoStore) AuditTrail(ctx context.Context, emailAddress string, limit int) ([]AuditEntry, error) {
+	opts := options.Find().
+		SetSort(bson.D{{Key: "occurred_at", Value: -1}}).
+		SetLimit(int64(limit))
+
+	cursor, err := s.audit.Find(ctx, bson.M{"email_address": emailAddress}, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	entries := []AuditEntry{}
+	if err := cursor.All(ctx, &entries); err != nil {
+		return nil, err
+	}
+
+	return entries, nil
+}
+
+func (s *MongoStore) DeleteByEmail(ctx context.Context, emailAddress string) error {
+	_, err := s.audit.DeleteMany(ctx, bson.M{"email_address": emailAddress})
+	return err
+}
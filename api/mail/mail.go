@@ -0,0 +1,124 @@
+package mail
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"net/smtp"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// Message is a plain text email.
+type Message struct {
+	To      string
+	Subject string
+	Body    string
+}
+
+// Sender delivers emails. Choose the implementation with NewSender.
+type Sender interface {
+	Send(ctx context.Context, message Message) error
+}
+
+// Config selects and configures a Sender.
+type Config struct {
+	// Kind is "log" (the default) to print messages to the server log for local development,
+	// "file" to write each message as an .eml file into Dir, or "smtp" to send them through
+	// SMTPHost:SMTPPort, authenticating with SMTPUsername and SMTPPassword.
+	Kind string
+	From string
+	Dir  string
+
+	SMTPHost     string
+	SMTPPort     int
+	SMTPUsername string
+	SMTPPassword string
+}
+
+// NewSender returns the sender selected by config.Kind.
+func NewSender(config Config) (Sender, error) {
+	switch config.Kind {
+	case "", "log":
+		return LogSender{From: config.From}, nil
+	case "file":
+		return FileSender{From: config.From, Dir: config.Dir}, nil
+	case "smtp":
+		return SMTPSender{
+			From:     config.From,
+			Host:     config.SMTPHost,
+			Port:     strconv.Itoa(config.SMTPPort),
+			Username: config.SMTPUsername,
+			Password: config.SMTPPassword,
+		}, nil
+	default:
+		return nil, fmt.Errorf("Unknown mail sender %q, expected log, file or smtp", config.Kind)
+	}
+}
+
+// LogSender prints messages to the server log instead of delivering them.
+type LogSender struct {
+	From string
+}
+
+func (s LogSender) Send(ctx context.Context, message Message) error {
+	log.Printf("Mail from %s to %s\nSubject: %s\n\n%s\n", s.From, message.To, message.Subject, message.Body)
+	return nil
+}
+
+// FileSender writes every message as an .eml file into Dir instead of delivering it.
+type FileSender struct {
+	From string
+	Dir  string
+}
+
+func (s FileSender) Send(ctx context.Context, message Message) error {
+	if err := os.MkdirAll(s.Dir, 0o755); err != nil {
+		return err
+	}
+
+	name := fmt.Sprintf("%s-%s.eml", time.Now().UTC().Format("20060102T150405Z"), primitive.NewObjectID().Hex())
+	return os.WriteFile(filepath.Join(s.Dir, name), format(s.From, message), 0o600)
+}
+
+// SMTPSender delivers messages through an SMTP server using PLAIN authentication.
+type SMTPSender struct {
+	From     string
+	Host     string
+	Port     string
+	Username string
+	Password string
+}
+
+func (s SMTPSender) Send(ctx context.Context, message Message) error {
+	if s.Host == "" {
+		return fmt.Errorf("No SMTP host is set")
+	}
+
+	var auth smtp.Auth
+	if s.Username != "" {
+		auth = smtp.PlainAuth("", s.Username, s.Password, s.Host)
+	}
+
+	return smtp.SendMail(s.Host+":"+s.Port, auth, s.From, []string{message.To}, format(s.From, message))
+}
+
+// format renders message as an RFC 5322 email.
+func format(from string, message Message) []byte {
+	var email strings.Builder
+
+	fmt.Fprintf(&email, "From: %s\r\n", from)
+	fmt.Fprintf(&email, "To: %s\r\n", message.To)
+	fmt.Fprintf(&email, "Subject: %s\r\n", message.Subject)
+	fmt.Fprintf(&email, "Date: %s\r\n", time.Now().Format(time.RFC1123Z))
+	email.WriteString("MIME-Version: 1.0\r\n")
+	email.WriteString("Content-Type: text/plain; charset=utf-8\r\n\r\n")
+	email.WriteString(strings.ReplaceAll(message.Body, "\n", "\r\n"))
+
+	return []byte(email.String())
+}
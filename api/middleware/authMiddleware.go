@@ -1,37 +1,218 @@
 package middleware
 
 import (
+	"errors"
 	"fmt"
+	"log"
 	"net/http"
+	"strings"
 
 	helper "movie-api/api/resource/user/helpers"
 
 	"github.com/gin-gonic/gin"
 )
 
-func Authenticate() gin.HandlerFunc {
+// realm is advertised in the WWW-Authenticate challenge.
+const realm = "movie-api"
+
+// Auth authenticates and authorizes the requests of one API instance.
+type Auth struct {
+	Service *helper.Service
+
+	// UnverifiedPermissions are the permissions a user keeps until their email address
+	// is verified. Requests on a user's own resources are not restricted.
+	UnverifiedPermissions map[Permission]bool
+
+	Logger *log.Logger
+}
+
+func NewAuth(service *helper.Service, unverifiedPermissions map[Permission]bool, logger *log.Logger) *Auth {
+	return &Auth{Service: service, UnverifiedPermissions: unverifiedPermissions, Logger: logger}
+}
+
+// Authenticate accepts an access token sent as `Authorization: Bearer <jwt>`, or an
+// API key sent as a bearer token or in the X-API-Key header. The non-standard `token`
+// header is still read as a deprecated fallback. Tokens of admins who must first
+// enable two-factor authentication are refused with 403.
+func (a *Auth) Authenticate() gin.HandlerFunc {
+	return a.authenticate(false, true)
+}
+
+// AuthenticateForMFAEnrollment is Authenticate for the endpoints an admin needs to enable
+// two-factor authentication (and to log out), which also accept their restricted tokens.
+// These endpoints act on the session itself, so they do not accept API keys.
+func (a *Auth) AuthenticateForMFAEnrollment() gin.HandlerFunc {
+	return a.authenticate(true, false)
+}
+
+func (a *Auth) authenticate(allowMFAEnrollment, allowAPIKeys bool) gin.HandlerFunc {
 	return func(c *gin.Context) {
-		clientToken := c.Request.Header.Get("token")
-		if clientToken == "" {
-			msg := fmt.Sprintf("No Authorization header provider")
-			c.IndentedJSON(http.StatusBadRequest, gin.H{"message": msg})
-			c.Abort()
+		clientToken, ok := bearerToken(c)
+		if !ok {
+			abortUnauthorized(c, "", "No Authorization header provided")
 			return
 		}
 
-		claims, err := helper.ValidateToken(clientToken)
+		if helper.IsAPIKey(clientToken) {
+			if !allowAPIKeys {
+				abortUnauthorized(c, "invalid_token", "API keys cannot be used for this endpoint")
+				return
+			}
+			a.authenticateAPIKey(c, clientToken)
+			return
+		}
+
+		claims, err := a.Service.ValidateToken(clientToken)
 		if err != "" {
-			c.IndentedJSON(http.StatusBadRequest, gin.H{"message": err})
+			abortUnauthorized(c, "invalid_token", err)
+			return
+		}
+
+		revoked, revokedErr := a.Service.IsTokenRevoked(c.Request.Context(), claims)
+		if revokedErr != nil {
+			c.IndentedJSON(http.StatusInternalServerError, gin.H{"message": revokedErr.Error()})
 			c.Abort()
 			return
 		}
+		if revoked {
+			abortUnauthorized(c, "invalid_token", "Token has been revoked")
+			return
+		}
+
+		if claims.Mfa_enrollment_required && !allowMFAEnrollment {
+			AbortForbidden(c, "Two-factor authentication must be enabled for this account first.")
+			return
+		}
+
+		if claims.Session_id != "" {
+			if err := a.Service.TouchSession(c.Request.Context(), claims.Session_id, c.ClientIP()); err != nil {
+				a.Logger.Println("Error recording session activity: ", err)
+			}
+			c.Set("session_id", claims.Session_id)
+		}
 
+		c.Set("claims", claims)
 		c.Set("email_address", claims.Email_address)
 		c.Set("first_name", claims.First_name)
 		c.Set("last_name", claims.Last_name)
 		c.Set("user_type", claims.User_type)
 		c.Set("user_id", claims.User_id)
+		c.Set("email_verified", claims.Email_verified)
+
+		c.Next()
+	}
+}
+
+// authenticateAPIKey authenticates the request as the owner of the API key. The owner's
+// current profile is used, and the key's scopes further limit what the request may do.
+func (a *Auth) authenticateAPIKey(c *gin.Context, key string) {
+	apiKey, owner, err := a.Service.AuthenticateAPIKey(c.Request.Context(), key)
+	if errors.Is(err, helper.ErrInvalidAPIKey) {
+		abortUnauthorized(c, "invalid_token", err.Error())
+		return
+	}
+	if err != nil {
+		c.IndentedJSON(http.StatusInternalServerError, gin.H{"message": err.Error()})
+		c.Abort()
+		return
+	}
+
+	mfaEnrollmentRequired, err := a.Service.MFAEnrollmentRequired(c.Request.Context(), owner)
+	if err != nil {
+		c.IndentedJSON(http.StatusInternalServerError, gin.H{"message": err.Error()})
+		c.Abort()
+		return
+	}
+	if mfaEnrollmentRequired {
+		AbortForbidden(c, "Two-factor authentication must be enabled for this account first.")
+		return
+	}
+
+	scopes := map[Permission]bool{}
+	for _, scope := range apiKey.Scopes {
+		scopes[Permission(scope)] = true
+	}
+
+	c.Set("api_key_id", apiKey.Key_id)
+	c.Set("api_key_scopes", scopes)
+	c.Set("email_address", *owner.Email_address)
+	c.Set("first_name", stringValue(owner.First_name))
+	c.Set("last_name", stringValue(owner.Last_name))
+	c.Set("user_type", stringValue(owner.User_type))
+	c.Set("user_id", owner.User_id)
+	c.Set("email_verified", owner.IsEmailVerified())
+
+	c.Next()
+}
+
+// IsAPIKeyRequest reports whether the request was authenticated with an API key.
+func IsAPIKeyRequest(c *gin.Context) bool {
+	_, ok := c.Get("api_key_id")
+	return ok
+}
+
+// DenyAPIKeys refuses requests authenticated with an API key, for endpoints that
+// only a person should use. It must run after Authenticate.
+func DenyAPIKeys() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if IsAPIKeyRequest(c) {
+			AbortForbidden(c, "API keys cannot be used for this endpoint.")
+			return
+		}
 
 		c.Next()
 	}
 }
+
+// AbortForbidden stops the request with 403 for an authenticated caller
+// whose role does not allow the operation.
+func AbortForbidden(c *gin.Context, msg string) {
+	c.Header("WWW-Authenticate", fmt.Sprintf(`Bearer realm=%q, error="insufficient_scope"`, realm))
+	c.IndentedJSON(http.StatusForbidden, gin.H{"message": msg})
+	c.Abort()
+}
+
+// bearerToken extracts the token from the Authorization header, falling back to the
+// X-API-Key header and then the `token` header.
+func bearerToken(c *gin.Context) (string, bool) {
+	if authorization := c.GetHeader("Authorization"); authorization != "" {
+		scheme, token, found := strings.Cut(authorization, " ")
+		if !found || !strings.EqualFold(scheme, "Bearer") || strings.TrimSpace(token) == "" {
+			return "", false
+		}
+		return strings.TrimSpace(token), true
+	}
+
+	if apiKey := c.GetHeader("X-API-Key"); apiKey != "" {
+		return apiKey, true
+	}
+
+	if token := c.GetHeader("token"); token != "" {
+		// Deprecated: clients should move to the Authorization header
+		c.Header("Deprecation", "true")
+		c.Header("Warning", `299 - "The token header is deprecated, use Authorization: Bearer"`)
+		return token, true
+	}
+
+	return "", false
+}
+
+// abortUnauthorized stops the request with 401 and a Bearer challenge (RFC 6750).
+// errorCode is left out of the challenge when the request carried no credentials.
+func abortUnauthorized(c *gin.Context, errorCode, msg string) {
+	challenge := fmt.Sprintf("Bearer realm=%q", realm)
+	if errorCode != "" {
+		challenge += fmt.Sprintf(", error=%q, error_description=%q", errorCode, msg)
+	}
+
+	c.Header("WWW-Authenticate", challenge)
+	c.IndentedJSON(http.StatusUnauthorized, gin.H{"message": msg})
+	c.Abort()
+}
+
+func stringValue(value *string) string {
+	if value == nil {
+		return ""
+	}
+	return *value
+}
@@ -0,0 +1,59 @@
+package middleware
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+
+	"movie-api/api/config"
+
+	"github.com/gin-gonic/gin"
+)
+
+// CORS lets browsers on the allowed origins call the API and answers their preflight
+// requests. Requests from other origins pass through without CORS headers, so the
+// browser keeps their responses from the calling page.
+func CORS(settings config.CORS) gin.HandlerFunc {
+	allowAll := false
+	allowed := map[string]bool{}
+	for _, origin := range settings.AllowedOrigins {
+		if origin == "*" {
+			allowAll = true
+		}
+		allowed[strings.TrimSuffix(origin, "/")] = true
+	}
+
+	methods := strings.Join(settings.AllowedMethods, ", ")
+	headers := strings.Join(settings.AllowedHeaders, ", ")
+	maxAge := strconv.Itoa(int(settings.MaxAge.Seconds()))
+
+	return func(c *gin.Context) {
+		origin := c.GetHeader("Origin")
+		if origin == "" || (!allowAll && !allowed[origin]) {
+			c.Next()
+			return
+		}
+
+		header := c.Writer.Header()
+		if allowAll {
+			header.Set("Access-Control-Allow-Origin", "*")
+		} else {
+			header.Set("Access-Control-Allow-Origin", origin)
+			header.Add("Vary", "Origin")
+		}
+		if settings.AllowCredentials {
+			header.Set("Access-Control-Allow-Credentials", "true")
+		}
+
+		// Preflight requests never reach the handlers
+		if c.Request.Method == http.MethodOptions && c.GetHeader("Access-Control-Request-Method") != "" {
+			header.Set("Access-Control-Allow-Methods", methods)
+			header.Set("Access-Control-Allow-Headers", headers)
+			header.Set("Access-Control-Max-Age", maxAge)
+			c.AbortWithStatus(http.StatusNoContent)
+			return
+		}
+
+		c.Next()
+	}
+}
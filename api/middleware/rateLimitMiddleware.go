@@ -0,0 +1,90 @@
+package middleware
+
+import (
+	"math"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"movie-api/api/config"
+
+	"github.com/gin-gonic/gin"
+)
+
+// RateLimit allows each client IP address settings.RequestsPerMinute requests per minute
+// on average, in bursts of up to settings.Burst requests. Further requests are answered
+// with 429 and a Retry-After header. The counters are kept per instance.
+func RateLimit(settings config.RateLimit) gin.HandlerFunc {
+	limiter := &rateLimiter{
+		rate:    float64(settings.RequestsPerMinute) / 60,
+		burst:   float64(settings.Burst),
+		buckets: map[string]*bucket{},
+	}
+
+	return func(c *gin.Context) {
+		if wait := limiter.take(c.ClientIP(), time.Now()); wait > 0 {
+			c.Header("Retry-After", strconv.Itoa(int(math.Ceil(wait.Seconds()))))
+			c.IndentedJSON(http.StatusTooManyRequests, gin.H{"message": "Too many requests, please try again later"})
+			c.Abort()
+			return
+		}
+
+		c.Next()
+	}
+}
+
+// bucket holds the requests a client can still send. It refills at the limiter's rate.
+type bucket struct {
+	tokens  float64
+	updated time.Time
+}
+
+// rateLimiter is a token bucket per client.
+type rateLimiter struct {
+	mu        sync.Mutex
+	rate      float64 // tokens per second
+	burst     float64
+	buckets   map[string]*bucket
+	lastSweep time.Time
+}
+
+// take spends a token of the client's bucket. It returns 0 when the request may go
+// ahead, or how long the client has to wait for the next token.
+func (l *rateLimiter) take(client string, now time.Time) time.Duration {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	l.sweep(now)
+
+	b, ok := l.buckets[client]
+	if !ok {
+		b = &bucket{tokens: l.burst, updated: now}
+		l.buckets[client] = b
+	}
+
+	b.tokens = math.Min(l.burst, b.tokens+now.Sub(b.updated).Seconds()*l.rate)
+	b.updated = now
+
+	if b.tokens < 1 {
+		return time.Duration((1 - b.tokens) / l.rate * float64(time.Second))
+	}
+
+	b.tokens--
+	return 0
+}
+
+// sweep forgets, once a minute, the buckets that have refilled completely, as they
+// behave like new ones. This keeps the map from growing with every client ever seen.
+func (l *rateLimiter) sweep(now time.Time) {
+	if now.Sub(l.lastSweep) < time.Minute {
+		return
+	}
+	l.lastSweep = now
+
+	for client, b := range l.buckets {
+		if b.tokens+now.Sub(b.updated).Seconds()*l.rate >= l.burst {
+			delete(l.buckets, client)
+		}
+	}
+}
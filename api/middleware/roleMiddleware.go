@@ -0,0 +1,177 @@
+package middleware
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// User roles, stored in User.User_type and carried in the access token.
+const (
+	RoleAdmin string = "ADMIN"
+	RoleUser  string = "USER"
+)
+
+// Permission names an operation guarded by the policy table.
+type Permission string
+
+const (
+	PermissionReadMovies  Permission = "movies:read"
+	PermissionWriteMovies Permission = "movies:write" // create, update, delete and import
+	PermissionReadUsers   Permission = "users:read"   // any user's profile, not only one's own
+	PermissionListUsers   Permission = "users:list"
+	PermissionManageUsers Permission = "users:manage" // update, delete and restore any user, change roles
+
+	PermissionManageSettings Permission = "settings:manage"
+)
+
+// Policy lists the roles granted each permission. A permission missing from
+// the table is granted to nobody.
+var Policy = map[Permission][]string{
+	PermissionReadMovies:  {RoleAdmin, RoleUser},
+	PermissionWriteMovies: {RoleAdmin},
+	PermissionReadUsers:   {RoleAdmin},
+	PermissionListUsers:   {RoleAdmin},
+	PermissionManageUsers: {RoleAdmin},
+
+	PermissionManageSettings: {RoleAdmin},
+}
+
+// ParseUnverifiedPermissions reads the permissions a user keeps until their email address
+// is verified from a comma separated list. An empty value keeps the default, reading
+// movies, and "none" requires verification for all of them.
+func ParseUnverifiedPermissions(value string) (map[Permission]bool, error) {
+	if value == "" {
+		value = string(PermissionReadMovies)
+	}
+
+	permissions := map[Permission]bool{}
+	if value == "none" {
+		return permissions, nil
+	}
+
+	for _, name := range strings.Split(value, ",") {
+		permission := Permission(strings.TrimSpace(name))
+		if _, ok := Policy[permission]; !ok {
+			return nil, fmt.Errorf("Unknown permission %q in the unverified user permissions", permission)
+		}
+		permissions[permission] = true
+	}
+
+	return permissions, nil
+}
+
+// HasPermission reports whether role is granted permission by the policy table.
+func HasPermission(role string, permission Permission) bool {
+	return hasRole(role, Policy[permission])
+}
+
+// RequireRole lets the request through only when the authenticated user has one of roles.
+// Roles cannot be expressed as API key scopes, so API keys are refused.
+// It must run after Authenticate.
+func RequireRole(roles ...string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if IsAPIKeyRequest(c) || !hasRole(c.GetString("user_type"), roles) {
+			AbortForbidden(c, "Unauthorized to access this resource.")
+			return
+		}
+
+		c.Next()
+	}
+}
+
+// RequireSelfOrRole lets the request through when the path parameter param is the
+// authenticated user's own user_id, or when the user has one of roles. API keys are refused.
+// It must run after Authenticate.
+func RequireSelfOrRole(param string, roles ...string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if IsAPIKeyRequest(c) || (!isSelf(c, param) && !hasRole(c.GetString("user_type"), roles)) {
+			AbortForbidden(c, "Unauthorized to access this resource.")
+			return
+		}
+
+		c.Next()
+	}
+}
+
+// RequirePermission lets the request through only when the policy table grants the
+// authenticated user's role permission. Users with an unverified email address are
+// further limited to the UnverifiedPermissions of a, and API keys to their scopes.
+// It must run after Authenticate.
+func (a *Auth) RequirePermission(permission Permission) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if !a.checkPermission(c, permission) {
+			return
+		}
+
+		c.Next()
+	}
+}
+
+// RequireSelfOrPermission lets the request through when the path parameter param is the
+// authenticated user's own user_id, or as RequirePermission would. API keys only
+// get through with the permission in their scopes, even on their owner's resources.
+// It must run after Authenticate.
+func (a *Auth) RequireSelfOrPermission(param string, permission Permission) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if !isSelf(c, param) && !a.checkPermission(c, permission) {
+			return
+		}
+
+		c.Next()
+	}
+}
+
+// checkPermission aborts with 403 and returns false when the caller lacks permission.
+func (a *Auth) checkPermission(c *gin.Context, permission Permission) bool {
+	if reason := a.denial(c, permission); reason != "" {
+		AbortForbidden(c, reason)
+		return false
+	}
+
+	return true
+}
+
+// Allows reports whether the caller has permission, deciding as RequirePermission does
+// but without aborting, for handlers whose response depends on it.
+func (a *Auth) Allows(c *gin.Context, permission Permission) bool {
+	return a.denial(c, permission) == ""
+}
+
+// denial explains why the caller lacks permission, or returns "" when they have it.
+func (a *Auth) denial(c *gin.Context, permission Permission) string {
+	if !HasPermission(c.GetString("user_type"), permission) {
+		return "Unauthorized to access this resource."
+	}
+
+	if !c.GetBool("email_verified") && !a.UnverifiedPermissions[permission] {
+		return "Please verify your email address to access this resource."
+	}
+
+	if scopes, ok := c.Get("api_key_scopes"); ok && !scopes.(map[Permission]bool)[permission] {
+		return fmt.Sprintf("The API key does not have the %s scope.", permission)
+	}
+
+	return ""
+}
+
+// isSelf reports whether the path parameter param is the user_id of the person
+// making the request. Requests made with API keys never count as the owner's own.
+func isSelf(c *gin.Context, param string) bool {
+	return !IsAPIKeyRequest(c) && c.Param(param) == c.GetString("user_id")
+}
+
+func hasRole(role string, roles []string) bool {
+	if role == "" {
+		return false
+	}
+
+	for _, allowed := range roles {
+		if role == allowed {
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,264 @@
+// Package mockprovider is a minimal OpenID Connect provider for local development
+// and tests of the login flow. It signs ID tokens with a key generated at startup,
+// accepts any client and logs in whoever the user claims to be.
+package mockprovider
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"html/template"
+	"math/big"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	jwt "github.com/golang-jwt/jwt/v5"
+)
+
+const (
+	keyID            string        = "mock-key-1"
+	codeLifetime     time.Duration = time.Minute
+	idTokenLifetime  time.Duration = time.Hour
+	defaultGivenName string        = "Mock"
+)
+
+// Provider serves the discovery document, JWKS, authorization and token endpoints.
+type Provider struct {
+	Issuer string
+
+	key   *rsa.PrivateKey
+	mu    sync.Mutex
+	codes map[string]authorization
+}
+
+// authorization is an issued authorization code waiting to be redeemed.
+type authorization struct {
+	clientID      string
+	redirectURI   string
+	codeChallenge string
+	nonce         string
+	email         string
+	givenName     string
+	familyName    string
+	expiresAt     time.Time
+}
+
+// New returns a provider whose issuer identifier, and base URL, is issuer.
+func New(issuer string) (*Provider, error) {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Provider{
+		Issuer: strings.TrimSuffix(issuer, "/"),
+		key:    key,
+		codes:  map[string]authorization{},
+	}, nil
+}
+
+func (provider *Provider) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	switch r.URL.Path {
+	case "/.well-known/openid-configuration":
+		provider.discovery(w)
+	case "/jwks":
+		provider.jwks(w)
+	case "/authorize":
+		provider.authorize(w, r)
+	case "/token":
+		provider.token(w, r)
+	default:
+		http.NotFound(w, r)
+	}
+}
+
+func (provider *Provider) discovery(w http.ResponseWriter) {
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"issuer":                                provider.Issuer,
+		"authorization_endpoint":                provider.Issuer + "/authorize",
+		"token_endpoint":                        provider.Issuer + "/token",
+		"jwks_uri":                              provider.Issuer + "/jwks",
+		"response_types_supported":              []string{"code"},
+		"subject_types_supported":               []string{"public"},
+		"id_token_signing_alg_values_supported": []string{"RS256"},
+		"code_challenge_methods_supported":      []string{"S256"},
+		"scopes_supported":                      []string{"openid", "email", "profile"},
+	})
+}
+
+func (provider *Provider) jwks(w http.ResponseWriter) {
+	publicKey := provider.key.PublicKey
+
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"keys": []map[string]string{{
+			"kty": "RSA",
+			"kid": keyID,
+			"use": "sig",
+			"alg": "RS256",
+			"n":   base64.RawURLEncoding.EncodeToString(publicKey.N.Bytes()),
+			"e":   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(publicKey.E)).Bytes()),
+		}},
+	})
+}
+
+var loginPage = template.Must(template.New("login").Parse(`<!DOCTYPE html>
+<title>Mock OpenID Connect provider</title>
+<form method="get" action="/authorize">
+{{range $name, $values := .}}{{range $values}}<input type="hidden" name="{{$name}}" value="{{.}}">
+{{end}}{{end}}<p><label>Email <input name="email" type="email" required></label></p>
+<p><label>First name <input name="given_name"></label></p>
+<p><label>Last name <input name="family_name"></label></p>
+<p><button type="submit">Log in</button></p>
+</form>
+`))
+
+// authorize shows a login form, or logs in straight away when the request names
+// the user with an email parameter, as automated tests do.
+func (provider *Provider) authorize(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+
+	redirectURI, err := url.Parse(query.Get("redirect_uri"))
+	if err != nil || !redirectURI.IsAbs() {
+		http.Error(w, "invalid redirect_uri", http.StatusBadRequest)
+		return
+	}
+	if query.Get("response_type") != "code" || query.Get("client_id") == "" ||
+		query.Get("code_challenge") == "" || query.Get("code_challenge_method") != "S256" {
+		http.Error(w, "expected response_type=code, a client_id and an S256 code_challenge", http.StatusBadRequest)
+		return
+	}
+
+	email := query.Get("email")
+	if email == "" {
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		loginPage.Execute(w, query)
+		return
+	}
+
+	givenName := query.Get("given_name")
+	if givenName == "" {
+		givenName = defaultGivenName
+	}
+	familyName := query.Get("family_name")
+	if familyName == "" {
+		familyName, _, _ = strings.Cut(email, "@")
+	}
+
+	code := randomString()
+	provider.mu.Lock()
+	provider.codes[code] = authorization{
+		clientID:      query.Get("client_id"),
+		redirectURI:   redirectURI.String(),
+		codeChallenge: query.Get("code_challenge"),
+		nonce:         query.Get("nonce"),
+		email:         email,
+		givenName:     givenName,
+		familyName:    familyName,
+		expiresAt:     time.Now().Add(codeLifetime),
+	}
+	provider.mu.Unlock()
+
+	callback := redirectURI.Query()
+	callback.Set("code", code)
+	callback.Set("state", query.Get("state"))
+	redirectURI.RawQuery = callback.Encode()
+
+	http.Redirect(w, r, redirectURI.String(), http.StatusFound)
+}
+
+func (provider *Provider) token(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	if err := r.ParseForm(); err != nil {
+		tokenError(w, "invalid_request", err.Error())
+		return
+	}
+
+	clientID := r.PostForm.Get("client_id")
+	if username, _, ok := r.BasicAuth(); ok {
+		clientID, _ = url.QueryUnescape(username)
+	}
+
+	// Codes are single use, whether or not the exchange succeeds
+	code := r.PostForm.Get("code")
+	provider.mu.Lock()
+	grant, ok := provider.codes[code]
+	delete(provider.codes, code)
+	provider.mu.Unlock()
+
+	switch {
+	case r.PostForm.Get("grant_type") != "authorization_code":
+		tokenError(w, "unsupported_grant_type", "only authorization_code is supported")
+		return
+	case !ok || time.Now().After(grant.expiresAt):
+		tokenError(w, "invalid_grant", "unknown or expired code")
+		return
+	case grant.clientID != clientID || grant.redirectURI != r.PostForm.Get("redirect_uri"):
+		tokenError(w, "invalid_grant", "client_id or redirect_uri does not match the authorization request")
+		return
+	case codeChallenge(r.PostForm.Get("code_verifier")) != grant.codeChallenge:
+		tokenError(w, "invalid_grant", "code_verifier does not match the code_challenge")
+		return
+	}
+
+	now := time.Now()
+	subject := sha256.Sum256([]byte(strings.ToLower(grant.email)))
+	claims := jwt.MapClaims{
+		"iss":            provider.Issuer,
+		"sub":            hex.EncodeToString(subject[:8]),
+		"aud":            grant.clientID,
+		"iat":            now.Unix(),
+		"exp":            now.Add(idTokenLifetime).Unix(),
+		"nonce":          grant.nonce,
+		"email":          grant.email,
+		"email_verified": true,
+		"name":           grant.givenName + " " + grant.familyName,
+		"given_name":     grant.givenName,
+		"family_name":    grant.familyName,
+	}
+
+	idToken := jwt.NewWithClaims(jwt.SigningMethodRS256, claims)
+	idToken.Header["kid"] = keyID
+	signed, err := idToken.SignedString(provider.key)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"access_token": randomString(),
+		"token_type":   "Bearer",
+		"expires_in":   int(idTokenLifetime.Seconds()),
+		"id_token":     signed,
+	})
+}
+
+func tokenError(w http.ResponseWriter, code, description string) {
+	writeJSON(w, http.StatusBadRequest, map[string]string{"error": code, "error_description": description})
+}
+
+func writeJSON(w http.ResponseWriter, status int, value interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(value)
+}
+
+func codeChallenge(verifier string) string {
+	sum := sha256.Sum256([]byte(verifier))
+	return base64.RawURLEncoding.EncodeToString(sum[:])
+}
+
+func randomString() string {
+	buffer := make([]byte, 24)
+	rand.Read(buffer)
+	return base64.RawURLEncoding.EncodeToString(buffer)
+}
@@ -0,0 +1,364 @@
+package oidc
+
+import (
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math/big"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	jwt "github.com/golang-jwt/jwt/v5"
+)
+
+// Config identifies the provider and this application as its client.
+type Config struct {
+	Issuer       string
+	ClientID     string
+	ClientSecret string // empty for public clients, which rely on PKCE alone
+	RedirectURL  string
+	Scopes       []string
+}
+
+// Discovery is the part of the provider's /.well-known/openid-configuration document the login flow needs.
+type Discovery struct {
+	Issuer                 string `json:"issuer"`
+	Authorization_endpoint string `json:"authorization_endpoint"`
+	Token_endpoint         string `json:"token_endpoint"`
+	Jwks_uri               string `json:"jwks_uri"`
+	Userinfo_endpoint      string `json:"userinfo_endpoint,omitempty"`
+}
+
+// Claims are the ID token claims used to find or create the user.
+type Claims struct {
+	Email          string `json:"email"`
+	Email_verified bool   `json:"email_verified"`
+	Name           string `json:"name"`
+	Given_name     string `json:"given_name"`
+	Family_name    string `json:"family_name"`
+	Picture        string `json:"picture"`
+	Nonce          string `json:"nonce"`
+	jwt.RegisteredClaims
+}
+
+// ErrProvider wraps errors reported by, or about talking to, the provider.
+var ErrProvider = errors.New("OpenID Connect provider error")
+
+// keyRefreshInterval limits how often an unknown key ID makes the client fetch the JWKS again.
+const keyRefreshInterval time.Duration = time.Minute
+
+// Client runs the authorization code flow against one provider. The discovery document
+// and the provider's signing keys are fetched on first use and cached.
+type Client struct {
+	Config     Config
+	HTTPClient *http.Client
+
+	mu            sync.Mutex
+	discovery     *Discovery
+	keys          map[string]crypto.PublicKey
+	keysFetchedAt time.Time
+}
+
+func New(config Config) *Client {
+	if len(config.Scopes) == 0 {
+		config.Scopes = []string{"openid", "email", "profile"}
+	}
+
+	return &Client{Config: config, HTTPClient: &http.Client{Timeout: 10 * time.Second}}
+}
+
+// AuthCodeURL returns the provider URL the user is sent to in order to log in.
+// codeChallenge is the S256 PKCE challenge of the verifier later passed to Exchange.
+func (client *Client) AuthCodeURL(ctx context.Context, state, nonce, codeChallenge string) (string, error) {
+	discovery, err := client.Discover(ctx)
+	if err != nil {
+		return "", err
+	}
+
+	query := url.Values{}
+	query.Set("response_type", "code")
+	query.Set("client_id", client.Config.ClientID)
+	query.Set("redirect_uri", client.Config.RedirectURL)
+	query.Set("scope", strings.Join(client.Config.Scopes, " "))
+	query.Set("state", state)
+	query.Set("nonce", nonce)
+	query.Set("code_challenge", codeChallenge)
+	query.Set("code_challenge_method", "S256")
+
+	separator := "?"
+	if strings.Contains(discovery.Authorization_endpoint, "?") {
+		separator = "&"
+	}
+
+	return discovery.Authorization_endpoint + separator + query.Encode(), nil
+}
+
+// Exchange redeems the authorization code at the token endpoint and returns the
+// verified claims of the ID token, which must carry nonce.
+func (client *Client) Exchange(ctx context.Context, code, codeVerifier, nonce string) (*Claims, error) {
+	discovery, err := client.Discover(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	form := url.Values{}
+	form.Set("grant_type", "authorization_code")
+	form.Set("code", code)
+	form.Set("redirect_uri", client.Config.RedirectURL)
+	form.Set("code_verifier", codeVerifier)
+	form.Set("client_id", client.Config.ClientID)
+
+	request, err := http.NewRequestWithContext(ctx, http.MethodPost, discovery.Token_endpoint, strings.NewReader(form.Encode()))
+	if err != nil {
+		return nil, err
+	}
+	request.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	request.Header.Set("Accept", "application/json")
+	if client.Config.ClientSecret != "" {
+		// client_secret_basic, the default token endpoint authentication method
+		request.SetBasicAuth(url.QueryEscape(client.Config.ClientID), url.QueryEscape(client.Config.ClientSecret))
+	}
+
+	var response struct {
+		Id_token          string `json:"id_token"`
+		Error             string `json:"error"`
+		Error_description string `json:"error_description"`
+	}
+	status, err := client.doJSON(request, &response)
+	if err != nil {
+		return nil, err
+	}
+	if status != http.StatusOK || response.Error != "" {
+		return nil, fmt.Errorf("%w: token endpoint answered %d %s %s", ErrProvider, status, response.Error, response.Error_description)
+	}
+	if response.Id_token == "" {
+		return nil, fmt.Errorf("%w: token response has no id_token", ErrProvider)
+	}
+
+	return client.VerifyIDToken(ctx, response.Id_token, nonce)
+}
+
+// VerifyIDToken checks the signature, issuer, audience, expiry and nonce of an ID token.
+func (client *Client) VerifyIDToken(ctx context.Context, rawIDToken, nonce string) (*Claims, error) {
+	discovery, err := client.Discover(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	token, err := jwt.ParseWithClaims(
+		rawIDToken,
+		&Claims{},
+		func(token *jwt.Token) (interface{}, error) {
+			kid, _ := token.Header["kid"].(string)
+			return client.key(ctx, kid)
+		},
+		jwt.WithValidMethods([]string{"RS256", "RS384", "RS512", "ES256", "ES384", "ES512"}),
+		jwt.WithIssuer(discovery.Issuer),
+		jwt.WithAudience(client.Config.ClientID),
+		jwt.WithExpirationRequired(),
+		jwt.WithLeeway(time.Minute),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("%w: invalid ID token: %v", ErrProvider, err)
+	}
+
+	claims, ok := token.Claims.(*Claims)
+	if !ok || !token.Valid {
+		return nil, fmt.Errorf("%w: invalid ID token", ErrProvider)
+	}
+	if claims.Nonce != nonce {
+		return nil, fmt.Errorf("%w: ID token nonce does not match", ErrProvider)
+	}
+	if claims.Subject == "" {
+		return nil, fmt.Errorf("%w: ID token has no subject", ErrProvider)
+	}
+
+	return claims, nil
+}
+
+// Discover returns the provider's discovery document, fetching it on first use.
+func (client *Client) Discover(ctx context.Context) (*Discovery, error) {
+	client.mu.Lock()
+	defer client.mu.Unlock()
+
+	if client.discovery != nil {
+		return client.discovery, nil
+	}
+
+	wellKnown := strings.TrimSuffix(client.Config.Issuer, "/") + "/.well-known/openid-configuration"
+	request, err := http.NewRequestWithContext(ctx, http.MethodGet, wellKnown, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	var discovery Discovery
+	status, err := client.doJSON(request, &discovery)
+	if err != nil {
+		return nil, err
+	}
+	if status != http.StatusOK {
+		return nil, fmt.Errorf("%w: discovery answered %d", ErrProvider, status)
+	}
+
+	// OpenID Connect Discovery 1.0 section 4.3
+	if discovery.Issuer != client.Config.Issuer {
+		return nil, fmt.Errorf("%w: discovery issuer %q does not match %q", ErrProvider, discovery.Issuer, client.Config.Issuer)
+	}
+	if discovery.Authorization_endpoint == "" || discovery.Token_endpoint == "" || discovery.Jwks_uri == "" {
+		return nil, fmt.Errorf("%w: discovery document is incomplete", ErrProvider)
+	}
+
+	client.discovery = &discovery
+	return client.discovery, nil
+}
+
+// key returns the provider's signing key kid, fetching the JWKS again when the key is
+// unknown, which is how providers roll their keys.
+func (client *Client) key(ctx context.Context, kid string) (crypto.PublicKey, error) {
+	client.mu.Lock()
+	defer client.mu.Unlock()
+
+	if key, ok := client.lookupKey(kid); ok {
+		return key, nil
+	}
+	if time.Since(client.keysFetchedAt) < keyRefreshInterval {
+		return nil, fmt.Errorf("unknown signing key %q", kid)
+	}
+
+	request, err := http.NewRequestWithContext(ctx, http.MethodGet, client.discovery.Jwks_uri, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	var set jsonWebKeySet
+	status, err := client.doJSON(request, &set)
+	if err != nil {
+		return nil, err
+	}
+	if status != http.StatusOK {
+		return nil, fmt.Errorf("%w: JWKS answered %d", ErrProvider, status)
+	}
+
+	keys := map[string]crypto.PublicKey{}
+	for _, jwk := range set.Keys {
+		if jwk.Use != "" && jwk.Use != "sig" {
+			continue
+		}
+		if key, err := jwk.publicKey(); err == nil {
+			keys[jwk.Kid] = key
+		}
+	}
+	client.keys = keys
+	client.keysFetchedAt = time.Now()
+
+	if key, ok := client.lookupKey(kid); ok {
+		return key, nil
+	}
+	return nil, fmt.Errorf("unknown signing key %q", kid)
+}
+
+// lookupKey finds kid among the cached keys. A token without kid is accepted when the
+// provider has a single key.
+func (client *Client) lookupKey(kid string) (crypto.PublicKey, bool) {
+	if kid == "" && len(client.keys) == 1 {
+		for _, key := range client.keys {
+			return key, true
+		}
+	}
+
+	key, ok := client.keys[kid]
+	return key, ok
+}
+
+func (client *Client) doJSON(request *http.Request, target interface{}) (status int, err error) {
+	response, err := client.HTTPClient.Do(request)
+	if err != nil {
+		return 0, fmt.Errorf("%w: %v", ErrProvider, err)
+	}
+	defer response.Body.Close()
+
+	body, err := io.ReadAll(io.LimitReader(response.Body, 1<<20))
+	if err != nil {
+		return 0, fmt.Errorf("%w: %v", ErrProvider, err)
+	}
+
+	if err := json.Unmarshal(body, target); err != nil && response.StatusCode == http.StatusOK {
+		return 0, fmt.Errorf("%w: invalid JSON from %s: %v", ErrProvider, request.URL.Path, err)
+	}
+
+	return response.StatusCode, nil
+}
+
+type jsonWebKeySet struct {
+	Keys []jsonWebKey `json:"keys"`
+}
+
+// jsonWebKey is an RSA or EC public key in JWK form (RFC 7517, RFC 7518 section 6).
+type jsonWebKey struct {
+	Kty string `json:"kty"`
+	Kid string `json:"kid"`
+	Use string `json:"use"`
+	N   string `json:"n"`
+	E   string `json:"e"`
+	Crv string `json:"crv"`
+	X   string `json:"x"`
+	Y   string `json:"y"`
+}
+
+func (jwk jsonWebKey) publicKey() (crypto.PublicKey, error) {
+	switch jwk.Kty {
+	case "RSA":
+		n, err := decodeBigInt(jwk.N)
+		if err != nil {
+			return nil, err
+		}
+		e, err := decodeBigInt(jwk.E)
+		if err != nil {
+			return nil, err
+		}
+		return &rsa.PublicKey{N: n, E: int(e.Int64())}, nil
+
+	case "EC":
+		var curve elliptic.Curve
+		switch jwk.Crv {
+		case "P-256":
+			curve = elliptic.P256()
+		case "P-384":
+			curve = elliptic.P384()
+		case "P-521":
+			curve = elliptic.P521()
+		default:
+			return nil, fmt.Errorf("unsupported curve %q", jwk.Crv)
+		}
+		x, err := decodeBigInt(jwk.X)
+		if err != nil {
+			return nil, err
+		}
+		y, err := decodeBigInt(jwk.Y)
+		if err != nil {
+			return nil, err
+		}
+		return &ecdsa.PublicKey{Curve: curve, X: x, Y: y}, nil
+
+	default:
+		return nil, fmt.Errorf("unsupported key type %q", jwk.Kty)
+	}
+}
+
+func decodeBigInt(value string) (*big.Int, error) {
+	bytes, err := base64.RawURLEncoding.DecodeString(value)
+	if err != nil {
+		return nil, err
+	}
+	return new(big.Int).SetBytes(bytes), nil
+}
@@ -0,0 +1,159 @@
+package oidc
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+
+	"movie-api/api/oidc/mockprovider"
+
+	jwt "github.com/golang-jwt/jwt/v5"
+)
+
+const redirectURL = "http://localhost/auth/oidc/callback"
+
+// startProvider serves a mock provider and returns a client of it.
+func startProvider(t *testing.T) *Client {
+	t.Helper()
+
+	var provider *mockprovider.Provider
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		provider.ServeHTTP(w, r)
+	}))
+	t.Cleanup(server.Close)
+
+	provider, err := mockprovider.New(server.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return New(Config{Issuer: provider.Issuer, ClientID: "movie-api", RedirectURL: redirectURL})
+}
+
+// authorize logs email in at the provider and returns the redirect back to the client.
+func authorize(t *testing.T, client *Client, state, nonce, codeVerifier, email string) url.Values {
+	t.Helper()
+
+	authURL, err := client.AuthCodeURL(context.Background(), state, nonce, CodeChallengeS256(codeVerifier))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	noRedirects := &http.Client{CheckRedirect: func(*http.Request, []*http.Request) error {
+		return http.ErrUseLastResponse
+	}}
+	response, err := noRedirects.Get(authURL + "&email=" + url.QueryEscape(email))
+	if err != nil {
+		t.Fatal(err)
+	}
+	response.Body.Close()
+
+	location, err := response.Location()
+	if err != nil {
+		t.Fatalf("authorization answered %d without a redirect", response.StatusCode)
+	}
+	if got := location.Scheme + "://" + location.Host + location.Path; got != redirectURL {
+		t.Fatalf("redirected to %s, want %s", got, redirectURL)
+	}
+
+	return location.Query()
+}
+
+func TestLoginFlow(t *testing.T) {
+	client := startProvider(t)
+
+	callback := authorize(t, client, "state", "nonce", "verifier", "carol@example.com")
+	if callback.Get("state") != "state" || callback.Get("code") == "" {
+		t.Fatalf("callback = %v, want the state and a code", callback)
+	}
+
+	claims, err := client.Exchange(context.Background(), callback.Get("code"), "verifier", "nonce")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if claims.Email != "carol@example.com" || !claims.Email_verified || claims.Subject == "" || claims.Family_name != "carol" {
+		t.Errorf("claims = %+v, want carol's verified address", claims)
+	}
+}
+
+func TestExchangeRejects(t *testing.T) {
+	tests := []struct {
+		name         string
+		codeVerifier string
+		nonce        string
+	}{
+		{"another code verifier", "other verifier", "nonce"},
+		{"another nonce", "verifier", "other nonce"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			client := startProvider(t)
+			callback := authorize(t, client, "state", "nonce", "verifier", "carol@example.com")
+
+			_, err := client.Exchange(context.Background(), callback.Get("code"), tt.codeVerifier, tt.nonce)
+			if !errors.Is(err, ErrProvider) {
+				t.Errorf("err = %v, want ErrProvider", err)
+			}
+		})
+	}
+}
+
+func TestExchangeRejectsReusedCode(t *testing.T) {
+	client := startProvider(t)
+	callback := authorize(t, client, "state", "nonce", "verifier", "carol@example.com")
+
+	if _, err := client.Exchange(context.Background(), callback.Get("code"), "verifier", "nonce"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := client.Exchange(context.Background(), callback.Get("code"), "verifier", "nonce"); !errors.Is(err, ErrProvider) {
+		t.Errorf("err = %v, want ErrProvider", err)
+	}
+}
+
+func TestDiscoverRejectsAnotherIssuer(t *testing.T) {
+	client := startProvider(t)
+	client.Config.Issuer += "/"
+
+	if _, err := client.Discover(context.Background()); !errors.Is(err, ErrProvider) {
+		t.Errorf("err = %v, want ErrProvider", err)
+	}
+}
+
+func TestVerifyIDTokenRejectsForeignSignatures(t *testing.T) {
+	client := startProvider(t)
+	discovery, err := client.Discover(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	claims := jwt.MapClaims{
+		"iss":   discovery.Issuer,
+		"sub":   "mallory",
+		"aud":   client.Config.ClientID,
+		"exp":   time.Now().Add(time.Hour).Unix(),
+		"nonce": "nonce",
+		"email": "carol@example.com",
+	}
+	token := jwt.NewWithClaims(jwt.SigningMethodRS256, claims)
+	token.Header["kid"] = "mock-key-1"
+	signed, err := token.SignedString(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := client.VerifyIDToken(context.Background(), signed, "nonce"); !errors.Is(err, ErrProvider) {
+		t.Errorf("err = %v, want ErrProvider", err)
+	}
+}
@@ -0,0 +1,139 @@
+package pagination
+
+import (
+	"fmt"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	DefaultLimit int = 20  // page size used when the client does not send `limit`
+	MaxLimit     int = 100 // largest page size a client may request
+)
+
+// Params holds the requested page (1-based) and page size.
+type Params struct {
+	Page  int
+	Limit int
+}
+
+// Skip returns the number of items preceding the requested page.
+func (p Params) Skip() int64 {
+	return int64(p.Page-1) * int64(p.Limit)
+}
+
+// SortField is one key of a sort order.
+type SortField struct {
+	Field      string
+	Descending bool
+}
+
+// Links point at the current, next and previous pages of a listing.
+type Links struct {
+	Self string `json:"self"`
+	Next string `json:"next,omitempty"`
+	Prev string `json:"prev,omitempty"`
+}
+
+// Page is the response envelope of a paginated listing.
+type Page[T any] struct {
+	Data        []T   `json:"data"`
+	Page        int   `json:"page"`
+	Limit       int   `json:"limit"`
+	Total       int64 `json:"total"`
+	Total_pages int   `json:"total_pages"`
+	Links       Links `json:"links"`
+}
+
+// ParseParams reads the `page` and `limit` query parameters.
+func ParseParams(c *gin.Context) (Params, error) {
+	params := Params{Page: 1, Limit: DefaultLimit}
+
+	if value := c.Query("page"); value != "" {
+		page, err := strconv.Atoi(value)
+		if err != nil || page < 1 {
+			return params, fmt.Errorf("page must be a positive integer")
+		}
+		params.Page = page
+	}
+
+	if value := c.Query("limit"); value != "" {
+		limit, err := strconv.Atoi(value)
+		if err != nil || limit < 1 || limit > MaxLimit {
+			return params, fmt.Errorf("limit must be between 1 and %d", MaxLimit)
+		}
+		params.Limit = limit
+	}
+
+	return params, nil
+}
+
+// ParseSort reads a comma separated sort expression such as "-popularity,release_date",
+// where a leading "-" means descending. Only the keys of allowed are accepted; they map
+// the public names onto stored field names. defaultSort is used when value is empty.
+func ParseSort(value, defaultSort string, allowed map[string]string) ([]SortField, error) {
+	if strings.TrimSpace(value) == "" {
+		value = defaultSort
+	}
+
+	var fields []SortField
+	for _, key := range strings.Split(value, ",") {
+		key = strings.TrimSpace(key)
+		descending := strings.HasPrefix(key, "-")
+		key = strings.TrimPrefix(key, "-")
+
+		field, ok := allowed[key]
+		if !ok {
+			return nil, fmt.Errorf("cannot sort by %q", key)
+		}
+
+		fields = append(fields, SortField{Field: field, Descending: descending})
+	}
+
+	return fields, nil
+}
+
+// NewPage builds the response envelope for one page of items out of total,
+// with links that keep the request's other query parameters.
+func NewPage[T any](c *gin.Context, items []T, params Params, total int64) Page[T] {
+	if items == nil {
+		items = []T{}
+	}
+
+	totalPages := int((total + int64(params.Limit) - 1) / int64(params.Limit))
+
+	page := Page[T]{
+		Data:        items,
+		Page:        params.Page,
+		Limit:       params.Limit,
+		Total:       total,
+		Total_pages: totalPages,
+		Links:       Links{Self: pageURL(c.Request.URL, params.Page)},
+	}
+
+	if params.Page < totalPages {
+		page.Links.Next = pageURL(c.Request.URL, params.Page+1)
+	}
+	if params.Page > 1 {
+		// A page past the end links back to the last page that has items
+		prev := params.Page - 1
+		if prev > totalPages {
+			prev = totalPages
+		}
+		if prev >= 1 {
+			page.Links.Prev = pageURL(c.Request.URL, prev)
+		}
+	}
+
+	return page
+}
+
+func pageURL(requestURL *url.URL, page int) string {
+	query := requestURL.Query()
+	query.Set("page", strconv.Itoa(page))
+
+	return requestURL.Path + "?" + query.Encode()
+}
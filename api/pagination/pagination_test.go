@@ -0,0 +1,117 @@
+package pagination
+
+import (
+	"net/http/httptest"
+	"reflect"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+func newContext(target string) *gin.Context {
+	c, _ := gin.CreateTestContext(httptest.NewRecorder())
+	c.Request = httptest.NewRequest("GET", target, nil)
+	return c
+}
+
+func TestParseParams(t *testing.T) {
+	tests := []struct {
+		query   string
+		want    Params
+		wantErr bool
+	}{
+		{"", Params{Page: 1, Limit: DefaultLimit}, false},
+		{"?page=3&limit=10", Params{Page: 3, Limit: 10}, false},
+		{"?limit=100", Params{Page: 1, Limit: MaxLimit}, false},
+		{"?page=0", Params{}, true},
+		{"?page=-1", Params{}, true},
+		{"?page=two", Params{}, true},
+		{"?limit=0", Params{}, true},
+		{"?limit=101", Params{}, true},
+		{"?limit=ten", Params{}, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.query, func(t *testing.T) {
+			got, err := ParseParams(newContext("/movies" + tt.query))
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("err = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !tt.wantErr && got != tt.want {
+				t.Errorf("got %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSkip(t *testing.T) {
+	if got := (Params{Page: 3, Limit: 20}).Skip(); got != 40 {
+		t.Errorf("Skip = %d, want 40", got)
+	}
+}
+
+func TestParseSort(t *testing.T) {
+	allowed := map[string]string{"popularity": "popularity", "released": "release_date"}
+
+	tests := []struct {
+		value   string
+		want    []SortField
+		wantErr bool
+	}{
+		{"", []SortField{{Field: "popularity", Descending: true}}, false},
+		{"  ", []SortField{{Field: "popularity", Descending: true}}, false},
+		{"released", []SortField{{Field: "release_date"}}, false},
+		{"-released, popularity", []SortField{{Field: "release_date", Descending: true}, {Field: "popularity"}}, false},
+		{"title", nil, true},
+		{"popularity,", nil, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.value, func(t *testing.T) {
+			got, err := ParseSort(tt.value, "-popularity", allowed)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("err = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("got %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestNewPageLinks(t *testing.T) {
+	tests := []struct {
+		name     string
+		target   string
+		params   Params
+		total    int64
+		wantNext string
+		wantPrev string
+	}{
+		{"first page", "/movies?genre=18", Params{Page: 1, Limit: 10}, 25, "/movies?genre=18&page=2", ""},
+		{"middle page", "/movies?page=2", Params{Page: 2, Limit: 10}, 25, "/movies?page=3", "/movies?page=1"},
+		{"last page", "/movies?page=3", Params{Page: 3, Limit: 10}, 25, "", "/movies?page=2"},
+		{"past the end", "/movies?page=9", Params{Page: 9, Limit: 10}, 25, "", "/movies?page=3"},
+		{"no items", "/movies?page=2", Params{Page: 2, Limit: 10}, 0, "", ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			page := NewPage[int](newContext(tt.target), nil, tt.params, tt.total)
+
+			if page.Links.Next != tt.wantNext || page.Links.Prev != tt.wantPrev {
+				t.Errorf("links = %+v, want next %q and prev %q", page.Links, tt.wantNext, tt.wantPrev)
+			}
+			if page.Data == nil {
+				t.Error("Data is nil, want an empty list")
+			}
+		})
+	}
+}
+
+func TestNewPageTotalPages(t *testing.T) {
+	page := NewPage(newContext("/movies"), []int{1, 2}, Params{Page: 1, Limit: 2}, 5)
+	if page.Total_pages != 3 || page.Total != 5 {
+		t.Errorf("total pages = %d, total = %d, want 3 and 5", page.Total_pages, page.Total)
+	}
+}
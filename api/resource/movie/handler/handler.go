@@ -1,27 +1,112 @@
 package handler
 
 import (
+	"errors"
+	"io"
 	"net/http"
+	"strings"
 
+	"movie-api/api/pagination"
 	helper "movie-api/api/resource/movie/helpers"
+	"movie-api/api/resource/movie/importer"
 	models "movie-api/api/resource/movie/model"
+	"movie-api/api/resource/movie/repository"
+	"movie-api/api/resource/movie/search"
 
 	"github.com/gin-gonic/gin"
+	"github.com/go-playground/validator/v10"
 )
 
-// GetMovies responds with the list of all movies as JSON.
-func GetMovies() gin.HandlerFunc {
+// Handler serves the movie endpoints of one API instance.
+type Handler struct {
+	Movies repository.Repository
+
+	// Use a single instance of Validate, it caches struct info
+	Validate *validator.Validate
+}
+
+func New(movies repository.Repository, validate *validator.Validate) *Handler {
+	return &Handler{Movies: movies, Validate: validate}
+}
+
+// maxImportSize caps the size of an uploaded TMDB import.
+const maxImportSize int64 = 64 << 20
+
+// GetMovies responds with one page of the movies matching the query filters as JSON.
+//
+// Query parameters: page, limit, sort (popularity, vote_average, vote_count or
+// release_date, prefixed with "-" for descending order, comma separated),
+// genre, original_language, adult, status, release_year_from and release_year_to.
+func (h *Handler) GetMovies() gin.HandlerFunc {
 	return func(c *gin.Context) {
 		// Set Content-Type header to application/json
 		c.Header("Content-Type", "application/json")
 
-		c.IndentedJSON(http.StatusOK, models.Movies)
+		params, err := pagination.ParseParams(c)
+		if err != nil {
+			c.IndentedJSON(http.StatusBadRequest, gin.H{"message": err.Error()})
+			return
+		}
+
+		sort, err := pagination.ParseSort(c.Query("sort"), "-popularity", helper.MovieSortFields)
+		if err != nil {
+			c.IndentedJSON(http.StatusBadRequest, gin.H{"message": err.Error()})
+			return
+		}
+
+		filter, err := helper.GetMovieFilterHelper(c)
+		if err != nil {
+			c.IndentedJSON(http.StatusBadRequest, gin.H{"message": err.Error()})
+			return
+		}
+
+		movies, total, err := h.Movies.List(c.Request.Context(), filter, sort, params)
+		if err != nil {
+			c.IndentedJSON(http.StatusInternalServerError, gin.H{"message": err.Error()})
+			return
+		}
+
+		c.IndentedJSON(http.StatusOK, pagination.NewPage(c, movies, params, total))
+	}
+}
+
+// SearchMovies responds with the movies matching the `q` query parameter as JSON.
+// Title, overview, tagline, cast, writers and director are searched; partial words
+// and small typos still match. Results are paginated with page and limit.
+func (h *Handler) SearchMovies() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		// Set Content-Type header to application/json
+		c.Header("Content-Type", "application/json")
+
+		query := search.NewQuery(c.Query("q"))
+		if query.Empty() {
+			c.IndentedJSON(http.StatusBadRequest, gin.H{"message": "The q query parameter is required"})
+			return
+		}
+
+		params, err := pagination.ParseParams(c)
+		if err != nil {
+			c.IndentedJSON(http.StatusBadRequest, gin.H{"message": err.Error()})
+			return
+		}
+
+		results, err := helper.SearchMoviesHelper(c.Request.Context(), h.Movies, query)
+		if err != nil {
+			c.IndentedJSON(http.StatusInternalServerError, gin.H{"message": err.Error()})
+			return
+		}
+
+		// Slice out the requested page
+		start := min(params.Skip(), int64(len(results)))
+		end := min(start+int64(params.Limit), int64(len(results)))
+
+		c.IndentedJSON(http.StatusOK, pagination.NewPage(c, results[start:end], params, int64(len(results))))
 	}
 }
 
-// getMGetMovieByIDovieByID locates the movie whose ID value matches the id
+// GetMovieByID locates the movie whose ID value matches the id
 // parameter sent by the client, then returns that movie as a response.
-func GetMovieByID() gin.HandlerFunc {
+func (h *Handler) GetMovieByID() gin.HandlerFunc {
 	return func(c *gin.Context) {
 		// Set Content-Type header to application/json
 		c.Header("Content-Type", "application/json")
@@ -34,10 +119,9 @@ func GetMovieByID() gin.HandlerFunc {
 			return
 		}
 
-		movie := helper.GetMovieByIDHelper(movieID)
-		if movie == nil {
-			// Movie was not found
-			c.IndentedJSON(http.StatusNotFound, gin.H{"message": "Movie not found"})
+		movie, err := helper.GetMovieByIDHelper(c.Request.Context(), h.Movies, movieID)
+		if err != nil {
+			respondWithMovieLookupError(c, err)
 			return
 		}
 
@@ -48,7 +132,7 @@ func GetMovieByID() gin.HandlerFunc {
 
 // GetMovieByIDCast get the cast in a movie whose ID value matches the id
 // parameter sent by the client, then returns that cast as a response.
-func GetMovieByIDCast() gin.HandlerFunc {
+func (h *Handler) GetMovieByIDCast() gin.HandlerFunc {
 	return func(c *gin.Context) {
 		// Set Content-Type header to application/json
 		c.Header("Content-Type", "application/json")
@@ -61,10 +145,9 @@ func GetMovieByIDCast() gin.HandlerFunc {
 			return
 		}
 
-		movie := helper.GetMovieByIDHelper(movieID)
-		if movie == nil {
-			// Movie was not found
-			c.IndentedJSON(http.StatusNotFound, gin.H{"message": "Movie not found"})
+		movie, err := helper.GetMovieByIDHelper(c.Request.Context(), h.Movies, movieID)
+		if err != nil {
+			respondWithMovieLookupError(c, err)
 			return
 		}
 
@@ -75,7 +158,7 @@ func GetMovieByIDCast() gin.HandlerFunc {
 
 // GetMovieByIDSimilarMoviesByGenre gets the movies whose genre matches the id
 // parameter sent by the client, then returns that movies as a response.
-func GetMovieByIDSimilarMoviesByGenre() gin.HandlerFunc {
+func (h *Handler) GetMovieByIDSimilarMoviesByGenre() gin.HandlerFunc {
 	return func(c *gin.Context) {
 		// Set Content-Type header to application/json
 		c.Header("Content-Type", "application/json")
@@ -88,22 +171,195 @@ func GetMovieByIDSimilarMoviesByGenre() gin.HandlerFunc {
 			return
 		}
 
-		movie := helper.GetMovieByIDHelper(movieID)
-		if movie == nil {
-			// Movie was not found
-			c.IndentedJSON(http.StatusNotFound, gin.H{"message": "Movie not found"})
+		movie, err := helper.GetMovieByIDHelper(c.Request.Context(), h.Movies, movieID)
+		if err != nil {
+			respondWithMovieLookupError(c, err)
 			return
 		}
 
 		// Movie exists, find similar movies
-		similarMovies := helper.FindSimilarMoviesByGenreHelper(movie, models.Movies)
+		similarMovies, err := helper.FindSimilarMoviesByGenreHelper(c.Request.Context(), h.Movies, movie)
+		if err != nil {
+			c.IndentedJSON(http.StatusInternalServerError, gin.H{"message": err.Error()})
+			return
+		}
 
-		if len(similarMovies) > 0 {
-			// Return similar movies
-			c.IndentedJSON(http.StatusOK, similarMovies)
+		// Return similar movies (an empty array when there are none)
+		c.IndentedJSON(http.StatusOK, similarMovies)
+	}
+}
+
+// CreateMovie adds the movie sent in the request body to the catalogue.
+// A movie_id is allocated when the client does not send one.
+func (h *Handler) CreateMovie() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var movie models.Movie
+		if err := c.ShouldBindJSON(&movie); err != nil {
+			c.IndentedJSON(http.StatusBadRequest, gin.H{"message": err.Error()})
+			return
+		}
+
+		if validationErr := h.Validate.Struct(movie); validationErr != nil {
+			c.IndentedJSON(http.StatusBadRequest, gin.H{"message": validationErr.Error()})
+			return
+		}
+
+		// A server allocated movie_id is retried on a clash, a requested one is not
+		var err error
+		if movie.Movie_id == 0 {
+			err = h.Movies.InsertWithNewID(c.Request.Context(), &movie)
 		} else {
-			// Return empty array movies
-			c.IndentedJSON(http.StatusOK, []models.Movie{})
+			err = h.Movies.Insert(c.Request.Context(), &movie)
+		}
+		if errors.Is(err, repository.ErrDuplicateMovieID) {
+			c.IndentedJSON(http.StatusConflict, gin.H{"message": "A movie with this movie_id already exists"})
+			return
+		}
+		if err != nil {
+			respondWithMovieLookupError(c, err)
+			return
 		}
+
+		// Return created movie
+		c.IndentedJSON(http.StatusCreated, movie)
 	}
 }
+
+// ReplaceMovie overwrites the movie whose ID matches the id parameter
+// with the movie sent in the request body.
+func (h *Handler) ReplaceMovie() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		movieID, err := helper.GetMovieIDHelper(c)
+		if err != nil {
+			c.IndentedJSON(http.StatusBadRequest, gin.H{"message": "Invalid movieID format"})
+			return
+		}
+
+		var movie models.Movie
+		if err := c.ShouldBindJSON(&movie); err != nil {
+			c.IndentedJSON(http.StatusBadRequest, gin.H{"message": err.Error()})
+			return
+		}
+
+		// The path parameter always wins over a movie_id in the body
+		movie.Movie_id = movieID
+
+		if validationErr := h.Validate.Struct(movie); validationErr != nil {
+			c.IndentedJSON(http.StatusBadRequest, gin.H{"message": validationErr.Error()})
+			return
+		}
+
+		if err := h.Movies.Replace(c.Request.Context(), &movie); err != nil {
+			respondWithMovieLookupError(c, err)
+			return
+		}
+
+		c.IndentedJSON(http.StatusOK, movie)
+	}
+}
+
+// UpdateMovie applies the fields sent in the request body to the movie whose
+// ID matches the id parameter. Fields that are not sent keep their value.
+func (h *Handler) UpdateMovie() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		movieID, err := helper.GetMovieIDHelper(c)
+		if err != nil {
+			c.IndentedJSON(http.StatusBadRequest, gin.H{"message": "Invalid movieID format"})
+			return
+		}
+
+		movie, err := helper.GetMovieByIDHelper(c.Request.Context(), h.Movies, movieID)
+		if err != nil {
+			respondWithMovieLookupError(c, err)
+			return
+		}
+
+		// Decoding onto the stored movie only overwrites the fields present in the body
+		if err := c.ShouldBindJSON(movie); err != nil {
+			c.IndentedJSON(http.StatusBadRequest, gin.H{"message": err.Error()})
+			return
+		}
+		movie.Movie_id = movieID
+
+		if validationErr := h.Validate.Struct(movie); validationErr != nil {
+			c.IndentedJSON(http.StatusBadRequest, gin.H{"message": validationErr.Error()})
+			return
+		}
+
+		if err := h.Movies.Replace(c.Request.Context(), movie); err != nil {
+			respondWithMovieLookupError(c, err)
+			return
+		}
+
+		c.IndentedJSON(http.StatusOK, movie)
+	}
+}
+
+// DeleteMovie removes the movie whose ID matches the id parameter.
+func (h *Handler) DeleteMovie() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		movieID, err := helper.GetMovieIDHelper(c)
+		if err != nil {
+			c.IndentedJSON(http.StatusBadRequest, gin.H{"message": "Invalid movieID format"})
+			return
+		}
+
+		if err := h.Movies.Delete(c.Request.Context(), movieID); err != nil {
+			respondWithMovieLookupError(c, err)
+			return
+		}
+
+		c.IndentedJSON(http.StatusOK, gin.H{"message": "Movie deleted"})
+	}
+}
+
+// ImportMovies upserts the TMDB documents sent either as a multipart "file" field
+// or as the raw request body (a JSON document, a JSON array or newline-delimited JSON),
+// then responds with a per-record import report.
+func (h *Handler) ImportMovies() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, maxImportSize)
+
+		var source io.Reader = c.Request.Body
+		if strings.HasPrefix(c.ContentType(), "multipart/form-data") {
+			fileHeader, err := c.FormFile("file")
+			if err != nil {
+				c.IndentedJSON(http.StatusBadRequest, gin.H{"message": "A \"file\" field is required"})
+				return
+			}
+
+			file, err := fileHeader.Open()
+			if err != nil {
+				c.IndentedJSON(http.StatusBadRequest, gin.H{"message": err.Error()})
+				return
+			}
+			defer file.Close()
+
+			source = file
+		}
+
+		report, err := importer.Import(c.Request.Context(), h.Movies, source)
+		if err != nil {
+			// Records handled before the source became unreadable are still reported
+			c.IndentedJSON(http.StatusBadRequest, gin.H{"message": err.Error(), "report": report})
+			return
+		}
+
+		c.IndentedJSON(http.StatusOK, report)
+	}
+}
+
+// respondWithMovieLookupError maps a failed movie lookup or write to 404, 409 or 500.
+func respondWithMovieLookupError(c *gin.Context, err error) {
+	if errors.Is(err, repository.ErrMovieNotFound) {
+		// Movie was not found
+		c.IndentedJSON(http.StatusNotFound, gin.H{"message": "Movie not found"})
+		return
+	}
+	if errors.Is(err, repository.ErrDuplicateTMDBID) {
+		c.IndentedJSON(http.StatusConflict, gin.H{"message": "A movie with this tmdb_id already exists"})
+		return
+	}
+
+	c.IndentedJSON(http.StatusInternalServerError, gin.H{"message": err.Error()})
+}
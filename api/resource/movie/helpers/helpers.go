@@ -1,9 +1,15 @@
 package helpers
 
 import (
+	"context"
+	"fmt"
+	"sort"
 	"strconv"
+	"strings"
 
 	models "movie-api/api/resource/movie/model"
+	"movie-api/api/resource/movie/repository"
+	"movie-api/api/resource/movie/search"
 
 	"github.com/gin-gonic/gin"
 )
@@ -21,37 +27,106 @@ func GetMovieIDHelper(c *gin.Context) (uint64, error) {
 	return movieID, nil
 }
 
-// Handler to get movie by ID
-func GetMovieByIDHelper(movieID uint64) *models.Movie {
-	for _, movie := range models.Movies {
-		if movie.Movie_id == movieID {
-			return &movie
+// MovieSortFields maps the public `sort` keys of GET /movies onto stored fields.
+var MovieSortFields = map[string]string{
+	"popularity":   "popularity",
+	"vote_average": "vote_average",
+	"vote_count":   "vote_count",
+	"release_date": "release_date",
+}
+
+// Helper to read the movie listing filters from the query string:
+// genre (comma separated IDs), original_language, adult, status,
+// release_year_from and release_year_to.
+func GetMovieFilterHelper(c *gin.Context) (models.MovieFilter, error) {
+	var filter models.MovieFilter
+
+	if value := c.Query("genre"); value != "" {
+		for _, genre := range strings.Split(value, ",") {
+			genreID, err := strconv.ParseUint(strings.TrimSpace(genre), 10, 64)
+			if err != nil {
+				return filter, fmt.Errorf("genre must be a comma separated list of genre IDs")
+			}
+			filter.Genre_ids = append(filter.Genre_ids, genreID)
 		}
 	}
-	return nil
-}
 
-// Handler to find similar movies to target movie by genre
-func FindSimilarMoviesByGenreHelper(targetMovie *models.Movie, allMovies []models.Movie) []models.Movie {
-	// Initialize an array to store similar movies
-	var similarMovies []models.Movie
-	// Iterate through all movies
-	for _, movie := range allMovies {
-		// Skip the target movie itself
-		if movie.Movie_id == targetMovie.Movie_id {
-			continue
+	filter.Original_language = c.Query("original_language")
+	filter.Status = c.Query("status")
+
+	if value := c.Query("adult"); value != "" {
+		adult, err := strconv.ParseBool(value)
+		if err != nil {
+			return filter, fmt.Errorf("adult must be true or false")
+		}
+		filter.Adult = &adult
+	}
+
+	for param, target := range map[string]*int{
+		"release_year_from": &filter.Release_year_from,
+		"release_year_to":   &filter.Release_year_to,
+	} {
+		if value := c.Query(param); value != "" {
+			year, err := strconv.Atoi(value)
+			if err != nil || year < 1800 || year > 9999 {
+				return filter, fmt.Errorf("%s must be a four digit year", param)
+			}
+			*target = year
 		}
+	}
 
-		// Check if the movie shares at least one genre with the target movie
-		commonGenres := CountCommonGenres(targetMovie.Genres, movie.Genres)
+	if filter.Release_year_from > 0 && filter.Release_year_to > 0 && filter.Release_year_from > filter.Release_year_to {
+		return filter, fmt.Errorf("release_year_from must not be after release_year_to")
+	}
 
-		// Adjust the criteria based on your needs (e.g., require a minimum number of shared genres)
-		if commonGenres >= 1 {
-			similarMovies = append(similarMovies, movie)
+	return filter, nil
+}
+
+// Helper to search the stored movies, most relevant first. Storage narrows the
+// candidates by the search keys of the terms, and only those are scored.
+func SearchMoviesHelper(ctx context.Context, movies repository.Repository, query search.Query) ([]search.Result, error) {
+	candidates, err := movies.FindBySearchKeys(ctx, query.Keys())
+	if err != nil {
+		return nil, err
+	}
+
+	results := []search.Result{}
+	for i := range candidates {
+		if score := query.Score(&candidates[i]); score > 0 {
+			results = append(results, search.Result{Movie: candidates[i], Score: score})
 		}
 	}
 
-	return similarMovies
+	search.Rank(results)
+	return results, nil
+}
+
+// Handler to get movie by ID.
+// Returns repository.ErrMovieNotFound when no movie has the given ID.
+func GetMovieByIDHelper(ctx context.Context, movies repository.Repository, movieID uint64) (*models.Movie, error) {
+	return movies.FindByID(ctx, movieID)
+}
+
+// Handler to find similar movies to target movie by genre
+func FindSimilarMoviesByGenreHelper(ctx context.Context, movies repository.Repository, targetMovie *models.Movie) ([]models.Movie, error) {
+	genreIDs := make([]uint64, 0, len(targetMovie.Genres))
+	for _, genre := range targetMovie.Genres {
+		genreIDs = append(genreIDs, genre.ID)
+	}
+
+	// Only movies sharing at least one genre are loaded from storage
+	similarMovies, err := movies.FindByGenreIDs(ctx, genreIDs, targetMovie.Movie_id)
+	if err != nil {
+		return nil, err
+	}
+
+	// Movies sharing the most genres with the target movie come first
+	sort.SliceStable(similarMovies, func(i, j int) bool {
+		return CountCommonGenres(targetMovie.Genres, similarMovies[i].Genres) >
+			CountCommonGenres(targetMovie.Genres, similarMovies[j].Genres)
+	})
+
+	return similarMovies, nil
 }
 
 // Helper to get common genres amongst movies
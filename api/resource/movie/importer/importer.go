@@ -0,0 +1,206 @@
+package importer
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+
+	"movie-api/api/resource/movie/repository"
+
+	"github.com/go-playground/validator/v10"
+)
+
+// Use a single instance of Validate, it caches struct info
+var validate *validator.Validate = validator.New()
+
+// Record is one raw TMDB document read from an import source.
+type Record struct {
+	Index int // 1-based position of the record in the source
+	Line  int // 1-based line number for newline-delimited sources, 0 otherwise
+	Data  json.RawMessage
+}
+
+// RecordError describes why a single record was not imported.
+type RecordError struct {
+	Record  int    `json:"record"`
+	Line    int    `json:"line,omitempty"`
+	Tmdb_id uint64 `json:"tmdb_id,omitempty"`
+	Message string `json:"message"`
+}
+
+// Report summarises an import run.
+type Report struct {
+	Total   int           `json:"total"`
+	Created int           `json:"created"`
+	Updated int           `json:"updated"`
+	Failed  int           `json:"failed"`
+	Errors  []RecordError `json:"errors"`
+}
+
+// Import reads TMDB documents from r and upserts each of them into movies by TMDB id.
+// A record that cannot be decoded, mapped, validated or stored is added to the
+// report and the import carries on with the next one. The returned error is only
+// set when the source itself cannot be read.
+func Import(ctx context.Context, movies repository.Repository, r io.Reader) (*Report, error) {
+	report := &Report{Errors: []RecordError{}}
+
+	err := ReadRecords(r, func(record Record) error {
+		report.Total++
+
+		tmdbID, created, err := importRecord(ctx, movies, record)
+		if err != nil {
+			// Stop the whole batch once the caller has gone away
+			if ctxErr := ctx.Err(); ctxErr != nil {
+				return ctxErr
+			}
+
+			report.Failed++
+			report.Errors = append(report.Errors, RecordError{
+				Record:  record.Index,
+				Line:    record.Line,
+				Tmdb_id: tmdbID,
+				Message: err.Error(),
+			})
+			return nil
+		}
+
+		if created {
+			report.Created++
+		} else {
+			report.Updated++
+		}
+		return nil
+	})
+
+	return report, err
+}
+
+func importRecord(ctx context.Context, movies repository.Repository, record Record) (tmdbID uint64, created bool, err error) {
+	var document TMDBMovie
+	if err := json.Unmarshal(record.Data, &document); err != nil {
+		return 0, false, fmt.Errorf("invalid JSON: %w", err)
+	}
+
+	movie, err := document.ToMovie()
+	if err != nil {
+		return document.ID, false, err
+	}
+
+	if validationErr := validate.Struct(movie); validationErr != nil {
+		return document.ID, false, validationErr
+	}
+
+	created, err = movies.UpsertByTmdbID(ctx, &movie)
+	return document.ID, created, err
+}
+
+// ReadRecords calls handle for every TMDB document in r. Three layouts are accepted:
+// a JSON array of documents, newline-delimited JSON (one document per line), and one
+// or more (possibly pretty-printed) documents one after another, such as sample-movie.json.
+//
+// With newline-delimited input a malformed line is still handed to handle, so the
+// caller can report it and carry on. For the other layouts a syntax error ends the read.
+func ReadRecords(r io.Reader, handle func(Record) error) error {
+	reader := bufio.NewReader(r)
+
+	// Skip blank lines before the first document
+	var firstLine []byte
+	lineNumber := 0
+	for {
+		line, err := reader.ReadBytes('\n')
+		if len(bytes.TrimSpace(line)) > 0 {
+			lineNumber++
+			firstLine = line
+			break
+		}
+		lineNumber++
+		if err == io.EOF {
+			return errors.New("no records found")
+		}
+		if err != nil {
+			return err
+		}
+	}
+
+	trimmedFirstLine := bytes.TrimSpace(firstLine)
+	rest := io.MultiReader(bytes.NewReader(firstLine), reader)
+
+	if trimmedFirstLine[0] == '[' {
+		return readArray(rest, handle)
+	}
+
+	// A first line that is a complete document means one document per line
+	if trimmedFirstLine[0] == '{' && json.Valid(trimmedFirstLine) {
+		return readLines(reader, firstLine, lineNumber, handle)
+	}
+
+	return readStream(rest, handle)
+}
+
+func readArray(r io.Reader, handle func(Record) error) error {
+	decoder := json.NewDecoder(r)
+	if _, err := decoder.Token(); err != nil {
+		return err
+	}
+
+	for index := 1; decoder.More(); index++ {
+		var data json.RawMessage
+		if err := decoder.Decode(&data); err != nil {
+			return fmt.Errorf("record %d: %w", index, err)
+		}
+		if err := handle(Record{Index: index, Data: data}); err != nil {
+			return err
+		}
+	}
+
+	_, err := decoder.Token()
+	return err
+}
+
+func readLines(reader *bufio.Reader, firstLine []byte, lineNumber int, handle func(Record) error) error {
+	index := 1
+	if err := handle(Record{Index: index, Line: lineNumber, Data: bytes.TrimSpace(firstLine)}); err != nil {
+		return err
+	}
+
+	for {
+		line, readErr := reader.ReadBytes('\n')
+		if readErr != nil && readErr != io.EOF {
+			return readErr
+		}
+
+		lineNumber++
+		if trimmed := bytes.TrimSpace(line); len(trimmed) > 0 {
+			index++
+			if err := handle(Record{Index: index, Line: lineNumber, Data: trimmed}); err != nil {
+				return err
+			}
+		}
+
+		if readErr == io.EOF {
+			return nil
+		}
+	}
+}
+
+func readStream(r io.Reader, handle func(Record) error) error {
+	decoder := json.NewDecoder(r)
+
+	for index := 1; ; index++ {
+		var data json.RawMessage
+		err := decoder.Decode(&data)
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("record %d: %w", index, err)
+		}
+		if err := handle(Record{Index: index, Data: data}); err != nil {
+			return err
+		}
+	}
+}
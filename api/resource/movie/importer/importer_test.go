@@ -0,0 +1,140 @@
+package importer
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"reflect"
+	"strings"
+	"testing"
+
+	"movie-api/api/resource/movie/repository"
+)
+
+// readAll returns the records ReadRecords finds in input.
+func readAll(t *testing.T, input string) ([]Record, error) {
+	t.Helper()
+
+	var records []Record
+	err := ReadRecords(strings.NewReader(input), func(record Record) error {
+		records = append(records, record)
+		return nil
+	})
+
+	return records, err
+}
+
+// summary reduces records to index:line:data, for comparing with the expected ones.
+func summary(records []Record) []string {
+	var lines []string
+	for _, record := range records {
+		lines = append(lines, fmt.Sprintf("%d:%d:%s", record.Index, record.Line, record.Data))
+	}
+
+	return lines
+}
+
+func TestReadRecords(t *testing.T) {
+	tests := []struct {
+		name  string
+		input string
+		want  []string
+	}{
+		{
+			name:  "array",
+			input: "\n[\n  {\"id\": 1},\n  {\"id\": 2}\n]\n",
+			want:  []string{`1:0:{"id": 1}`, `2:0:{"id": 2}`},
+		},
+		{
+			name:  "newline-delimited",
+			input: "\n{\"id\": 1}\n\n{\"id\": 2}\n{\"id\": 3}",
+			want:  []string{`1:2:{"id": 1}`, `2:4:{"id": 2}`, `3:5:{"id": 3}`},
+		},
+		{
+			name:  "newline-delimited with a malformed line",
+			input: "{\"id\": 1}\n{\"id\": \n{\"id\": 3}\n",
+			want:  []string{`1:1:{"id": 1}`, `2:2:{"id":`, `3:3:{"id": 3}`},
+		},
+		{
+			name:  "pretty-printed documents",
+			input: "{\n  \"id\": 1\n}\n{\n  \"id\": 2\n}\n",
+			want:  []string{"1:0:{\n  \"id\": 1\n}", "2:0:{\n  \"id\": 2\n}"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			records, err := readAll(t, tt.input)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := summary(records); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("records = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestReadRecordsErrors(t *testing.T) {
+	tests := []struct {
+		name  string
+		input string
+	}{
+		{"empty", ""},
+		{"blank lines only", "\n  \n"},
+		{"malformed array", `[{"id": 1}, {"id": }]`},
+		{"malformed pretty-printed document", "{\n  \"id\": \n}"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := readAll(t, tt.input); err == nil {
+				t.Error("no error")
+			}
+		})
+	}
+}
+
+func TestReadRecordsStopsOnHandlerError(t *testing.T) {
+	stop := errors.New("stop")
+	calls := 0
+
+	err := ReadRecords(strings.NewReader("{\"id\": 1}\n{\"id\": 2}\n"), func(Record) error {
+		calls++
+		return stop
+	})
+	if !errors.Is(err, stop) || calls != 1 {
+		t.Errorf("err = %v after %d calls, want stop after 1", err, calls)
+	}
+}
+
+func TestImport(t *testing.T) {
+	sample, err := os.ReadFile("../../../../sample-movie.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	movies := repository.NewMemoryRepository()
+	input := string(sample) + "\n{\"title\": \"No TMDB id\"}\n"
+
+	report, err := Import(context.Background(), movies, strings.NewReader(input))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if report.Total != 2 || report.Created != 1 || report.Failed != 1 {
+		t.Fatalf("report = %+v, want 1 created and 1 failed", report)
+	}
+	if report.Errors[0].Record != 2 {
+		t.Errorf("error = %+v, want it on record 2", report.Errors[0])
+	}
+
+	// Importing again updates the movie instead of adding a copy
+	report, err = Import(context.Background(), movies, strings.NewReader(string(sample)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if report.Created != 0 || report.Updated != 1 {
+		t.Errorf("report of the second import = %+v, want 1 updated", report)
+	}
+}
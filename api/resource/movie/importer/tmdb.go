@@ -0,0 +1,179 @@
+package importer
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	models "movie-api/api/resource/movie/model"
+)
+
+// TMDBMovie is a movie document as returned by the TMDB /movie/{id} endpoint,
+// optionally with append_to_response=credits.
+type TMDBMovie struct {
+	ID                   uint64                     `json:"id"`
+	Imdb_id              string                     `json:"imdb_id"`
+	Title                string                     `json:"title"`
+	Original_title       string                     `json:"original_title"`
+	Original_language    string                     `json:"original_language"`
+	Overview             string                     `json:"overview"`
+	Tagline              string                     `json:"tagline"`
+	Homepage             string                     `json:"homepage"`
+	Status               string                     `json:"status"`
+	Release_date         string                     `json:"release_date"`
+	Runtime              uint64                     `json:"runtime"`
+	Budget               uint64                     `json:"budget"`
+	Revenue              uint64                     `json:"revenue"`
+	Popularity           float64                    `json:"popularity"`
+	Vote_average         float64                    `json:"vote_average"`
+	Vote_count           uint64                     `json:"vote_count"`
+	Adult                bool                       `json:"adult"`
+	Video                bool                       `json:"video"`
+	Poster_path          string                     `json:"poster_path"`
+	Backdrop_path        string                     `json:"backdrop_path"`
+	Genres               []models.Genre             `json:"genres"`
+	Spoken_languages     []TMDBSpokenLanguage       `json:"spoken_languages"`
+	Production_companies []TMDBProductionCompany    `json:"production_companies"`
+	Production_countries []models.ProductionCountry `json:"production_countries"`
+	Credits              *TMDBCredits               `json:"credits"`
+}
+
+type TMDBSpokenLanguage struct {
+	English_name string `json:"english_name"`
+	Iso_639_1    string `json:"iso_639_1"`
+	Name         string `json:"name"`
+}
+
+type TMDBProductionCompany struct {
+	ID             uint64  `json:"id"`
+	Name           string  `json:"name"`
+	Logo_path      *string `json:"logo_path"`
+	Origin_country string  `json:"origin_country"`
+}
+
+type TMDBCredits struct {
+	Cast []TMDBCastMember `json:"cast"`
+	Crew []TMDBCrewMember `json:"crew"`
+}
+
+type TMDBCastMember struct {
+	Name  string `json:"name"`
+	Order int    `json:"order"`
+}
+
+type TMDBCrewMember struct {
+	Name       string `json:"name"`
+	Job        string `json:"job"`
+	Department string `json:"department"`
+}
+
+// ToMovie maps the TMDB document onto the catalogue's Movie model.
+// The returned movie has no movie_id; it is assigned when the movie is stored.
+func (t TMDBMovie) ToMovie() (models.Movie, error) {
+	movie := models.Movie{
+		Tmdb_id:              t.ID,
+		Imdb_id:              t.Imdb_id,
+		Title:                t.Title,
+		Original_title:       t.Original_title,
+		Overview:             t.Overview,
+		Homepage:             t.Homepage,
+		Popularity:           t.Popularity,
+		Status:               t.Status,
+		Tagline:              []string{},
+		Video:                t.Video,
+		Vote_average:         t.Vote_average,
+		Vote_count:           t.Vote_count,
+		Runtime:              t.Runtime,
+		Budget:               t.Budget,
+		Revenue:              t.Revenue,
+		Original_language:    t.Original_language,
+		Spoken_languages:     []models.SpokenLanguage{},
+		Poster_path:          t.Poster_path,
+		Backdrop_path:        t.Backdrop_path,
+		Adult:                t.Adult,
+		Genres:               t.Genres,
+		Production_countries: t.Production_countries,
+		Cast:                 []models.FullName{},
+		Writers:              []models.FullName{},
+	}
+
+	if t.ID == 0 {
+		return movie, fmt.Errorf("missing TMDB id")
+	}
+
+	if t.Release_date != "" {
+		releaseDate, err := time.Parse("2006-01-02", t.Release_date)
+		if err != nil {
+			return movie, fmt.Errorf("invalid release_date %q", t.Release_date)
+		}
+		movie.Release_date = releaseDate
+	}
+
+	if tagline := strings.TrimSpace(t.Tagline); tagline != "" {
+		movie.Tagline = append(movie.Tagline, tagline)
+	}
+
+	if movie.Genres == nil {
+		movie.Genres = []models.Genre{}
+	}
+
+	for _, language := range t.Spoken_languages {
+		movie.Spoken_languages = append(movie.Spoken_languages, models.SpokenLanguage{
+			English_name: language.English_name,
+			Name:         language.Name,
+			Iso_2:        language.Iso_639_1,
+		})
+	}
+
+	for _, company := range t.Production_companies {
+		productionCompany := models.ProductionCompany{
+			ID:             company.ID,
+			Name:           company.Name,
+			Origin_country: company.Origin_country,
+		}
+		if company.Logo_path != nil {
+			productionCompany.Logo_path = *company.Logo_path
+		}
+		movie.Production_companies = append(movie.Production_companies, productionCompany)
+	}
+
+	if t.Credits != nil {
+		for _, member := range t.Credits.Cast {
+			movie.Cast = append(movie.Cast, SplitFullName(member.Name))
+		}
+
+		for _, member := range t.Credits.Crew {
+			switch {
+			case member.Job == "Director" && movie.Director.First_name == "" && movie.Director.Last_name == "":
+				movie.Director = SplitFullName(member.Name)
+			case member.Department == "Writing" && !containsName(movie.Writers, member.Name):
+				// A writer is listed once per credited job (Screenplay, Novel, ...)
+				movie.Writers = append(movie.Writers, SplitFullName(member.Name))
+			}
+		}
+	}
+
+	return movie, nil
+}
+
+// SplitFullName splits a display name at its first space, so
+// "Yahya Abdul-Mateen II" becomes "Yahya" and "Abdul-Mateen II".
+func SplitFullName(name string) models.FullName {
+	firstName, lastName, _ := strings.Cut(strings.TrimSpace(name), " ")
+
+	return models.FullName{
+		First_name: firstName,
+		Last_name:  strings.TrimSpace(lastName),
+	}
+}
+
+func containsName(names []models.FullName, name string) bool {
+	fullName := SplitFullName(name)
+	for _, existing := range names {
+		if existing == fullName {
+			return true
+		}
+	}
+
+	return false
+}
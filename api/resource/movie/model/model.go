@@ -5,44 +5,76 @@ import (
 )
 
 type Movie struct {
-	Movie_id          uint64           `json:"movie_id"`
-	Title             string           `json:"title"`
-	Overview          string           `json:"overview"`
-	Popularity        float64          `json:"popularity"`
-	Status            string           `json:"status"`
-	Tagline           []string         `json:"tagline"`
-	Video             bool             `json:"video"`
-	Vote_average      float64          `json:"vote_average"`
-	Vote_count        uint64           `json:"vote_count"`
-	Release_date      time.Time        `json:"release_date"`
-	Original_language string           `json:"original_language"`
-	Spoken_languages  []SpokenLanguage `json:"spoken_languages"`
-	Poster_path       string           `json:"poster_path"`
-	Backdrop_path     string           `json:"backdrop_path"`
-	Adult             bool             `json:"adult"`
-	Genres            []Genre          `json:"genres"`
-	Cast              []FullName       `json:"cast"`
-	Writers           []FullName       `json:"writers"`
-	Director          FullName         `json:"director"`
+	Movie_id             uint64              `json:"movie_id"`
+	Tmdb_id              uint64              `json:"tmdb_id,omitempty" bson:"tmdb_id,omitempty"`
+	Imdb_id              string              `json:"imdb_id,omitempty" validate:"omitempty,max=20"`
+	Title                string              `json:"title" validate:"required,max=300"`
+	Original_title       string              `json:"original_title,omitempty" validate:"max=300"`
+	Overview             string              `json:"overview" validate:"max=5000"`
+	Homepage             string              `json:"homepage,omitempty" validate:"max=2048"`
+	Popularity           float64             `json:"popularity" validate:"gte=0"`
+	Status               string              `json:"status" validate:"max=50"`
+	Tagline              []string            `json:"tagline" validate:"dive,max=300"`
+	Video                bool                `json:"video"`
+	Vote_average         float64             `json:"vote_average" validate:"gte=0,lte=10"`
+	Vote_count           uint64              `json:"vote_count"`
+	Release_date         time.Time           `json:"release_date"`
+	Runtime              uint64              `json:"runtime,omitempty"`
+	Budget               uint64              `json:"budget,omitempty"`
+	Revenue              uint64              `json:"revenue,omitempty"`
+	Original_language    string              `json:"original_language" validate:"omitempty,len=2"`
+	Spoken_languages     []SpokenLanguage    `json:"spoken_languages" validate:"dive"`
+	Poster_path          string              `json:"poster_path" validate:"max=2048"`
+	Backdrop_path        string              `json:"backdrop_path" validate:"max=2048"`
+	Adult                bool                `json:"adult"`
+	Genres               []Genre             `json:"genres" validate:"dive"`
+	Production_companies []ProductionCompany `json:"production_companies,omitempty" validate:"dive"`
+	Production_countries []ProductionCountry `json:"production_countries,omitempty" validate:"dive"`
+	Cast                 []FullName          `json:"cast" validate:"dive"`
+	Writers              []FullName          `json:"writers" validate:"dive"`
+	Director             FullName            `json:"director"`
 }
 
 type FullName struct {
-	First_name string `json:"first_name"`
-	Last_name  string `json:"last_name"`
+	First_name string `json:"first_name" validate:"max=100"`
+	Last_name  string `json:"last_name" validate:"max=100"`
 }
 
 type Genre struct {
-	ID   uint64 `json:"id"`
-	Name string `json:"name"`
+	ID   uint64 `json:"id" validate:"required"`
+	Name string `json:"name" validate:"required,max=100"`
 }
 
 type SpokenLanguage struct {
-	English_name string `json:"english_name"`
-	Name         string `json:"name"`
-	Iso_2        string `json:"iso_2"`
+	English_name string `json:"english_name" validate:"max=100"`
+	Name         string `json:"name" validate:"max=100"`
+	Iso_2        string `json:"iso_2" validate:"omitempty,len=2"`
 }
 
-var Movies = []Movie{
+type ProductionCompany struct {
+	ID             uint64 `json:"id"`
+	Name           string `json:"name" validate:"required,max=200"`
+	Logo_path      string `json:"logo_path,omitempty" validate:"max=2048"`
+	Origin_country string `json:"origin_country,omitempty" validate:"omitempty,len=2"`
+}
+
+type ProductionCountry struct {
+	Iso_3166_1 string `json:"iso_3166_1" validate:"omitempty,len=2"`
+	Name       string `json:"name" validate:"max=100"`
+}
+
+// MovieFilter narrows a movie listing. Zero values leave a criterion out.
+type MovieFilter struct {
+	Genre_ids         []uint64 // movies having any of these genres
+	Original_language string
+	Adult             *bool
+	Status            string
+	Release_year_from int // inclusive
+	Release_year_to   int // inclusive
+}
+
+// SeedMovies is the starter catalogue inserted into an empty movies collection.
+var SeedMovies = []Movie{
 	{
 		Movie_id:   1,
 		Title:      "Fight Club",
@@ -0,0 +1,321 @@
+package repository
+
+import (
+	"context"
+	"sort"
+	"sync"
+	"time"
+
+	"movie-api/api/pagination"
+	models "movie-api/api/resource/movie/model"
+	"movie-api/api/resource/movie/search"
+)
+
+// MemoryRepository keeps the movies in process memory. State is lost on restart and
+// not shared between replicas. Movies are copied on the way in and out, so callers
+// may change what they get without touching the stored catalogue.
+type MemoryRepository struct {
+	mu     sync.RWMutex
+	movies map[uint64]models.Movie
+}
+
+func NewMemoryRepository() *MemoryRepository {
+	return &MemoryRepository{movies: map[uint64]models.Movie{}}
+}
+
+func (r *MemoryRepository) EnsureIndexes(ctx context.Context) error {
+	return nil
+}
+
+func (r *MemoryRepository) SeedIfEmpty(ctx context.Context, movies []models.Movie) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if len(r.movies) > 0 {
+		return nil
+	}
+
+	for _, movie := range movies {
+		r.movies[movie.Movie_id] = cloneMovie(movie)
+	}
+
+	return nil
+}
+
+func (r *MemoryRepository) FindBySearchKeys(ctx context.Context, keys [][]string) ([]models.Movie, error) {
+	return r.sorted(func(movie *models.Movie) bool { return hasSearchKeys(search.Keys(movie), keys) }), nil
+}
+
+func (r *MemoryRepository) List(ctx context.Context, filter models.MovieFilter, sortFields []pagination.SortField, params pagination.Params) ([]models.Movie, int64, error) {
+	movies := r.sorted(func(movie *models.Movie) bool { return matchesFilter(movie, filter) })
+
+	sort.SliceStable(movies, func(i, j int) bool {
+		for _, field := range sortFields {
+			order := compareMovies(&movies[i], &movies[j], field.Field)
+			if field.Descending {
+				order = -order
+			}
+			if order != 0 {
+				return order < 0
+			}
+		}
+		return false
+	})
+
+	total := int64(len(movies))
+	start := params.Skip()
+	if start > total {
+		start = total
+	}
+	end := start + int64(params.Limit)
+	if end > total {
+		end = total
+	}
+
+	return movies[start:end], total, nil
+}
+
+func (r *MemoryRepository) FindByID(ctx context.Context, movieID uint64) (*models.Movie, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	movie, ok := r.movies[movieID]
+	if !ok {
+		return nil, ErrMovieNotFound
+	}
+
+	movie = cloneMovie(movie)
+	return &movie, nil
+}
+
+func (r *MemoryRepository) FindByGenreIDs(ctx context.Context, genreIDs []uint64, excludeMovieID uint64) ([]models.Movie, error) {
+	wanted := map[uint64]bool{}
+	for _, genreID := range genreIDs {
+		wanted[genreID] = true
+	}
+
+	return r.sorted(func(movie *models.Movie) bool {
+		if movie.Movie_id == excludeMovieID {
+			return false
+		}
+		for _, genre := range movie.Genres {
+			if wanted[genre.ID] {
+				return true
+			}
+		}
+		return false
+	}), nil
+}
+
+func (r *MemoryRepository) Insert(ctx context.Context, movie *models.Movie) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	return r.insertLocked(movie)
+}
+
+func (r *MemoryRepository) InsertWithNewID(ctx context.Context, movie *models.Movie) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	movie.Movie_id = r.nextMovieIDLocked()
+	return r.insertLocked(movie)
+}
+
+func (r *MemoryRepository) insertLocked(movie *models.Movie) error {
+	if _, ok := r.movies[movie.Movie_id]; ok {
+		return ErrDuplicateMovieID
+	}
+	if movie.Tmdb_id > 0 && r.findByTmdbIDLocked(movie.Tmdb_id) != nil {
+		return ErrDuplicateTMDBID
+	}
+
+	r.movies[movie.Movie_id] = cloneMovie(*movie)
+	return nil
+}
+
+func (r *MemoryRepository) Replace(ctx context.Context, movie *models.Movie) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if _, ok := r.movies[movie.Movie_id]; !ok {
+		return ErrMovieNotFound
+	}
+	if existing := r.findByTmdbIDLocked(movie.Tmdb_id); movie.Tmdb_id > 0 && existing != nil && existing.Movie_id != movie.Movie_id {
+		return ErrDuplicateTMDBID
+	}
+
+	r.movies[movie.Movie_id] = cloneMovie(*movie)
+	return nil
+}
+
+func (r *MemoryRepository) Delete(ctx context.Context, movieID uint64) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if _, ok := r.movies[movieID]; !ok {
+		return ErrMovieNotFound
+	}
+
+	delete(r.movies, movieID)
+	return nil
+}
+
+func (r *MemoryRepository) UpsertByTmdbID(ctx context.Context, movie *models.Movie) (created bool, err error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if existing := r.findByTmdbIDLocked(movie.Tmdb_id); existing != nil {
+		movie.Movie_id = existing.Movie_id
+		r.movies[movie.Movie_id] = cloneMovie(*movie)
+		return false, nil
+	}
+
+	movie.Movie_id = r.nextMovieIDLocked()
+	r.movies[movie.Movie_id] = cloneMovie(*movie)
+	return true, nil
+}
+
+// sorted returns copies of the movies for which keep is true, ordered by movie_id.
+func (r *MemoryRepository) sorted(keep func(movie *models.Movie) bool) []models.Movie {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	movies := []models.Movie{}
+	for _, movie := range r.movies {
+		if keep(&movie) {
+			movies = append(movies, cloneMovie(movie))
+		}
+	}
+
+	sort.Slice(movies, func(i, j int) bool { return movies[i].Movie_id < movies[j].Movie_id })
+	return movies
+}
+
+// hasSearchKeys reports whether movieKeys holds one of the keys of every term.
+func hasSearchKeys(movieKeys []string, keys [][]string) bool {
+	stored := make(map[string]bool, len(movieKeys))
+	for _, key := range movieKeys {
+		stored[key] = true
+	}
+
+	for _, termKeys := range keys {
+		found := false
+		for _, key := range termKeys {
+			if stored[key] {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+
+	return true
+}
+
+func (r *MemoryRepository) nextMovieIDLocked() uint64 {
+	var last uint64
+	for movieID := range r.movies {
+		if movieID > last {
+			last = movieID
+		}
+	}
+
+	return last + 1
+}
+
+func (r *MemoryRepository) findByTmdbIDLocked(tmdbID uint64) *models.Movie {
+	for _, movie := range r.movies {
+		if movie.Tmdb_id == tmdbID {
+			return &movie
+		}
+	}
+
+	return nil
+}
+
+// matchesFilter applies filter the way movieFilterQuery does in MongoDB.
+func matchesFilter(movie *models.Movie, filter models.MovieFilter) bool {
+	if len(filter.Genre_ids) > 0 {
+		found := false
+		for _, genre := range movie.Genres {
+			for _, genreID := range filter.Genre_ids {
+				found = found || genre.ID == genreID
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+	if filter.Original_language != "" && movie.Original_language != filter.Original_language {
+		return false
+	}
+	if filter.Adult != nil && movie.Adult != *filter.Adult {
+		return false
+	}
+	if filter.Status != "" && movie.Status != filter.Status {
+		return false
+	}
+	if filter.Release_year_from > 0 && movie.Release_date.Before(time.Date(filter.Release_year_from, time.January, 1, 0, 0, 0, 0, time.UTC)) {
+		return false
+	}
+	if filter.Release_year_to > 0 && !movie.Release_date.Before(time.Date(filter.Release_year_to+1, time.January, 1, 0, 0, 0, 0, time.UTC)) {
+		return false
+	}
+
+	return true
+}
+
+// compareMovies orders two movies by one of the stored fields listed in the
+// movie helpers' MovieSortFields. Unknown fields compare equal.
+func compareMovies(a, b *models.Movie, field string) int {
+	switch field {
+	case "popularity":
+		return compare(a.Popularity, b.Popularity)
+	case "vote_average":
+		return compare(a.Vote_average, b.Vote_average)
+	case "vote_count":
+		return compare(a.Vote_count, b.Vote_count)
+	case "release_date":
+		return a.Release_date.Compare(b.Release_date)
+	case "title":
+		return compare(a.Title, b.Title)
+	case "movie_id":
+		return compare(a.Movie_id, b.Movie_id)
+	}
+
+	return 0
+}
+
+func compare[T float64 | uint64 | string](a, b T) int {
+	switch {
+	case a < b:
+		return -1
+	case a > b:
+		return 1
+	}
+	return 0
+}
+
+// cloneMovie copies movie together with its slices.
+func cloneMovie(movie models.Movie) models.Movie {
+	movie.Tagline = cloneSlice(movie.Tagline)
+	movie.Spoken_languages = cloneSlice(movie.Spoken_languages)
+	movie.Genres = cloneSlice(movie.Genres)
+	movie.Production_companies = cloneSlice(movie.Production_companies)
+	movie.Production_countries = cloneSlice(movie.Production_countries)
+	movie.Cast = cloneSlice(movie.Cast)
+	movie.Writers = cloneSlice(movie.Writers)
+
+	return movie
+}
+
+// cloneSlice copies s, keeping the difference between nil and empty slices.
+func cloneSlice[T any](s []T) []T {
+	if s == nil {
+		return nil
+	}
+	return append(make([]T, 0, len(s)), s...)
+}
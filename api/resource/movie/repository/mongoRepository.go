@@ -0,0 +1,352 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"time"
+
+	"movie-api/api/pagination"
+	models "movie-api/api/resource/movie/model"
+	"movie-api/api/resource/movie/search"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// indexNotFoundCode is the MongoDB error code for dropping an index that does not exist.
+const indexNotFoundCode int32 = 27
+
+// Names of the unique indexes, which duplicate key errors report.
+const (
+	movieIDIndex string = "movie_id_unique"
+	tmdbIDIndex  string = "tmdb_id_unique"
+)
+
+// MongoRepository keeps the movies in a MongoDB collection.
+type MongoRepository struct {
+	movies *mongo.Collection
+}
+
+// movieDocument is a movie as stored, with its search keys, so FindBySearchKeys can
+// use an index instead of reading every movie.
+type movieDocument struct {
+	models.Movie `bson:",inline"`
+	Search_keys  []string `bson:"search_keys"`
+}
+
+func newMovieDocument(movie *models.Movie) movieDocument {
+	return movieDocument{Movie: *movie, Search_keys: search.Keys(movie)}
+}
+
+func NewMongoRepository(movies *mongo.Collection) *MongoRepository {
+	return &MongoRepository{movies: movies}
+}
+
+// EnsureIndexes creates the indexes used by the movie queries.
+// CreateMany is idempotent, so it is safe to call on every start.
+func (r *MongoRepository) EnsureIndexes(ctx context.Context) error {
+	indexes := []mongo.IndexModel{
+		{
+			Keys:    bson.D{{Key: "movie_id", Value: 1}},
+			Options: options.Index().SetUnique(true).SetName(movieIDIndex),
+		},
+		{
+			// Movies imported from TMDB are upserted by their TMDB id
+			Keys: bson.D{{Key: "tmdb_id", Value: 1}},
+			Options: options.Index().
+				SetUnique(true).
+				SetPartialFilterExpression(bson.M{"tmdb_id": bson.M{"$gt": 0}}).
+				SetName(tmdbIDIndex),
+		},
+		{
+			Keys:    bson.D{{Key: "title", Value: 1}},
+			Options: options.Index().SetName("title"),
+		},
+		{
+			Keys:    bson.D{{Key: "search_keys", Value: 1}},
+			Options: options.Index().SetName("search_keys"),
+		},
+		{
+			Keys:    bson.D{{Key: "genres.id", Value: 1}},
+			Options: options.Index().SetName("genre_ids"),
+		},
+		{
+			Keys:    bson.D{{Key: "popularity", Value: -1}},
+			Options: options.Index().SetName("popularity"),
+		},
+		{
+			Keys:    bson.D{{Key: "release_date", Value: -1}},
+			Options: options.Index().SetName("release_date"),
+		},
+	}
+
+	if _, err := r.movies.Indexes().CreateMany(ctx, indexes); err != nil {
+		return err
+	}
+
+	// The search words were replaced by the search keys
+	var commandErr mongo.CommandError
+	if _, err := r.movies.Indexes().DropOne(ctx, "search_words"); err != nil && !(errors.As(err, &commandErr) && commandErr.Code == indexNotFoundCode) {
+		return err
+	}
+
+	return r.addMissingSearchKeys(ctx)
+}
+
+// addMissingSearchKeys sets the search keys of movies stored before they were kept.
+func (r *MongoRepository) addMissingSearchKeys(ctx context.Context) error {
+	cursor, err := r.movies.Find(ctx, bson.M{"search_keys": bson.M{"$exists": false}})
+	if err != nil {
+		return err
+	}
+	defer cursor.Close(ctx)
+
+	for cursor.Next(ctx) {
+		var movie models.Movie
+		if err := cursor.Decode(&movie); err != nil {
+			return err
+		}
+
+		update := bson.M{
+			"$set":   bson.M{"search_keys": search.Keys(&movie)},
+			"$unset": bson.M{"search_words": ""},
+		}
+		if _, err := r.movies.UpdateOne(ctx, bson.M{"movie_id": movie.Movie_id}, update); err != nil {
+			return err
+		}
+	}
+
+	return cursor.Err()
+}
+
+func (r *MongoRepository) SeedIfEmpty(ctx context.Context, movies []models.Movie) error {
+	count, err := r.movies.EstimatedDocumentCount(ctx)
+	if err != nil || count > 0 || len(movies) == 0 {
+		return err
+	}
+
+	documents := make([]interface{}, 0, len(movies))
+	for i := range movies {
+		documents = append(documents, newMovieDocument(&movies[i]))
+	}
+
+	_, err = r.movies.InsertMany(ctx, documents)
+	return err
+}
+
+func (r *MongoRepository) FindBySearchKeys(ctx context.Context, keys [][]string) ([]models.Movie, error) {
+	conditions := make(bson.A, 0, len(keys))
+	for _, termKeys := range keys {
+		conditions = append(conditions, bson.M{"search_keys": bson.M{"$in": termKeys}})
+	}
+
+	filter := bson.M{}
+	if len(conditions) > 0 {
+		filter["$and"] = conditions
+	}
+	opts := options.Find().
+		SetSort(bson.D{{Key: "movie_id", Value: 1}}).
+		SetProjection(bson.M{"search_keys": 0})
+
+	return r.find(ctx, filter, opts)
+}
+
+func (r *MongoRepository) List(ctx context.Context, filter models.MovieFilter, sort []pagination.SortField, params pagination.Params) ([]models.Movie, int64, error) {
+	query := movieFilterQuery(filter)
+
+	total, err := r.movies.CountDocuments(ctx, query)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	sortQuery := bson.D{}
+	for _, field := range sort {
+		direction := 1
+		if field.Descending {
+			direction = -1
+		}
+		sortQuery = append(sortQuery, bson.E{Key: field.Field, Value: direction})
+	}
+	// movie_id keeps the order stable between pages when sort keys tie
+	sortQuery = append(sortQuery, bson.E{Key: "movie_id", Value: 1})
+
+	opts := options.Find().
+		SetSort(sortQuery).
+		SetSkip(params.Skip()).
+		SetLimit(int64(params.Limit))
+
+	movies, err := r.find(ctx, query, opts)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	return movies, total, nil
+}
+
+func movieFilterQuery(filter models.MovieFilter) bson.M {
+	query := bson.M{}
+
+	if len(filter.Genre_ids) > 0 {
+		query["genres.id"] = bson.M{"$in": filter.Genre_ids}
+	}
+	if filter.Original_language != "" {
+		query["original_language"] = filter.Original_language
+	}
+	if filter.Adult != nil {
+		query["adult"] = *filter.Adult
+	}
+	if filter.Status != "" {
+		query["status"] = filter.Status
+	}
+
+	releaseDate := bson.M{}
+	if filter.Release_year_from > 0 {
+		releaseDate["$gte"] = time.Date(filter.Release_year_from, time.January, 1, 0, 0, 0, 0, time.UTC)
+	}
+	if filter.Release_year_to > 0 {
+		releaseDate["$lt"] = time.Date(filter.Release_year_to+1, time.January, 1, 0, 0, 0, 0, time.UTC)
+	}
+	if len(releaseDate) > 0 {
+		query["release_date"] = releaseDate
+	}
+
+	return query
+}
+
+func (r *MongoRepository) FindByID(ctx context.Context, movieID uint64) (*models.Movie, error) {
+	var movie models.Movie
+
+	err := r.movies.FindOne(ctx, bson.M{"movie_id": movieID}).Decode(&movie)
+	if err == mongo.ErrNoDocuments {
+		return nil, ErrMovieNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return &movie, nil
+}
+
+func (r *MongoRepository) FindByGenreIDs(ctx context.Context, genreIDs []uint64, excludeMovieID uint64) ([]models.Movie, error) {
+	if len(genreIDs) == 0 {
+		return []models.Movie{}, nil
+	}
+
+	filter := bson.M{
+		"genres.id": bson.M{"$in": genreIDs},
+		"movie_id":  bson.M{"$ne": excludeMovieID},
+	}
+	opts := options.Find().SetSort(bson.D{{Key: "movie_id", Value: 1}})
+
+	return r.find(ctx, filter, opts)
+}
+
+// nextMovieID returns one more than the highest movie_id currently stored.
+func (r *MongoRepository) nextMovieID(ctx context.Context) (uint64, error) {
+	var last models.Movie
+
+	opts := options.FindOne().SetSort(bson.D{{Key: "movie_id", Value: -1}})
+	err := r.movies.FindOne(ctx, bson.M{}, opts).Decode(&last)
+	if err == mongo.ErrNoDocuments {
+		return 1, nil
+	}
+	if err != nil {
+		return 0, err
+	}
+
+	return last.Movie_id + 1, nil
+}
+
+func (r *MongoRepository) Insert(ctx context.Context, movie *models.Movie) error {
+	_, err := r.movies.InsertOne(ctx, newMovieDocument(movie))
+	return duplicateKeyError(err)
+}
+
+func (r *MongoRepository) Replace(ctx context.Context, movie *models.Movie) error {
+	result, err := r.movies.ReplaceOne(ctx, bson.M{"movie_id": movie.Movie_id}, newMovieDocument(movie))
+	if err != nil {
+		return duplicateKeyError(err)
+	}
+	if result.MatchedCount == 0 {
+		return ErrMovieNotFound
+	}
+
+	return nil
+}
+
+func (r *MongoRepository) Delete(ctx context.Context, movieID uint64) error {
+	result, err := r.movies.DeleteOne(ctx, bson.M{"movie_id": movieID})
+	if err != nil {
+		return err
+	}
+	if result.DeletedCount == 0 {
+		return ErrMovieNotFound
+	}
+
+	return nil
+}
+
+func (r *MongoRepository) UpsertByTmdbID(ctx context.Context, movie *models.Movie) (created bool, err error) {
+	var existing models.Movie
+
+	err = r.movies.FindOne(ctx, bson.M{"tmdb_id": movie.Tmdb_id}).Decode(&existing)
+	if err == nil {
+		movie.Movie_id = existing.Movie_id
+		return false, r.Replace(ctx, movie)
+	}
+	if err != mongo.ErrNoDocuments {
+		return false, err
+	}
+
+	if err := r.InsertWithNewID(ctx, movie); err != nil {
+		return false, err
+	}
+
+	return true, nil
+}
+
+func (r *MongoRepository) InsertWithNewID(ctx context.Context, movie *models.Movie) (err error) {
+	// Another writer may claim the same movie_id concurrently, so retry a few times.
+	// A taken tmdb_id stays taken, so it is not retried.
+	for attempt := 0; attempt < 3; attempt++ {
+		movie.Movie_id, err = r.nextMovieID(ctx)
+		if err != nil {
+			return err
+		}
+
+		err = r.Insert(ctx, movie)
+		if err != ErrDuplicateMovieID {
+			return err
+		}
+	}
+
+	return err
+}
+
+// duplicateKeyError maps a duplicate key error to the error of the unique index it names.
+func duplicateKeyError(err error) error {
+	if !mongo.IsDuplicateKeyError(err) {
+		return err
+	}
+	if strings.Contains(err.Error(), "index: "+tmdbIDIndex) {
+		return ErrDuplicateTMDBID
+	}
+
+	return ErrDuplicateMovieID
+}
+
+func (r *MongoRepository) find(ctx context.Context, filter interface{}, opts *options.FindOptions) ([]models.Movie, error) {
+	cursor, err := r.movies.Find(ctx, filter, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	movies := []models.Movie{}
+	if err := cursor.All(ctx, &movies); err != nil {
+		return nil, err
+	}
+
+	return movies, nil
+}
@@ -0,0 +1,55 @@
+package repository
+
+import (
+	"context"
+	"errors"
+
+	"movie-api/api/pagination"
+	models "movie-api/api/resource/movie/model"
+)
+
+// ErrMovieNotFound is returned when no movie matches the requested movie_id.
+var ErrMovieNotFound = errors.New("movie not found")
+
+// ErrDuplicateMovieID is returned when inserting a movie whose movie_id is already taken.
+var ErrDuplicateMovieID = errors.New("movie_id already exists")
+
+// ErrDuplicateTMDBID is returned when storing a movie whose tmdb_id another movie has.
+var ErrDuplicateTMDBID = errors.New("tmdb_id already exists")
+
+// Repository stores the movie catalogue. MongoRepository is used in production;
+// MemoryRepository keeps the movies in process memory for tests and offline runs.
+type Repository interface {
+	// EnsureIndexes prepares the storage for the movie queries. It is safe to call on every start.
+	EnsureIndexes(ctx context.Context) error
+	// SeedIfEmpty inserts the given movies when no movie is stored yet.
+	SeedIfEmpty(ctx context.Context, movies []models.Movie) error
+	// FindBySearchKeys returns the movies that have, for each element of keys, at least
+	// one of its search keys among their own (see search.Keys), ordered by movie_id.
+	FindBySearchKeys(ctx context.Context, keys [][]string) ([]models.Movie, error)
+	// List returns one page of the movies matching filter in the given sort order,
+	// together with the number of matching movies across all pages. Ties are
+	// broken by movie_id so the order is stable between pages.
+	List(ctx context.Context, filter models.MovieFilter, sort []pagination.SortField, params pagination.Params) ([]models.Movie, int64, error)
+	// FindByID returns the movie with the given movie_id, or ErrMovieNotFound.
+	FindByID(ctx context.Context, movieID uint64) (*models.Movie, error)
+	// FindByGenreIDs returns the movies sharing at least one of genreIDs, excluding
+	// excludeMovieID, ordered by movie_id.
+	FindByGenreIDs(ctx context.Context, genreIDs []uint64, excludeMovieID uint64) ([]models.Movie, error)
+	// Insert stores a new movie, or returns ErrDuplicateMovieID if its movie_id is taken
+	// and ErrDuplicateTMDBID if its tmdb_id is.
+	Insert(ctx context.Context, movie *models.Movie) error
+	// InsertWithNewID stores a new movie under a newly allocated movie_id, one more than
+	// the highest stored, and sets it on movie. It returns ErrDuplicateTMDBID if the
+	// tmdb_id of movie is taken.
+	InsertWithNewID(ctx context.Context, movie *models.Movie) error
+	// Replace overwrites the stored movie that has the same movie_id, or returns
+	// ErrMovieNotFound. It returns ErrDuplicateTMDBID if another movie has the tmdb_id.
+	Replace(ctx context.Context, movie *models.Movie) error
+	// Delete removes the movie with the given movie_id, or returns ErrMovieNotFound.
+	Delete(ctx context.Context, movieID uint64) error
+	// UpsertByTmdbID stores movie keyed by its Tmdb_id. An existing movie keeps its
+	// movie_id and is replaced; otherwise a new movie_id is allocated. created reports
+	// which of the two happened.
+	UpsertByTmdbID(ctx context.Context, movie *models.Movie) (created bool, err error)
+}
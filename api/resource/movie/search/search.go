@@ -0,0 +1,315 @@
+package search
+
+import (
+	"sort"
+	"strings"
+	"unicode"
+
+	models "movie-api/api/resource/movie/model"
+
+	"golang.org/x/text/unicode/norm"
+)
+
+// Weight of a match in each searchable field
+const (
+	titleWeight    float64 = 10
+	peopleWeight   float64 = 5 // cast and director
+	writersWeight  float64 = 4
+	taglineWeight  float64 = 3
+	overviewWeight float64 = 1
+
+	// Bonus when the whole query appears as a phrase in the title
+	titlePhraseWeight float64 = 10
+)
+
+// How much of a field weight each kind of term match earns
+const (
+	exactMatch  float64 = 1.0
+	prefixMatch float64 = 0.8
+	fuzzyMatch  float64 = 0.6 // reduced further for each edit
+)
+
+// keyLength is the number of leading letters of a word its search keys are made of.
+// Letters after them never rule a match out, so they are left out of the keys.
+const keyLength = 6
+
+// Result is a movie matching a search, with its relevance score.
+type Result struct {
+	models.Movie
+	Score float64 `json:"score"`
+}
+
+// Query is a parsed search query. It only depends on the Movie model, so it can
+// score movies read from any storage.
+type Query struct {
+	terms  []string
+	phrase string
+}
+
+// NewQuery parses the raw search text into normalised terms.
+func NewQuery(text string) Query {
+	terms := Tokenize(text)
+	return Query{terms: terms, phrase: strings.Join(terms, " ")}
+}
+
+// Empty reports whether the query holds no searchable term.
+func (q Query) Empty() bool {
+	return len(q.terms) == 0
+}
+
+// Keys returns the search keys of every term. A movie Score matches has, for each
+// term, at least one of the term's keys among its own (see Keys), so storage can
+// narrow the movies worth scoring by them.
+func (q Query) Keys() [][]string {
+	keys := make([][]string, 0, len(q.terms))
+	for _, term := range q.terms {
+		head := keyHead(term)
+
+		// The term itself covers exact and prefix matches, its deletions typos
+		termKeys := append([]string{string(head)}, deletions(head, maxEdits(term))...)
+		keys = append(keys, distinct(termKeys))
+	}
+
+	return keys
+}
+
+// Score returns the relevance of movie for the query, or 0 when the movie does not
+// match. Every query term must match some field, either exactly, as a prefix of a
+// word (for autocomplete) or within a small edit distance (for typos).
+func (q Query) Score(movie *models.Movie) float64 {
+	fields := searchableFields(movie)
+
+	var score float64
+	for _, term := range q.terms {
+		var termScore float64
+		for _, field := range fields {
+			if match := bestMatch(term, field.words); match > 0 {
+				termScore += match * field.weight
+			}
+		}
+
+		// A term found nowhere rules the movie out
+		if termScore == 0 {
+			return 0
+		}
+		score += termScore
+	}
+
+	if len(q.terms) > 1 && strings.Contains(strings.Join(fields[0].words, " "), q.phrase) {
+		score += titlePhraseWeight
+	}
+
+	return score
+}
+
+// Keys returns the distinct search keys of the words of every searchable field of
+// movie, sorted, for storage to index. The keys of a word are its prefixes, which
+// prefix and exact matches share, and the strings left after deleting up to as many
+// letters as a term may have typos, which a term within that many typos shares.
+func Keys(movie *models.Movie) []string {
+	var keys []string
+	for _, field := range searchableFields(movie) {
+		for _, word := range field.words {
+			head := keyHead(word)
+			for length := min(2, len(head)); length <= len(head); length++ {
+				keys = append(keys, string(head[:length]))
+			}
+			keys = append(keys, deletions(head, maxTermEdits(len([]rune(word))))...)
+		}
+	}
+
+	keys = distinct(keys)
+	sort.Strings(keys)
+	return keys
+}
+
+// Rank orders results by score, then by popularity, then by movie_id.
+func Rank(results []Result) {
+	sort.SliceStable(results, func(i, j int) bool {
+		if results[i].Score != results[j].Score {
+			return results[i].Score > results[j].Score
+		}
+		if results[i].Popularity != results[j].Popularity {
+			return results[i].Popularity > results[j].Popularity
+		}
+		return results[i].Movie_id < results[j].Movie_id
+	})
+}
+
+// Tokenize lower-cases text, strips accents and splits it into words.
+func Tokenize(text string) []string {
+	var folded strings.Builder
+	for _, r := range norm.NFD.String(text) {
+		// Drop the combining marks left over from decomposing accented letters
+		if unicode.Is(unicode.Mn, r) {
+			continue
+		}
+		folded.WriteRune(unicode.ToLower(r))
+	}
+
+	return strings.FieldsFunc(folded.String(), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+}
+
+// bestMatch returns how well term matches the closest of words.
+func bestMatch(term string, words []string) float64 {
+	var best float64
+	allowedEdits := maxEdits(term)
+
+	for _, word := range words {
+		switch {
+		case word == term:
+			return exactMatch
+		case len(term) >= 2 && strings.HasPrefix(word, term):
+			best = max(best, prefixMatch)
+		case allowedEdits > 0:
+			if edits := editDistance(term, word, allowedEdits); edits <= allowedEdits {
+				best = max(best, fuzzyMatch-0.1*float64(edits-1))
+			}
+		}
+	}
+
+	return best
+}
+
+// maxTermEdits is the most typos a term matching a word of the given length can have:
+// two for terms of at least eight letters, which are at least six letters long words
+// with those typos, and one for terms of four to seven letters.
+func maxTermEdits(wordLength int) int {
+	switch {
+	case wordLength >= 6:
+		return 2
+	case wordLength >= 3:
+		return 1
+	default:
+		return 0
+	}
+}
+
+// keyHead returns the leading letters of word its search keys are made of.
+func keyHead(word string) []rune {
+	runes := []rune(word)
+	return runes[:min(len(runes), keyLength)]
+}
+
+// deletions returns the strings left after deleting one to edits letters of word.
+// Two words within edits typos of each other share one of them, or one of them is
+// the other word: with every typo undone by deleting a letter of either word, the
+// letters they have in common are left.
+func deletions(word []rune, edits int) []string {
+	var result []string
+	current := [][]rune{word}
+	for ; edits > 0; edits-- {
+		var next [][]rune
+		for _, runes := range current {
+			for i := range runes {
+				deleted := append(append([]rune{}, runes[:i]...), runes[i+1:]...)
+				next = append(next, deleted)
+				result = append(result, string(deleted))
+			}
+		}
+		current = next
+	}
+
+	return result
+}
+
+// distinct returns values without repetitions, in the order they first appear.
+func distinct(values []string) []string {
+	seen := map[string]bool{}
+	result := values[:0]
+	for _, value := range values {
+		if !seen[value] {
+			seen[value] = true
+			result = append(result, value)
+		}
+	}
+
+	return result
+}
+
+// maxEdits is the number of typos tolerated in a term of the given length.
+func maxEdits(term string) int {
+	switch length := len([]rune(term)); {
+	case length >= 8:
+		return 2
+	case length >= 4:
+		return 1
+	default:
+		return 0
+	}
+}
+
+// editDistance returns the optimal string alignment distance between a and b
+// (insertions, deletions, substitutions and adjacent transpositions). Once the
+// distance is known to exceed limit it returns limit+1.
+func editDistance(a, b string, limit int) int {
+	ra, rb := []rune(a), []rune(b)
+	if diff := len(ra) - len(rb); diff > limit || -diff > limit {
+		return limit + 1
+	}
+
+	// Three rolling rows are enough for the transposition lookback
+	previous2 := make([]int, len(rb)+1)
+	previous := make([]int, len(rb)+1)
+	current := make([]int, len(rb)+1)
+	for j := range previous {
+		previous[j] = j
+	}
+
+	for i := 1; i <= len(ra); i++ {
+		current[0] = i
+		rowMinimum := current[0]
+
+		for j := 1; j <= len(rb); j++ {
+			cost := 1
+			if ra[i-1] == rb[j-1] {
+				cost = 0
+			}
+
+			current[j] = min(previous[j]+1, current[j-1]+1, previous[j-1]+cost)
+			if i > 1 && j > 1 && ra[i-1] == rb[j-2] && ra[i-2] == rb[j-1] {
+				current[j] = min(current[j], previous2[j-2]+1)
+			}
+
+			rowMinimum = min(rowMinimum, current[j])
+		}
+
+		if rowMinimum > limit {
+			return limit + 1
+		}
+
+		previous2, previous, current = previous, current, previous2
+	}
+
+	return previous[len(rb)]
+}
+
+type searchableField struct {
+	weight float64
+	words  []string
+}
+
+// searchableFields returns the words of every field Score looks at, title first.
+func searchableFields(movie *models.Movie) []searchableField {
+	return []searchableField{
+		{titleWeight, Tokenize(movie.Title)},
+		{peopleWeight, Tokenize(fullNames(append([]models.FullName{movie.Director}, movie.Cast...)))},
+		{writersWeight, Tokenize(fullNames(movie.Writers))},
+		{taglineWeight, Tokenize(strings.Join(movie.Tagline, " "))},
+		{overviewWeight, Tokenize(movie.Overview)},
+	}
+}
+
+func fullNames(names []models.FullName) string {
+	var text strings.Builder
+	for _, name := range names {
+		text.WriteString(name.First_name)
+		text.WriteByte(' ')
+		text.WriteString(name.Last_name)
+		text.WriteByte(' ')
+	}
+
+	return text.String()
+}
@@ -0,0 +1,256 @@
+package search
+
+import (
+	"math/rand"
+	"reflect"
+	"testing"
+
+	models "movie-api/api/resource/movie/model"
+)
+
+var fightClub = models.Movie{
+	Movie_id:   1,
+	Title:      "Fight Club",
+	Overview:   "A ticking-time-bomb insomniac and a slippery soap salesman channel primal male aggression.",
+	Tagline:    []string{"Mischief. Mayhem. Soap."},
+	Popularity: 60,
+	Director:   models.FullName{First_name: "David", Last_name: "Fincher"},
+	Cast:       []models.FullName{{First_name: "Edward", Last_name: "Norton"}, {First_name: "Brad", Last_name: "Pitt"}},
+	Writers:    []models.FullName{{First_name: "Chuck", Last_name: "Palahniuk"}},
+}
+
+var amelie = models.Movie{
+	Movie_id:   2,
+	Title:      "Le Fabuleux Destin d'Amélie Poulain",
+	Overview:   "At a tiny Parisian café, the adorable yet painfully shy Amélie accidentally discovers a gift for helping others.",
+	Popularity: 40,
+	Director:   models.FullName{First_name: "Jean-Pierre", Last_name: "Jeunet"},
+}
+
+func TestTokenize(t *testing.T) {
+	got := Tokenize("Le Fabuleux Destin d'Amélie -- CAFÉ 2001!")
+	want := []string{"le", "fabuleux", "destin", "d", "amelie", "cafe", "2001"}
+
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Tokenize = %q, want %q", got, want)
+	}
+}
+
+func TestScoreMatches(t *testing.T) {
+	tests := []struct {
+		name  string
+		query string
+		movie models.Movie
+	}{
+		{"exact title word", "club", fightClub},
+		{"title prefix", "figh", fightClub},
+		{"typo", "fihgt", fightClub},
+		{"two typos in a long word", "palahnuik", fightClub},
+		{"cast", "norton", fightClub},
+		{"director", "fincher", fightClub},
+		{"tagline", "mayhem", fightClub},
+		{"overview", "insomniac", fightClub},
+		{"accents are ignored", "amelie", amelie},
+		{"accents in the query are ignored", "Parisiän", amelie},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if score := NewQuery(tt.query).Score(&tt.movie); score <= 0 {
+				t.Errorf("Score(%q) = %v, want a match", tt.query, score)
+			}
+		})
+	}
+}
+
+func TestScoreRejects(t *testing.T) {
+	tests := []struct {
+		name  string
+		query string
+	}{
+		{"unrelated word", "matrix"},
+		{"one term matching nothing", "fight matrix"},
+		{"typo in a short word", "clb"},
+		{"single letter prefix", "f"},
+		{"too many typos", "fxghx"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if score := NewQuery(tt.query).Score(&fightClub); score != 0 {
+				t.Errorf("Score(%q) = %v, want 0", tt.query, score)
+			}
+		})
+	}
+}
+
+func TestScoreOrdersMatchKinds(t *testing.T) {
+	exact := NewQuery("fight").Score(&fightClub)
+	prefix := NewQuery("figh").Score(&fightClub)
+	fuzzy := NewQuery("fihgt").Score(&fightClub)
+
+	if !(exact > prefix && prefix > fuzzy) {
+		t.Errorf("exact %v, prefix %v, fuzzy %v: want exact > prefix > fuzzy", exact, prefix, fuzzy)
+	}
+
+	// A title match outweighs a match in the overview
+	title := NewQuery("club").Score(&fightClub)
+	overview := NewQuery("soap").Score(&fightClub)
+	if title <= overview {
+		t.Errorf("title match %v, overview match %v: want title > overview", title, overview)
+	}
+}
+
+func TestScoreTitlePhraseBonus(t *testing.T) {
+	inOrder := NewQuery("fight club").Score(&fightClub)
+	reversed := NewQuery("club fight").Score(&fightClub)
+
+	if inOrder-reversed != titlePhraseWeight {
+		t.Errorf("phrase %v, reversed %v: want the phrase bonus %v between them", inOrder, reversed, titlePhraseWeight)
+	}
+}
+
+func TestEmptyQuery(t *testing.T) {
+	for _, text := range []string{"", "  ", "?!"} {
+		if !NewQuery(text).Empty() {
+			t.Errorf("NewQuery(%q) is not empty", text)
+		}
+	}
+}
+
+func TestEditDistance(t *testing.T) {
+	tests := []struct {
+		a, b  string
+		limit int
+		want  int
+	}{
+		{"fight", "fight", 2, 0},
+		{"fihgt", "fight", 2, 1}, // transposition
+		{"figt", "fight", 2, 1},  // deletion
+		{"fights", "fight", 2, 1},
+		{"fxghx", "fight", 2, 2},
+		{"abcdef", "fight", 2, 3}, // over the limit
+		{"a", "fight", 2, 3},      // length difference over the limit
+	}
+
+	for _, tt := range tests {
+		if got := editDistance(tt.a, tt.b, tt.limit); got != tt.want {
+			t.Errorf("editDistance(%q, %q, %d) = %d, want %d", tt.a, tt.b, tt.limit, got, tt.want)
+		}
+	}
+}
+
+func TestRank(t *testing.T) {
+	results := []Result{
+		{Movie: models.Movie{Movie_id: 3, Popularity: 10}, Score: 5},
+		{Movie: models.Movie{Movie_id: 2, Popularity: 50}, Score: 5},
+		{Movie: models.Movie{Movie_id: 1, Popularity: 10}, Score: 5},
+		{Movie: models.Movie{Movie_id: 4, Popularity: 1}, Score: 9},
+	}
+
+	Rank(results)
+
+	var order []uint64
+	for _, result := range results {
+		order = append(order, result.Movie_id)
+	}
+	if want := []uint64{4, 2, 1, 3}; !reflect.DeepEqual(order, want) {
+		t.Errorf("order = %v, want %v", order, want)
+	}
+}
+
+func TestQueryKeys(t *testing.T) {
+	keys := NewQuery("f club Palahniuk").Keys()
+
+	if want := []string{"f"}; !reflect.DeepEqual(keys[0], want) {
+		t.Errorf("keys of a single letter = %q, want %q", keys[0], want)
+	}
+	if want := []string{"club", "lub", "cub", "clb", "clu"}; !reflect.DeepEqual(keys[1], want) {
+		t.Errorf("keys of a term allowed one typo = %q, want %q", keys[1], want)
+	}
+	if keys[2][0] != "palahn" || !contains(keys[2], "plhn") {
+		t.Errorf("keys of a term allowed two typos = %q, want its first six letters and their deletions", keys[2])
+	}
+}
+
+func TestKeysCoverEveryQueryMatch(t *testing.T) {
+	keys := Keys(&fightClub)
+	for i := 1; i < len(keys); i++ {
+		if keys[i-1] >= keys[i] {
+			t.Fatalf("Keys are not sorted and distinct: %q", keys)
+		}
+	}
+
+	// Typos in the first letters are found too
+	for _, text := range []string{"club", "figh", "fihgt club", "ifght", "gfiht", "clbu", "norton", "palahnuik", "aplahniuk", "mayhem"} {
+		if !sharesKeys(NewQuery(text), keys) {
+			t.Errorf("%q matches but has a term sharing no key with the movie", text)
+		}
+	}
+}
+
+func TestKeysCoverRandomMatches(t *testing.T) {
+	random := rand.New(rand.NewSource(1))
+	letters := []rune("abcde")
+	randomWord := func(length int) string {
+		word := make([]rune, length)
+		for i := range word {
+			word[i] = letters[random.Intn(len(letters))]
+		}
+		return string(word)
+	}
+
+	for i := 0; i < 20000; i++ {
+		word := []rune(randomWord(1 + random.Intn(12)))
+
+		// A prefix of the word, or the word with up to two typos
+		term := append([]rune{}, word[:1+random.Intn(len(word))]...)
+		if random.Intn(2) == 0 {
+			term = append([]rune{}, word...)
+			for edits := random.Intn(3); edits > 0 && len(term) > 1; edits-- {
+				at := random.Intn(len(term) - 1)
+				switch random.Intn(4) {
+				case 0: // insertion
+					term = append(term[:at], append([]rune{letters[random.Intn(len(letters))]}, term[at:]...)...)
+				case 1: // deletion
+					term = append(term[:at], term[at+1:]...)
+				case 2: // substitution
+					term[at] = letters[random.Intn(len(letters))]
+				case 3: // transposition
+					term[at], term[at+1] = term[at+1], term[at]
+				}
+			}
+		}
+
+		movie := models.Movie{Title: string(word)}
+		query := NewQuery(string(term))
+		if query.Score(&movie) > 0 && !sharesKeys(query, Keys(&movie)) {
+			t.Fatalf("%q matches %q but shares no key with it", string(term), string(word))
+		}
+	}
+}
+
+// sharesKeys reports whether every term of query has a key among keys.
+func sharesKeys(query Query, keys []string) bool {
+	for _, termKeys := range query.Keys() {
+		found := false
+		for _, key := range termKeys {
+			found = found || contains(keys, key)
+		}
+		if !found {
+			return false
+		}
+	}
+
+	return true
+}
+
+func contains(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,107 @@
+package handler
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	middleware "movie-api/api/middleware"
+	helper "movie-api/api/resource/user/helpers"
+	models "movie-api/api/resource/user/model"
+	"movie-api/api/resource/user/repository"
+
+	"github.com/gin-gonic/gin"
+)
+
+// GetAPIKeys responds with the API keys of the user whose ID matches the user_id
+// parameter, newest first. The keys themselves are never shown again.
+func (h *Handler) GetAPIKeys() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		apiKeys, err := h.Auth.ListAPIKeys(c.Request.Context(), c.Param("user_id"))
+		if err != nil {
+			c.IndentedJSON(http.StatusInternalServerError, gin.H{"message": err.Error()})
+			return
+		}
+
+		c.IndentedJSON(http.StatusOK, apiKeys)
+	}
+}
+
+// CreateAPIKey creates an API key for the user whose ID matches the user_id parameter.
+// Its scopes must be permissions the user's role has. The response is the only time
+// the key is shown.
+func (h *Handler) CreateAPIKey() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var request models.CreateAPIKeyRequest
+
+		if err := c.ShouldBindJSON(&request); err != nil {
+			c.IndentedJSON(http.StatusBadRequest, gin.H{"message": err.Error()})
+			return
+		}
+
+		if validationErr := h.Validate.Struct(request); validationErr != nil {
+			c.IndentedJSON(http.StatusBadRequest, gin.H{"message": validationErr.Error()})
+			return
+		}
+
+		owner, err := h.Auth.Users.FindByID(c.Request.Context(), c.Param("user_id"), false)
+		if errors.Is(err, repository.ErrUserNotFound) {
+			c.IndentedJSON(http.StatusNotFound, gin.H{"message": "User not found"})
+			return
+		}
+		if err != nil {
+			c.IndentedJSON(http.StatusInternalServerError, gin.H{"message": err.Error()})
+			return
+		}
+
+		// A key cannot do more than its owner
+		for _, scope := range request.Scopes {
+			permission := middleware.Permission(scope)
+			if _, ok := middleware.Policy[permission]; !ok {
+				c.IndentedJSON(http.StatusBadRequest, gin.H{"message": fmt.Sprintf("Unknown scope %q", scope)})
+				return
+			}
+			if owner.User_type == nil || !middleware.HasPermission(*owner.User_type, permission) {
+				c.IndentedJSON(http.StatusBadRequest, gin.H{"message": fmt.Sprintf("The user does not have the %s permission", scope)})
+				return
+			}
+		}
+
+		now := time.Now()
+		expiresAt := now.Add(helper.APIKeyDefaultLifetime)
+		if request.Expires_at != nil {
+			expiresAt = *request.Expires_at
+		}
+		if !expiresAt.After(now) || expiresAt.After(now.Add(helper.APIKeyMaxLifetime)) {
+			c.IndentedJSON(http.StatusBadRequest, gin.H{"message": fmt.Sprintf("expires_at must be in the future and at most %s away", helper.APIKeyMaxLifetime)})
+			return
+		}
+
+		created, err := h.Auth.CreateAPIKey(c.Request.Context(), owner.User_id, request.Name, request.Scopes, expiresAt)
+		if err != nil {
+			c.IndentedJSON(http.StatusInternalServerError, gin.H{"message": err.Error()})
+			return
+		}
+
+		c.IndentedJSON(http.StatusCreated, created)
+	}
+}
+
+// RevokeAPIKey revokes the API key whose ID matches the key_id parameter. It stops
+// working immediately but stays listed.
+func (h *Handler) RevokeAPIKey() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		found, err := h.Auth.RevokeAPIKey(c.Request.Context(), c.Param("user_id"), c.Param("key_id"))
+		if err != nil {
+			c.IndentedJSON(http.StatusInternalServerError, gin.H{"message": err.Error()})
+			return
+		}
+		if !found {
+			c.IndentedJSON(http.StatusNotFound, gin.H{"message": "API key not found"})
+			return
+		}
+
+		c.IndentedJSON(http.StatusOK, gin.H{"message": "API key revoked"})
+	}
+}
@@ -2,20 +2,25 @@ package handler
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"log"
+	"math"
 	"net/http"
+	"strconv"
 	"time"
 
-	"movie-api/api/database"
+	"movie-api/api/loginguard"
+	"movie-api/api/mail"
+	middleware "movie-api/api/middleware"
+	"movie-api/api/pagination"
 	helper "movie-api/api/resource/user/helpers"
 	models "movie-api/api/resource/user/model"
+	"movie-api/api/resource/user/repository"
 
 	"github.com/gin-gonic/gin"
 	"github.com/go-playground/validator/v10"
-	"go.mongodb.org/mongo-driver/bson"
 	"go.mongodb.org/mongo-driver/bson/primitive"
-	"go.mongodb.org/mongo-driver/mongo"
 	"golang.org/x/crypto/bcrypt"
 )
 
@@ -25,18 +30,29 @@ const (
 	DefaultCost int = 10 // the cost that will actually be set if a cost below MinCost is passed into GenerateFromPassword
 )
 
-var userCollection *mongo.Collection = database.OpenCollection(database.Client, "user")
+// Handler serves the auth, user and admin endpoints of one API instance.
+type Handler struct {
+	Auth *helper.Service
 
-// Use a single instance of Validate, it caches struct info
-var validate *validator.Validate = validator.New()
+	// Access answers permission questions whose outcome changes a response rather than
+	// refusing the request, in the same way as the route middleware.
+	Access *middleware.Auth
 
-var rootContext = context.Background()
+	// Use a single instance of Validate, it caches struct info
+	Validate *validator.Validate
 
-// Handle password hashing
-func HashPassword(password string) string {
+	Logger *log.Logger
+}
+
+func New(auth *helper.Service, access *middleware.Auth, validate *validator.Validate, logger *log.Logger) *Handler {
+	return &Handler{Auth: auth, Access: access, Validate: validate, Logger: logger}
+}
+
+// Handle password hashing at the configured cost
+func (h *Handler) HashPassword(password string) string {
 	// GenerateFromPassword returns the bcrypt hash of the password at the given cost.
 	// If the cost given is less than MinCost, the cost will be set to DefaultCost
-	bytes, err := bcrypt.GenerateFromPassword([]byte(password), DefaultCost)
+	bytes, err := bcrypt.GenerateFromPassword([]byte(password), h.Auth.BcryptCost)
 	if err != nil {
 		log.Panic(err)
 	}
@@ -59,27 +75,45 @@ func VerifyPassword(hashedUserPassword, providedClearTextPassword string) (bool,
 	return check, msg
 }
 
-func LoginUser() gin.HandlerFunc {
+func (h *Handler) LoginUser() gin.HandlerFunc {
 	return func(c *gin.Context) {
-		var user models.User
+		var request models.LoginRequest
 		var foundUser models.User
 
-		if err := c.ShouldBindJSON(&user); err != nil {
-			log.Println("Error Here: ", err.Error())
-			c.IndentedJSON(http.StatusInternalServerError, gin.H{"message": err.Error()})
+		if err := c.ShouldBindJSON(&request); err != nil {
+			c.IndentedJSON(http.StatusBadRequest, gin.H{"message": err.Error()})
+			return
+		}
+
+		if validationErr := h.Validate.Struct(request); validationErr != nil {
+			c.IndentedJSON(http.StatusBadRequest, gin.H{"message": validationErr.Error()})
+			return
+		}
+
+		// Refuse attempts while the account or the client is backing off
+		if !h.checkLoginGuard(c, *request.Email_address) {
 			return
 		}
 
 		// Find user with email address in the user DB
-		err := userCollection.FindOne(rootContext, bson.M{"email_address": user.Email_address}).Decode(&foundUser)
-		if err != nil {
+		foundUser, err := h.Auth.Users.FindByEmail(c.Request.Context(), *request.Email_address, false)
+		if errors.Is(err, repository.ErrUserNotFound) {
+			h.recordFailedLogin(c, *request.Email_address, loginguard.ReasonUnknownEmail)
 			c.IndentedJSON(http.StatusBadRequest, gin.H{"message": "Email or password is incorrect"})
 			return
 		}
+		if err != nil {
+			c.IndentedJSON(http.StatusInternalServerError, gin.H{"message": err.Error()})
+			return
+		}
 
-		passwordIsValid, msg := VerifyPassword(*user.Password, *foundUser.Password)
+		passwordIsValid, msg := false, "Email or password is incorrect"
+		if foundUser.Password != nil {
+			passwordIsValid, msg = VerifyPassword(*request.Password, *foundUser.Password)
+		}
 		// Password is invalid
 		if !passwordIsValid {
+			h.recordFailedLogin(c, *request.Email_address, loginguard.ReasonWrongPassword)
 			c.IndentedJSON(http.StatusBadRequest, gin.H{"message": msg})
 			return
 		}
@@ -90,123 +124,824 @@ func LoginUser() gin.HandlerFunc {
 			return
 		}
 
-		// Generate tokens
-		token, refreshToken, _ := helper.GenerateAllTokens(*foundUser.Email_address, *foundUser.First_name, *foundUser.Last_name, *foundUser.User_type, *&foundUser.User_id)
-		helper.UpdateAllTokens(token, refreshToken, foundUser.User_id)
-
-		err = userCollection.FindOne(rootContext, bson.M{"user_id": foundUser.User_id}).Decode(&foundUser)
-		if err != nil {
-			c.IndentedJSON(http.StatusInternalServerError, gin.H{"message": err.Error()})
+		// With two-factor authentication the tokens are only issued by LoginMFA
+		if foundUser.Mfa_enabled {
+			h.respondWithMFAChallenge(c, foundUser)
 			return
 		}
 
-		// Return logged in user
-		c.IndentedJSON(http.StatusOK, foundUser)
+		h.completeLogin(c, foundUser)
 	}
 }
 
-func RegisterUser() gin.HandlerFunc {
+// respondWithMFAChallenge answers a login of a user with two-factor authentication
+// with the challenge token LoginMFA exchanges for the user's tokens.
+func (h *Handler) respondWithMFAChallenge(c *gin.Context, foundUser models.User) {
+	mfaToken, err := h.Auth.GenerateMFAChallengeToken(foundUser)
+	if err != nil {
+		c.IndentedJSON(http.StatusInternalServerError, gin.H{"message": err.Error()})
+		return
+	}
+
+	c.IndentedJSON(http.StatusOK, models.MFAChallengeResponse{
+		Mfa_required: true,
+		Mfa_token:    mfaToken,
+		Expires_in:   int64(helper.MFAChallengeTokenLifetime.Seconds()),
+	})
+}
+
+// completeLogin resets the failed login counter, starts a session and responds
+// with the logged in user and the session's tokens.
+func (h *Handler) completeLogin(c *gin.Context, foundUser models.User) {
+	h.recordSuccessfulLogin(c, *foundUser.Email_address)
+
+	// Generate tokens
+	token, refreshToken, mfaEnrollmentRequired, err := h.startSession(c, foundUser)
+	if err != nil {
+		c.IndentedJSON(http.StatusInternalServerError, gin.H{"message": err.Error()})
+		return
+	}
+
+	// Return logged in user
+	c.IndentedJSON(http.StatusOK, h.newAuthResponse(foundUser, token, refreshToken, mfaEnrollmentRequired))
+}
+
+// checkLoginGuard responds 429 and returns false while the account or the client is
+// backing off after failed logins. Every password check goes through it first.
+func (h *Handler) checkLoginGuard(c *gin.Context, emailAddress string) bool {
+	decision, err := h.Auth.LoginGuard.Check(c.Request.Context(), emailAddress, c.ClientIP())
+	if err != nil {
+		c.IndentedJSON(http.StatusInternalServerError, gin.H{"message": err.Error()})
+		return false
+	}
+	if !decision.Allowed {
+		c.Header("Retry-After", fmt.Sprintf("%.0f", math.Ceil(decision.Retry_after.Seconds())))
+		msg := "Too many failed login attempts. Please try again later"
+		if decision.Locked {
+			msg = "This account is temporarily locked after too many failed login attempts"
+		}
+		c.IndentedJSON(http.StatusTooManyRequests, gin.H{"message": msg})
+		return false
+	}
+
+	return true
+}
+
+// recordSuccessfulLogin resets the failed login counter of the account. Errors are
+// only logged so the client still gets the usual answer.
+func (h *Handler) recordSuccessfulLogin(c *gin.Context, emailAddress string) {
+	if err := h.Auth.LoginGuard.RecordSuccess(c.Request.Context(), emailAddress); err != nil {
+		h.Logger.Println("Error resetting failed login counter: ", err)
+	}
+}
+
+// recordFailedLogin counts a failed login. Errors are only logged so the
+// client still gets the usual answer.
+func (h *Handler) recordFailedLogin(c *gin.Context, emailAddress, reason string) {
+	if err := h.Auth.LoginGuard.RecordFailure(c.Request.Context(), emailAddress, c.ClientIP(), reason); err != nil {
+		h.Logger.Println("Error recording failed login: ", err)
+	}
+}
+
+func (h *Handler) RegisterUser() gin.HandlerFunc {
 	return func(c *gin.Context) {
-		var user models.User
+		var request models.RegisterRequest
 
-		// Bind JSON request body to User struct.
+		// Bind JSON request body to RegisterRequest struct.
 		// See https://github.com/iden3/go-iden3-servers/issues/6 for information
-		if err := c.ShouldBindJSON(&user); err != nil {
+		if err := c.ShouldBindJSON(&request); err != nil {
 			c.IndentedJSON(http.StatusBadRequest, gin.H{"message": err.Error()})
 			return
 		}
 
 		// Returns InvalidValidationError for bad validation input, nil or ValidationErrors ( []FieldError )
-		validationErr := validate.Struct(user)
+		validationErr := h.Validate.Struct(request)
 		if validationErr != nil {
 			c.IndentedJSON(http.StatusBadRequest, gin.H{"message": validationErr.Error()})
 			return
 		}
 
+		// The role is never taken from the request, so nobody can sign up as an admin
+		userType := middleware.RoleUser
+		user := models.User{
+			First_name:    request.First_name,
+			Last_name:     request.Last_name,
+			Profile_photo: request.Profile_photo,
+			Password:      request.Password,
+			Email_address: request.Email_address,
+			Phone_number:  request.Phone_number,
+			User_type:     &userType,
+		}
+
 		// Check if there's a user with the same email address.
-		// If count > 0, means email address already exist
-		emailCount, err := userCollection.CountDocuments(rootContext, bson.M{"email_address": user.Email_address})
+		emailExists, err := h.Auth.Users.EmailExists(c.Request.Context(), *user.Email_address)
 		if err != nil {
-			log.Panic(err)
-			c.JSON(http.StatusInternalServerError, gin.H{"message": "Error occurred while checking for user email!"})
+			c.IndentedJSON(http.StatusInternalServerError, gin.H{"message": "Error occurred while checking for user email!"})
 			return
 		}
 
-		if emailCount > 0 {
+		if emailExists {
 			c.IndentedJSON(http.StatusBadRequest, gin.H{"message": "Email already exists!"})
+			return
 		}
 
 		// Hash password
-		password := HashPassword(*user.Password)
+		password := h.HashPassword(*user.Password)
 		user.Password = &password
 
 		// Check if there's a user with the same phone number.
-		// If count > 0, means phone number already exist
-		phoneNumberCount, err := userCollection.CountDocuments(rootContext, bson.M{"phone_number": user.Phone_number})
+		phoneNumberExists, err := h.Auth.Users.PhoneNumberExists(c.Request.Context(), *user.Phone_number, "")
 		if err != nil {
-			log.Panic(err)
-			c.JSON(http.StatusInternalServerError, gin.H{"message": "Error occurred while checking for user phone number!"})
+			c.IndentedJSON(http.StatusInternalServerError, gin.H{"message": "Error occurred while checking for user phone number!"})
 			return
 		}
 
-		if phoneNumberCount > 0 {
+		if phoneNumberExists {
 			c.IndentedJSON(http.StatusBadRequest, gin.H{"message": "Phone number already exists!"})
+			return
 		}
 
 		user.Created_at, _ = time.Parse(time.RFC3339, time.Now().Format(time.RFC3339))
 		user.Updated_at, _ = time.Parse(time.RFC3339, time.Now().Format(time.RFC3339))
 		user.ID = primitive.NewObjectID()
 		user.User_id = user.ID.Hex()
-		token, refreshToken, _ := helper.GenerateAllTokens(*user.Email_address, *user.First_name, *user.Last_name, *user.User_type, *&user.User_id)
-		user.Token = &token
-		user.Refresh_token = &refreshToken
 
-		resultInsertionNumber, insertErr := userCollection.InsertOne(rootContext, user)
-		if insertErr != nil {
+		// New accounts start unverified; the verification email is sent below
+		emailVerified := false
+		user.Email_verified = &emailVerified
+		user.Verification_sent_at = &user.Created_at
+
+		// The checks above can race with another sign-up; the unique indexes cannot
+		insertErr := h.Auth.Users.Insert(c.Request.Context(), user)
+		switch {
+		case errors.Is(insertErr, repository.ErrDuplicateEmail):
+			c.IndentedJSON(http.StatusBadRequest, gin.H{"message": "Email already exists!"})
+			return
+		case errors.Is(insertErr, repository.ErrDuplicatePhoneNumber):
+			c.IndentedJSON(http.StatusBadRequest, gin.H{"message": "Phone number already exists!"})
+			return
+		case insertErr != nil:
 			msg := fmt.Sprintf("User was not created")
 			c.IndentedJSON(http.StatusInternalServerError, gin.H{"message": msg})
 			return
 		}
 
+		token, refreshToken, mfaEnrollmentRequired, err := h.startSession(c, user)
+		if err != nil {
+			c.IndentedJSON(http.StatusInternalServerError, gin.H{"message": err.Error()})
+			return
+		}
+
+		// The user can ask for another email if this one does not arrive
+		if err := h.Auth.SendVerificationEmail(c.Request.Context(), *user.Email_address, user.User_id); err != nil {
+			h.Logger.Println("Error sending verification email: ", err)
+		}
+
 		// Return user
-		c.IndentedJSON(http.StatusOK, resultInsertionNumber)
+		c.IndentedJSON(http.StatusCreated, h.newAuthResponse(user, token, refreshToken, mfaEnrollmentRequired))
+	}
+}
+
+// RefreshTokens exchanges a valid refresh token for a new access/refresh token pair.
+// The presented refresh token is invalidated. Presenting a refresh token that was
+// already exchanged is treated as theft: all of the user's tokens are revoked.
+func (h *Handler) RefreshTokens() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var request models.RefreshTokenRequest
+
+		if err := c.ShouldBindJSON(&request); err != nil {
+			c.IndentedJSON(http.StatusBadRequest, gin.H{"message": err.Error()})
+			return
+		}
+
+		if validationErr := h.Validate.Struct(request); validationErr != nil {
+			c.IndentedJSON(http.StatusBadRequest, gin.H{"message": validationErr.Error()})
+			return
+		}
+
+		claims, msg := h.Auth.ValidateRefreshToken(request.Refresh_token)
+		if msg != "" {
+			c.IndentedJSON(http.StatusUnauthorized, gin.H{"message": msg})
+			return
+		}
+
+		foundUser, err := h.Auth.Users.FindByID(c.Request.Context(), claims.User_id, false)
+		if errors.Is(err, repository.ErrUserNotFound) {
+			c.IndentedJSON(http.StatusUnauthorized, gin.H{"message": "User not found"})
+			return
+		}
+		if err != nil {
+			c.IndentedJSON(http.StatusInternalServerError, gin.H{"message": err.Error()})
+			return
+		}
+
+		// Refresh tokens from before sessions were introduced cannot be rotated, and
+		// those of an older token generation were revoked with all others
+		if claims.Session_id == "" || claims.Token_generation != foundUser.Token_generation {
+			c.IndentedJSON(http.StatusUnauthorized, gin.H{"message": "Refresh token has been revoked. Please log in again"})
+			return
+		}
+
+		mfaEnrollmentRequired, err := h.Auth.MFAEnrollmentRequired(c.Request.Context(), foundUser)
+		if err != nil {
+			c.IndentedJSON(http.StatusInternalServerError, gin.H{"message": err.Error()})
+			return
+		}
+
+		token, refreshToken, err := h.Auth.GenerateAllTokens(foundUser, claims.Session_id, mfaEnrollmentRequired)
+		if err != nil {
+			c.IndentedJSON(http.StatusInternalServerError, gin.H{"message": err.Error()})
+			return
+		}
+
+		found, rotated, err := h.Auth.RotateSessionRefreshToken(c.Request.Context(), claims.Session_id, request.Refresh_token, refreshToken, c.Request.UserAgent(), c.ClientIP())
+		if err != nil {
+			c.IndentedJSON(http.StatusInternalServerError, gin.H{"message": err.Error()})
+			return
+		}
+
+		// Logging out ends the session
+		if !found {
+			c.IndentedJSON(http.StatusUnauthorized, gin.H{"message": "Refresh token has been revoked. Please log in again"})
+			return
+		}
+
+		if !rotated {
+			// A validly signed refresh token that is no longer the session's has been
+			// used before, so whoever holds the newer tokens may not be the user.
+			if err := h.Auth.RevokeAllTokens(c.Request.Context(), foundUser.User_id); err != nil {
+				h.Logger.Println("Error revoking tokens after refresh token reuse: ", err)
+			}
+
+			c.IndentedJSON(http.StatusUnauthorized, gin.H{"message": "Refresh token has already been used. Please log in again"})
+			return
+		}
+
+		c.IndentedJSON(http.StatusOK, h.newTokenPair(token, refreshToken))
 	}
 }
 
-func GetUsers() {}
+// LogoutUser revokes the access token used for the request and ends its session.
+func (h *Handler) LogoutUser() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		claims := c.MustGet("claims").(*helper.SignedDetails)
+
+		if err := h.Auth.RevokeToken(c.Request.Context(), claims); err != nil {
+			c.IndentedJSON(http.StatusInternalServerError, gin.H{"message": err.Error()})
+			return
+		}
+
+		// Ending the session stops its refresh token from being exchanged
+		if claims.Session_id != "" {
+			if _, err := h.Auth.RevokeSession(c.Request.Context(), claims.User_id, claims.Session_id); err != nil {
+				c.IndentedJSON(http.StatusInternalServerError, gin.H{"message": err.Error()})
+				return
+			}
+		}
 
-func GetUser() gin.HandlerFunc {
+		c.IndentedJSON(http.StatusOK, gin.H{"message": "Logged out"})
+	}
+}
+
+// LogoutAllUser revokes every access and refresh token issued to the user,
+// logging them out on all devices.
+func (h *Handler) LogoutAllUser() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userId := c.GetString("user_id")
+
+		if err := h.Auth.RevokeAllTokens(c.Request.Context(), userId); err != nil {
+			c.IndentedJSON(http.StatusInternalServerError, gin.H{"message": err.Error()})
+			return
+		}
+
+		c.IndentedJSON(http.StatusOK, gin.H{"message": "Logged out from all devices"})
+	}
+}
+
+// userSortFields maps the public `sort` keys of GET /users onto stored fields.
+var userSortFields = map[string]string{
+	"created_at": "created_at",
+}
+
+// GetUsers responds with one page of users as JSON.
+//
+// Query parameters: page, limit, sort (created_at or -created_at), user_type,
+// q, which matches part of the email address, first name or last name, and
+// status (active, deleted or all; active by default).
+func (h *Handler) GetUsers() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		params, err := pagination.ParseParams(c)
+		if err != nil {
+			c.IndentedJSON(http.StatusBadRequest, gin.H{"message": err.Error()})
+			return
+		}
+
+		sort, err := pagination.ParseSort(c.Query("sort"), "-created_at", userSortFields)
+		if err != nil {
+			c.IndentedJSON(http.StatusBadRequest, gin.H{"message": err.Error()})
+			return
+		}
+
+		filter := models.UserFilter{Status: c.DefaultQuery("status", "active"), Query: c.Query("q")}
+
+		switch filter.Status {
+		case "active", "deleted", "all":
+		default:
+			c.IndentedJSON(http.StatusBadRequest, gin.H{"message": "status must be active, deleted or all"})
+			return
+		}
+
+		if userType := c.Query("user_type"); userType != "" {
+			if userType != middleware.RoleAdmin && userType != middleware.RoleUser {
+				c.IndentedJSON(http.StatusBadRequest, gin.H{"message": "user_type must be ADMIN or USER"})
+				return
+			}
+			filter.User_type = userType
+		}
+
+		users, total, err := h.Auth.Users.List(c.Request.Context(), filter, sort, params)
+		if err != nil {
+			c.IndentedJSON(http.StatusInternalServerError, gin.H{"message": err.Error()})
+			return
+		}
+
+		views := make([]models.AdminUserView, 0, len(users))
+		for _, user := range users {
+			views = append(views, models.NewAdminUserView(user))
+		}
+
+		c.IndentedJSON(http.StatusOK, pagination.NewPage(c, views, params, total))
+	}
+}
+
+// UpdateUser changes the profile fields sent in the request body of the user whose
+// ID matches the user_id parameter. Only admins may change User_type.
+func (h *Handler) UpdateUser() gin.HandlerFunc {
 	return func(c *gin.Context) {
-		// Get queried user by user_id
 		userId := c.Param("user_id")
 
-		// Handle verification of user type to user_id matching
-		err := helper.MatchUserTypeToUid(c, userId)
+		var request models.UpdateUserRequest
+		if err := c.ShouldBindJSON(&request); err != nil {
+			c.IndentedJSON(http.StatusBadRequest, gin.H{"message": err.Error()})
+			return
+		}
+
+		if validationErr := h.Validate.Struct(request); validationErr != nil {
+			c.IndentedJSON(http.StatusBadRequest, gin.H{"message": validationErr.Error()})
+			return
+		}
+
+		isAdmin := h.Access.Allows(c, middleware.PermissionManageUsers)
+		if request.User_type != nil && !isAdmin {
+			middleware.AbortForbidden(c, "Only admins can change user_type.")
+			return
+		}
 
+		if request.First_name == nil && request.Last_name == nil && request.Profile_photo == nil &&
+			request.User_type == nil && request.Phone_number == nil {
+			c.IndentedJSON(http.StatusBadRequest, gin.H{"message": "No fields to update"})
+			return
+		}
+
+		if request.Phone_number != nil {
+			// Check if another user has the same phone number.
+			phoneNumberExists, err := h.Auth.Users.PhoneNumberExists(c.Request.Context(), *request.Phone_number, userId)
+			if err != nil {
+				c.IndentedJSON(http.StatusInternalServerError, gin.H{"message": "Error occurred while checking for user phone number!"})
+				return
+			}
+			if phoneNumberExists {
+				c.IndentedJSON(http.StatusBadRequest, gin.H{"message": "Phone number already exists!"})
+				return
+			}
+		}
+
+		updatedAt, _ := time.Parse(time.RFC3339, time.Now().Format(time.RFC3339))
+
+		user, err := h.Auth.Users.UpdateProfile(c.Request.Context(), userId, request, updatedAt)
+		if errors.Is(err, repository.ErrUserNotFound) {
+			c.IndentedJSON(http.StatusNotFound, gin.H{"message": "User not found"})
+			return
+		}
+		if errors.Is(err, repository.ErrDuplicatePhoneNumber) {
+			c.IndentedJSON(http.StatusBadRequest, gin.H{"message": "Phone number already exists!"})
+			return
+		}
 		if err != nil {
-			// Return error if it exists
+			c.IndentedJSON(http.StatusInternalServerError, gin.H{"message": err.Error()})
+			return
+		}
+
+		// Issued tokens carry the old role, so the user has to log in again
+		if request.User_type != nil {
+			if err := h.Auth.RevokeAllTokens(c.Request.Context(), userId); err != nil {
+				c.IndentedJSON(http.StatusInternalServerError, gin.H{"message": err.Error()})
+				return
+			}
+		}
+
+		if userId == c.GetString("user_id") {
+			c.IndentedJSON(http.StatusOK, models.NewSelfProfile(user))
+			return
+		}
+
+		c.IndentedJSON(http.StatusOK, models.NewAdminUserView(user))
+	}
+}
+
+// DeleteUser soft deletes the user whose ID matches the user_id parameter. The user is
+// logged out everywhere and the account is purged after the deletion grace period,
+// during which an admin can still restore it.
+func (h *Handler) DeleteUser() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userId := c.Param("user_id")
+
+		found, err := h.Auth.SoftDeleteUser(c.Request.Context(), userId)
+		if err != nil {
+			c.IndentedJSON(http.StatusInternalServerError, gin.H{"message": err.Error()})
+			return
+		}
+		if !found {
+			c.IndentedJSON(http.StatusNotFound, gin.H{"message": "User not found"})
+			return
+		}
+
+		c.IndentedJSON(http.StatusOK, gin.H{
+			"message":     "User deleted",
+			"purge_after": time.Now().Add(h.Auth.DeletionGracePeriod),
+		})
+	}
+}
+
+// RestoreUser cancels the deletion of the user whose ID matches the user_id parameter.
+func (h *Handler) RestoreUser() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userId := c.Param("user_id")
+
+		found, err := h.Auth.RestoreUser(c.Request.Context(), userId)
+		if err != nil {
+			c.IndentedJSON(http.StatusInternalServerError, gin.H{"message": err.Error()})
+			return
+		}
+		if !found {
+			c.IndentedJSON(http.StatusNotFound, gin.H{"message": "No deleted user with this user_id"})
+			return
+		}
+
+		c.IndentedJSON(http.StatusOK, gin.H{"message": "User restored"})
+	}
+}
+
+// ChangePassword replaces the password of the user whose ID matches the user_id
+// parameter once the current password has been verified. All other sessions are
+// logged out and a new token pair is returned.
+func (h *Handler) ChangePassword() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userId := c.Param("user_id")
+
+		var request models.ChangePasswordRequest
+		if err := c.ShouldBindJSON(&request); err != nil {
+			c.IndentedJSON(http.StatusBadRequest, gin.H{"message": err.Error()})
+			return
+		}
+
+		if validationErr := h.Validate.Struct(request); validationErr != nil {
+			c.IndentedJSON(http.StatusBadRequest, gin.H{"message": validationErr.Error()})
+			return
+		}
+
+		foundUser, err := h.Auth.Users.FindByID(c.Request.Context(), userId, false)
+		if errors.Is(err, repository.ErrUserNotFound) {
+			c.IndentedJSON(http.StatusNotFound, gin.H{"message": "User not found"})
+			return
+		}
+		if err != nil {
+			c.IndentedJSON(http.StatusInternalServerError, gin.H{"message": err.Error()})
+			return
+		}
+
+		// Wrong current passwords are throttled like failed logins
+		if !h.checkLoginGuard(c, *foundUser.Email_address) {
+			return
+		}
+
+		if foundUser.Password == nil {
+			c.IndentedJSON(http.StatusBadRequest, gin.H{"message": "Current password is incorrect"})
+			return
+		}
+		if passwordIsValid, _ := VerifyPassword(request.Current_password, *foundUser.Password); !passwordIsValid {
+			h.recordFailedLogin(c, *foundUser.Email_address, loginguard.ReasonWrongPassword)
+			c.IndentedJSON(http.StatusBadRequest, gin.H{"message": "Current password is incorrect"})
+			return
+		}
+		h.recordSuccessfulLogin(c, *foundUser.Email_address)
+
+		if err := h.setPassword(c.Request.Context(), userId, request.New_password); err != nil {
+			c.IndentedJSON(http.StatusInternalServerError, gin.H{"message": err.Error()})
+			return
+		}
+
+		// The new tokens are issued with the token generation that revoked the old ones
+		foundUser, err = h.Auth.Users.FindByID(c.Request.Context(), userId, false)
+		if err != nil {
+			c.IndentedJSON(http.StatusInternalServerError, gin.H{"message": err.Error()})
+			return
+		}
+
+		token, refreshToken, _, err := h.startSession(c, foundUser)
+		if err != nil {
+			c.IndentedJSON(http.StatusInternalServerError, gin.H{"message": err.Error()})
+			return
+		}
+
+		c.IndentedJSON(http.StatusOK, h.newTokenPair(token, refreshToken))
+	}
+}
+
+// ForgotPassword emails a single-use password reset link to the address in the request
+// body. The response is the same whether or not the address belongs to a user.
+// Requests are throttled per address and per client like failed logins.
+func (h *Handler) ForgotPassword() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var request models.ForgotPasswordRequest
+		if err := c.ShouldBindJSON(&request); err != nil {
 			c.IndentedJSON(http.StatusBadRequest, gin.H{"message": err.Error()})
 			return
 		}
 
-		// Get User model
-		var user models.User
+		if validationErr := h.Validate.Struct(request); validationErr != nil {
+			c.IndentedJSON(http.StatusBadRequest, gin.H{"message": validationErr.Error()})
+			return
+		}
+
+		// Every request counts against the address and the client, whether or not the address
+		// belongs to a user, so nobody can flood a mailbox or probe addresses at will
+		if !h.checkLoginGuard(c, request.Email_address) {
+			return
+		}
+		h.recordFailedLogin(c, request.Email_address, loginguard.ReasonPasswordReset)
 
-		// Find user by user_id in DB userCollection
-		err = userCollection.FindOne(rootContext, bson.M{"user_id": userId}).Decode(&user)
+		response := gin.H{"message": "If the email address belongs to an account, a password reset link has been sent to it"}
 
-		if err == mongo.ErrNoDocuments {
-			// Log error
-			fmt.Printf("No user was found with the user_id %s\n", userId)
+		foundUser, err := h.Auth.Users.FindByEmail(c.Request.Context(), request.Email_address, false)
+		if errors.Is(err, repository.ErrUserNotFound) {
+			c.IndentedJSON(http.StatusAccepted, response)
+			return
+		}
+		if err != nil {
+			c.IndentedJSON(http.StatusInternalServerError, gin.H{"message": err.Error()})
+			return
+		}
+
+		resetToken, err := h.Auth.CreatePasswordResetToken(c.Request.Context(), foundUser.User_id)
+		if err != nil {
+			c.IndentedJSON(http.StatusInternalServerError, gin.H{"message": err.Error()})
+			return
+		}
+
+		message := mail.Message{
+			To:      request.Email_address,
+			Subject: "Reset your password",
+			Body: fmt.Sprintf("Use the link below to choose a new password. It expires in %s.\n\n%s/reset-password?token=%s\n\n"+
+				"If you did not ask for a password reset, you can ignore this email.",
+				h.Auth.PasswordResetTokenLifetime, h.Auth.AppBaseURL, resetToken),
+		}
+		if err := h.Auth.Mail.Send(c.Request.Context(), message); err != nil {
+			// Not reported to the client, which must not learn whether the address exists
+			h.Logger.Println("Error sending password reset email: ", err)
+		}
+
+		c.IndentedJSON(http.StatusAccepted, response)
+	}
+}
+
+// ResetPassword sets a new password using a token sent by ForgotPassword,
+// then logs the user out everywhere.
+func (h *Handler) ResetPassword() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var request models.ResetPasswordRequest
+		if err := c.ShouldBindJSON(&request); err != nil {
+			c.IndentedJSON(http.StatusBadRequest, gin.H{"message": err.Error()})
+			return
+		}
+
+		if validationErr := h.Validate.Struct(request); validationErr != nil {
+			c.IndentedJSON(http.StatusBadRequest, gin.H{"message": validationErr.Error()})
+			return
+		}
+
+		userId, err := h.Auth.ConsumePasswordResetToken(c.Request.Context(), request.Token)
+		if errors.Is(err, helper.ErrInvalidResetToken) {
+			c.IndentedJSON(http.StatusBadRequest, gin.H{"message": err.Error()})
+			return
+		}
+		if err != nil {
+			c.IndentedJSON(http.StatusInternalServerError, gin.H{"message": err.Error()})
+			return
+		}
+
+		if err := h.setPassword(c.Request.Context(), userId, request.New_password); err != nil {
+			c.IndentedJSON(http.StatusInternalServerError, gin.H{"message": err.Error()})
+			return
+		}
+
+		c.IndentedJSON(http.StatusOK, gin.H{"message": "Password has been reset. Please log in again"})
+	}
+}
+
+// VerifyEmail marks the user's email address as verified using the token from the
+// link sent at registration. Tokens issued before verification still say the address
+// is unverified; refreshing them picks up the change.
+func (h *Handler) VerifyEmail() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		claims, msg := h.Auth.ValidateEmailVerificationToken(c.Query("token"))
+		if msg != "" {
+			c.IndentedJSON(http.StatusBadRequest, gin.H{"message": "Verification link is invalid or has expired"})
+			return
+		}
+
+		found, err := h.Auth.MarkEmailVerified(c.Request.Context(), claims.Email_address, claims.User_id)
+		if err != nil {
+			c.IndentedJSON(http.StatusInternalServerError, gin.H{"message": err.Error()})
+			return
+		}
+		if !found {
+			// The account was deleted or its email address changed after the link was sent
+			c.IndentedJSON(http.StatusBadRequest, gin.H{"message": "Verification link is invalid or has expired"})
+			return
+		}
+
+		c.IndentedJSON(http.StatusOK, gin.H{"message": "Email address verified"})
+	}
+}
+
+// ResendVerificationEmail sends a new verification link to the authenticated user,
+// at most once every helper.EmailVerificationResendInterval.
+func (h *Handler) ResendVerificationEmail() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userId := c.GetString("user_id")
+
+		found, retryAfter, err := h.Auth.ClaimVerificationEmail(c.Request.Context(), userId)
+		if err != nil {
+			c.IndentedJSON(http.StatusInternalServerError, gin.H{"message": err.Error()})
+			return
+		}
+		if !found {
+			c.IndentedJSON(http.StatusConflict, gin.H{"message": "Email address is already verified"})
+			return
+		}
+		if retryAfter > 0 {
+			c.Header("Retry-After", fmt.Sprintf("%.0f", retryAfter.Seconds()+0.5))
+			c.IndentedJSON(http.StatusTooManyRequests, gin.H{"message": "A verification email was sent recently. Please wait before asking for another one"})
+			return
+		}
+
+		if err := h.Auth.SendVerificationEmail(c.Request.Context(), c.GetString("email_address"), userId); err != nil {
+			c.IndentedJSON(http.StatusInternalServerError, gin.H{"message": err.Error()})
+			return
+		}
+
+		c.IndentedJSON(http.StatusAccepted, gin.H{"message": "Verification email sent"})
+	}
+}
+
+// setPassword stores the hash of password for the user and revokes all of the user's tokens.
+func (h *Handler) setPassword(ctx context.Context, userId, password string) error {
+	hashedPassword := h.HashPassword(password)
+	Updated_at, _ := time.Parse(time.RFC3339, time.Now().Format(time.RFC3339))
+
+	if err := h.Auth.Users.SetPassword(ctx, userId, hashedPassword, Updated_at); err != nil {
+		return err
+	}
+
+	return h.Auth.RevokeAllTokens(ctx, userId)
+}
+
+// UnlockUser lifts the failed login lockout of the user whose ID matches the user_id parameter.
+func (h *Handler) UnlockUser() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		user, ok := h.findUserForAdmin(c)
+		if !ok {
+			return
+		}
+
+		if err := h.Auth.LoginGuard.Unlock(c.Request.Context(), *user.Email_address); err != nil {
+			c.IndentedJSON(http.StatusInternalServerError, gin.H{"message": err.Error()})
+			return
+		}
+
+		c.IndentedJSON(http.StatusOK, gin.H{"message": "User unlocked"})
+	}
+}
+
+// GetFailedLogins responds with the most recent failed logins (at most `limit`, default 50)
+// for the email address of the user whose ID matches the user_id parameter.
+func (h *Handler) GetFailedLogins() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		limit, err := strconv.Atoi(c.DefaultQuery("limit", "50"))
+		if err != nil || limit < 1 || limit > 500 {
+			c.IndentedJSON(http.StatusBadRequest, gin.H{"message": "limit must be between 1 and 500"})
+			return
+		}
+
+		user, ok := h.findUserForAdmin(c)
+		if !ok {
+			return
+		}
+
+		entries, err := h.Auth.LoginGuard.AuditTrail(c.Request.Context(), *user.Email_address, limit)
+		if err != nil {
+			c.IndentedJSON(http.StatusInternalServerError, gin.H{"message": err.Error()})
+			return
+		}
+
+		c.IndentedJSON(http.StatusOK, entries)
+	}
+}
+
+// findUserForAdmin loads the user named by the user_id parameter, deleted or not,
+// and answers 404 or 500 itself when that fails.
+func (h *Handler) findUserForAdmin(c *gin.Context) (models.User, bool) {
+	user, err := h.Auth.Users.FindByID(c.Request.Context(), c.Param("user_id"), true)
+	if errors.Is(err, repository.ErrUserNotFound) || (err == nil && user.Email_address == nil) {
+		c.IndentedJSON(http.StatusNotFound, gin.H{"message": "User not found"})
+		return user, false
+	}
+	if err != nil {
+		c.IndentedJSON(http.StatusInternalServerError, gin.H{"message": err.Error()})
+		return user, false
+	}
+
+	return user, true
+}
+
+// GetUser responds with the user whose ID matches the user_id parameter.
+// Users see their own full profile; the route lets only them and those with the
+// users:read permission through, and the latter see the admin view.
+func (h *Handler) GetUser() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		// Get queried user by user_id
+		userId := c.Param("user_id")
+
+		// Deleted users are only visible to admins, and to API keys with the users:read scope
+		isAdmin := h.Access.Allows(c, middleware.PermissionReadUsers)
+
+		// Find user by user_id in the user repository
+		user, err := h.Auth.Users.FindByID(c.Request.Context(), userId, isAdmin)
+
+		if errors.Is(err, repository.ErrUserNotFound) {
+			c.IndentedJSON(http.StatusNotFound, gin.H{"message": "User not found"})
 			return
 		}
 
 		// Return error 500
 		if err != nil {
 			c.IndentedJSON(http.StatusInternalServerError, gin.H{"message": err.Error()})
+			return
 		}
 
 		// Return user with status 200
-		c.IndentedJSON(http.StatusOK, user)
+		if userId == c.GetString("user_id") {
+			c.IndentedJSON(http.StatusOK, models.NewSelfProfile(user))
+			return
+		}
+
+		c.IndentedJSON(http.StatusOK, models.NewAdminUserView(user))
 	}
 }
+
+func (h *Handler) newTokenPair(token, refreshToken string) models.TokenPair {
+	return models.TokenPair{
+		Access_token:  token,
+		Refresh_token: refreshToken,
+		Token_type:    "Bearer",
+		Expires_in:    int64(h.Auth.AccessTokenLifetime.Seconds()),
+	}
+}
+
+func (h *Handler) newAuthResponse(user models.User, token, refreshToken string, mfaEnrollmentRequired bool) models.AuthResponse {
+	return models.AuthResponse{
+		User:                    models.NewSelfProfile(user),
+		Tokens:                  h.newTokenPair(token, refreshToken),
+		Mfa_enrollment_required: mfaEnrollmentRequired,
+	}
+}
+
+// startSession records a new session of user on the requesting device and signs its
+// token pair. mfaEnrollmentRequired reports that the access token is limited to setting
+// up two-factor authentication.
+func (h *Handler) startSession(c *gin.Context, user models.User) (token, refreshToken string, mfaEnrollmentRequired bool, err error) {
+	mfaEnrollmentRequired, err = h.Auth.MFAEnrollmentRequired(c.Request.Context(), user)
+	if err != nil {
+		return "", "", false, err
+	}
+
+	sessionId := helper.NewSessionID()
+	token, refreshToken, err = h.Auth.GenerateAllTokens(user, sessionId, mfaEnrollmentRequired)
+	if err != nil {
+		return "", "", false, err
+	}
+
+	err = h.Auth.CreateSession(c.Request.Context(), sessionId, user.User_id, refreshToken, c.Request.UserAgent(), c.ClientIP())
+	return token, refreshToken, mfaEnrollmentRequired, err
+}
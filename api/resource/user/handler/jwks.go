@@ -0,0 +1,17 @@
+package handler
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+// GetJWKS responds with the public keys that verify this service's tokens as a JSON
+// Web Key Set. Keys are published before they start signing, so caching the set for
+// a few minutes is safe.
+func (h *Handler) GetJWKS() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		c.Header("Cache-Control", "public, max-age=300")
+		c.IndentedJSON(http.StatusOK, h.Auth.SigningKeys.JWKS())
+	}
+}
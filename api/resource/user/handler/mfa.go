@@ -0,0 +1,378 @@
+package handler
+
+import (
+	"context"
+	"errors"
+	"net/http"
+
+	"movie-api/api/loginguard"
+	helper "movie-api/api/resource/user/helpers"
+	models "movie-api/api/resource/user/model"
+	"movie-api/api/resource/user/repository"
+
+	"github.com/gin-gonic/gin"
+)
+
+// LoginMFA completes a login started by LoginUser for a user with two-factor
+// authentication, exchanging the MFA challenge token and a code from the
+// authenticator app (or a recovery code) for the user's tokens.
+func (h *Handler) LoginMFA() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var request models.MFALoginRequest
+
+		if err := c.ShouldBindJSON(&request); err != nil {
+			c.IndentedJSON(http.StatusBadRequest, gin.H{"message": err.Error()})
+			return
+		}
+
+		if validationErr := h.Validate.Struct(request); validationErr != nil {
+			c.IndentedJSON(http.StatusBadRequest, gin.H{"message": validationErr.Error()})
+			return
+		}
+
+		claims, msg := h.Auth.ValidateMFAChallengeToken(request.Mfa_token)
+		if msg != "" {
+			c.IndentedJSON(http.StatusUnauthorized, gin.H{"message": msg})
+			return
+		}
+
+		revoked, err := h.Auth.IsTokenRevoked(c.Request.Context(), claims)
+		if err != nil {
+			c.IndentedJSON(http.StatusInternalServerError, gin.H{"message": err.Error()})
+			return
+		}
+		if revoked {
+			c.IndentedJSON(http.StatusUnauthorized, gin.H{"message": "MFA token has already been used. Please log in again"})
+			return
+		}
+
+		// Wrong codes count as failed logins, so guessing codes is throttled like guessing passwords
+		if !h.checkLoginGuard(c, claims.Email_address) {
+			return
+		}
+
+		foundUser, err := h.Auth.Users.FindByID(c.Request.Context(), claims.User_id, false)
+		if errors.Is(err, repository.ErrUserNotFound) || (err == nil && (!foundUser.Mfa_enabled || foundUser.Email_address == nil)) {
+			c.IndentedJSON(http.StatusUnauthorized, gin.H{"message": "MFA token is no longer valid. Please log in again"})
+			return
+		}
+		if err != nil {
+			c.IndentedJSON(http.StatusInternalServerError, gin.H{"message": err.Error()})
+			return
+		}
+
+		valid, err := h.checkSecondFactor(c.Request.Context(), foundUser, request.Code, request.Recovery_code)
+		if err != nil {
+			c.IndentedJSON(http.StatusInternalServerError, gin.H{"message": err.Error()})
+			return
+		}
+		if !valid {
+			h.recordFailedLogin(c, *foundUser.Email_address, loginguard.ReasonWrongMFACode)
+			c.IndentedJSON(http.StatusUnauthorized, gin.H{"message": helper.ErrInvalidMFACode.Error()})
+			return
+		}
+
+		// Each challenge completes one login
+		if err := h.Auth.RevokeToken(c.Request.Context(), claims); err != nil {
+			c.IndentedJSON(http.StatusInternalServerError, gin.H{"message": err.Error()})
+			return
+		}
+
+		h.completeLogin(c, foundUser)
+	}
+}
+
+// EnrollMFA starts two-factor authentication enrolment for the authenticated user.
+// It responds with the secret and its otpauth:// provisioning URI; the enrolment is
+// completed by ConfirmMFA.
+func (h *Handler) EnrollMFA() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		secret, provisioningURI, err := h.Auth.StartMFAEnrollment(c.Request.Context(), c.GetString("email_address"), c.GetString("user_id"))
+		if errors.Is(err, helper.ErrMFAAlreadyEnabled) {
+			c.IndentedJSON(http.StatusConflict, gin.H{"message": err.Error()})
+			return
+		}
+		if err != nil {
+			c.IndentedJSON(http.StatusInternalServerError, gin.H{"message": err.Error()})
+			return
+		}
+
+		c.IndentedJSON(http.StatusOK, models.MFAEnrollment{Secret: secret, Provisioning_uri: provisioningURI})
+	}
+}
+
+// ConfirmMFA enables two-factor authentication once the user proves their authenticator
+// app works by sending a code from it. It responds with the recovery codes and the
+// tokens of a new session, which replaces the caller's and is no longer limited to enrolment.
+func (h *Handler) ConfirmMFA() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var request models.MFACodeRequest
+
+		if err := c.ShouldBindJSON(&request); err != nil {
+			c.IndentedJSON(http.StatusBadRequest, gin.H{"message": err.Error()})
+			return
+		}
+
+		if validationErr := h.Validate.Struct(request); validationErr != nil {
+			c.IndentedJSON(http.StatusBadRequest, gin.H{"message": validationErr.Error()})
+			return
+		}
+
+		userId := c.GetString("user_id")
+		recoveryCodes, err := h.Auth.ConfirmMFAEnrollment(c.Request.Context(), userId, request.Code)
+		switch {
+		case errors.Is(err, helper.ErrInvalidMFACode):
+			c.IndentedJSON(http.StatusBadRequest, gin.H{"message": err.Error()})
+			return
+		case errors.Is(err, helper.ErrMFAAlreadyEnabled), errors.Is(err, helper.ErrNoMFAEnrollment):
+			c.IndentedJSON(http.StatusConflict, gin.H{"message": err.Error()})
+			return
+		case errors.Is(err, repository.ErrUserNotFound):
+			c.IndentedJSON(http.StatusNotFound, gin.H{"message": "User not found"})
+			return
+		case err != nil:
+			c.IndentedJSON(http.StatusInternalServerError, gin.H{"message": err.Error()})
+			return
+		}
+
+		foundUser, err := h.Auth.Users.FindByID(c.Request.Context(), userId, true)
+		if err != nil {
+			c.IndentedJSON(http.StatusInternalServerError, gin.H{"message": err.Error()})
+			return
+		}
+
+		token, refreshToken, _, err := h.startSession(c, foundUser)
+		if err != nil {
+			c.IndentedJSON(http.StatusInternalServerError, gin.H{"message": err.Error()})
+			return
+		}
+
+		// The new session replaces the one enrolment was done in, whose tokens may
+		// have been limited to enrolment
+		claims := c.MustGet("claims").(*helper.SignedDetails)
+		if claims.Session_id != "" {
+			if _, err := h.Auth.RevokeSession(c.Request.Context(), userId, claims.Session_id); err != nil {
+				h.Logger.Println("Error ending the enrolment session: ", err)
+			}
+		} else if err := h.Auth.RevokeToken(c.Request.Context(), claims); err != nil {
+			h.Logger.Println("Error revoking enrolment token: ", err)
+		}
+
+		tokens := h.newTokenPair(token, refreshToken)
+		c.IndentedJSON(http.StatusOK, models.RecoveryCodes{Recovery_codes: recoveryCodes, Tokens: &tokens})
+	}
+}
+
+// DisableMFA turns two-factor authentication off for the authenticated user after
+// checking their password and a code. Admins cannot turn it off while the security
+// settings require it.
+func (h *Handler) DisableMFA() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var request models.DisableMFARequest
+
+		if err := c.ShouldBindJSON(&request); err != nil {
+			c.IndentedJSON(http.StatusBadRequest, gin.H{"message": err.Error()})
+			return
+		}
+
+		if validationErr := h.Validate.Struct(request); validationErr != nil {
+			c.IndentedJSON(http.StatusBadRequest, gin.H{"message": validationErr.Error()})
+			return
+		}
+
+		foundUser, ok := h.findMFAUser(c)
+		if !ok {
+			return
+		}
+
+		// Wrong passwords and codes are throttled like failed logins
+		if !h.checkLoginGuard(c, *foundUser.Email_address) {
+			return
+		}
+
+		if foundUser.Password == nil {
+			c.IndentedJSON(http.StatusBadRequest, gin.H{"message": "Password is incorrect"})
+			return
+		}
+		if passwordIsValid, _ := VerifyPassword(request.Password, *foundUser.Password); !passwordIsValid {
+			h.recordFailedLogin(c, *foundUser.Email_address, loginguard.ReasonWrongPassword)
+			c.IndentedJSON(http.StatusBadRequest, gin.H{"message": "Password is incorrect"})
+			return
+		}
+
+		valid, err := h.checkSecondFactor(c.Request.Context(), foundUser, request.Code, request.Recovery_code)
+		if err != nil {
+			c.IndentedJSON(http.StatusInternalServerError, gin.H{"message": err.Error()})
+			return
+		}
+		if !valid {
+			h.recordFailedLogin(c, *foundUser.Email_address, loginguard.ReasonWrongMFACode)
+			c.IndentedJSON(http.StatusBadRequest, gin.H{"message": helper.ErrInvalidMFACode.Error()})
+			return
+		}
+		h.recordSuccessfulLogin(c, *foundUser.Email_address)
+
+		// Would the account have to enrol again straight away?
+		withoutMFA := foundUser
+		withoutMFA.Mfa_enabled = false
+		required, err := h.Auth.MFAEnrollmentRequired(c.Request.Context(), withoutMFA)
+		if err != nil {
+			c.IndentedJSON(http.StatusInternalServerError, gin.H{"message": err.Error()})
+			return
+		}
+		if required {
+			c.IndentedJSON(http.StatusForbidden, gin.H{"message": "Two-factor authentication is required for admin accounts"})
+			return
+		}
+
+		if err := h.Auth.DisableMFA(c.Request.Context(), foundUser.User_id); err != nil {
+			c.IndentedJSON(http.StatusInternalServerError, gin.H{"message": err.Error()})
+			return
+		}
+
+		c.IndentedJSON(http.StatusOK, gin.H{"message": "Two-factor authentication disabled"})
+	}
+}
+
+// RegenerateRecoveryCodes replaces the authenticated user's recovery codes after
+// checking a code from their authenticator app. The old codes stop working.
+func (h *Handler) RegenerateRecoveryCodes() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var request models.MFACodeRequest
+
+		if err := c.ShouldBindJSON(&request); err != nil {
+			c.IndentedJSON(http.StatusBadRequest, gin.H{"message": err.Error()})
+			return
+		}
+
+		if validationErr := h.Validate.Struct(request); validationErr != nil {
+			c.IndentedJSON(http.StatusBadRequest, gin.H{"message": validationErr.Error()})
+			return
+		}
+
+		foundUser, ok := h.findMFAUser(c)
+		if !ok {
+			return
+		}
+
+		// Wrong codes are throttled like failed logins
+		if !h.checkLoginGuard(c, *foundUser.Email_address) {
+			return
+		}
+
+		valid, err := h.Auth.VerifyMFACode(c.Request.Context(), foundUser.User_id, foundUser.Mfa_secret, request.Code)
+		if err != nil {
+			c.IndentedJSON(http.StatusInternalServerError, gin.H{"message": err.Error()})
+			return
+		}
+		if !valid {
+			h.recordFailedLogin(c, *foundUser.Email_address, loginguard.ReasonWrongMFACode)
+			c.IndentedJSON(http.StatusBadRequest, gin.H{"message": helper.ErrInvalidMFACode.Error()})
+			return
+		}
+		h.recordSuccessfulLogin(c, *foundUser.Email_address)
+
+		recoveryCodes, err := h.Auth.RegenerateRecoveryCodes(c.Request.Context(), foundUser.User_id)
+		if err != nil {
+			c.IndentedJSON(http.StatusInternalServerError, gin.H{"message": err.Error()})
+			return
+		}
+
+		c.IndentedJSON(http.StatusOK, models.RecoveryCodes{Recovery_codes: recoveryCodes})
+	}
+}
+
+// ResetUserMFA turns two-factor authentication off for the user whose ID matches the
+// user_id parameter, for users who lost their authenticator and recovery codes.
+// All of the user's tokens are revoked.
+func (h *Handler) ResetUserMFA() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		user, ok := h.findUserForAdmin(c)
+		if !ok {
+			return
+		}
+
+		if err := h.Auth.DisableMFA(c.Request.Context(), user.User_id); err != nil {
+			c.IndentedJSON(http.StatusInternalServerError, gin.H{"message": err.Error()})
+			return
+		}
+
+		if err := h.Auth.RevokeAllTokens(c.Request.Context(), user.User_id); err != nil {
+			c.IndentedJSON(http.StatusInternalServerError, gin.H{"message": err.Error()})
+			return
+		}
+
+		c.IndentedJSON(http.StatusOK, gin.H{"message": "Two-factor authentication reset"})
+	}
+}
+
+// GetSecuritySettings responds with the account security settings.
+func (h *Handler) GetSecuritySettings() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		settings, err := h.Auth.GetSecuritySettings(c.Request.Context())
+		if err != nil {
+			c.IndentedJSON(http.StatusInternalServerError, gin.H{"message": err.Error()})
+			return
+		}
+
+		c.IndentedJSON(http.StatusOK, settings)
+	}
+}
+
+// UpdateSecuritySettings changes the account security settings. Requiring two-factor
+// authentication for admins applies to the next token each admin gets.
+func (h *Handler) UpdateSecuritySettings() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var request models.UpdateSecuritySettingsRequest
+
+		if err := c.ShouldBindJSON(&request); err != nil {
+			c.IndentedJSON(http.StatusBadRequest, gin.H{"message": err.Error()})
+			return
+		}
+
+		if validationErr := h.Validate.Struct(request); validationErr != nil {
+			c.IndentedJSON(http.StatusBadRequest, gin.H{"message": validationErr.Error()})
+			return
+		}
+
+		settings, err := h.Auth.UpdateSecuritySettings(c.Request.Context(), models.SecuritySettings{
+			Require_admin_mfa: *request.Require_admin_mfa,
+		})
+		if err != nil {
+			c.IndentedJSON(http.StatusInternalServerError, gin.H{"message": err.Error()})
+			return
+		}
+
+		c.IndentedJSON(http.StatusOK, settings)
+	}
+}
+
+// checkSecondFactor checks a code from the authenticator app, or a recovery code when no code is given.
+func (h *Handler) checkSecondFactor(ctx context.Context, user models.User, code, recoveryCode string) (bool, error) {
+	if code != "" {
+		return h.Auth.VerifyMFACode(ctx, user.User_id, user.Mfa_secret, code)
+	}
+
+	return h.Auth.UseRecoveryCode(ctx, user.User_id, user.Mfa_recovery_codes, recoveryCode)
+}
+
+// findMFAUser loads the authenticated user, and answers 404, 409 or 500 itself when
+// the user cannot be loaded or has no two-factor authentication enabled.
+func (h *Handler) findMFAUser(c *gin.Context) (models.User, bool) {
+	user, err := h.Auth.Users.FindByID(c.Request.Context(), c.GetString("user_id"), false)
+	if errors.Is(err, repository.ErrUserNotFound) {
+		c.IndentedJSON(http.StatusNotFound, gin.H{"message": "User not found"})
+		return user, false
+	}
+	if err != nil {
+		c.IndentedJSON(http.StatusInternalServerError, gin.H{"message": err.Error()})
+		return user, false
+	}
+
+	if !user.Mfa_enabled {
+		c.IndentedJSON(http.StatusConflict, gin.H{"message": helper.ErrMFANotEnabled.Error()})
+		return user, false
+	}
+
+	return user, true
+}
@@ -0,0 +1,132 @@
+package handler
+
+import (
+	"errors"
+	"net/http"
+	"net/url"
+
+	"movie-api/api/oidc"
+	helper "movie-api/api/resource/user/helpers"
+
+	"github.com/gin-gonic/gin"
+)
+
+// OIDCLogin redirects the user to the OpenID Connect provider to log in.
+// The provider sends the user back to OIDCCallback.
+func (h *Handler) OIDCLogin() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if h.Auth.OIDCClient == nil {
+			c.IndentedJSON(http.StatusNotFound, gin.H{"message": "Login with an identity provider is not configured"})
+			return
+		}
+
+		authURL, state, err := h.Auth.StartOIDCLogin(c.Request.Context())
+		if errors.Is(err, oidc.ErrProvider) {
+			c.IndentedJSON(http.StatusBadGateway, gin.H{"message": err.Error()})
+			return
+		}
+		if err != nil {
+			c.IndentedJSON(http.StatusInternalServerError, gin.H{"message": err.Error()})
+			return
+		}
+
+		// Only this browser can complete the login, so nobody can log a victim in to their
+		// own account by sending them a callback link
+		h.setOIDCStateCookie(c, state, int(helper.OIDCStateLifetime.Seconds()))
+
+		c.Redirect(http.StatusFound, authURL)
+	}
+}
+
+// OIDCCallback completes a login at the OpenID Connect provider. The identity is linked
+// to the user's account, or a new account is created, and the response is the same as
+// LoginUser's, including the MFA challenge for users with two-factor authentication.
+func (h *Handler) OIDCCallback() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if h.Auth.OIDCClient == nil {
+			c.IndentedJSON(http.StatusNotFound, gin.H{"message": "Login with an identity provider is not configured"})
+			return
+		}
+
+		// The user cancelled or the provider refused the request
+		if providerError := c.Query("error"); providerError != "" {
+			msg := providerError
+			if description := c.Query("error_description"); description != "" {
+				msg += ": " + description
+			}
+			c.IndentedJSON(http.StatusUnauthorized, gin.H{"message": msg})
+			return
+		}
+
+		state, code := c.Query("state"), c.Query("code")
+		if state == "" || code == "" {
+			c.IndentedJSON(http.StatusBadRequest, gin.H{"message": "state and code are required"})
+			return
+		}
+
+		// A rejected redirect leaves the pending login of this browser alone
+		boundState, _ := c.Cookie(oidcStateCookie)
+		claims, err := h.Auth.FinishOIDCLogin(c.Request.Context(), state, boundState, code)
+		if !errors.Is(err, helper.ErrInvalidOIDCState) {
+			h.setOIDCStateCookie(c, "", -1)
+		}
+		switch {
+		case errors.Is(err, helper.ErrInvalidOIDCState):
+			c.IndentedJSON(http.StatusBadRequest, gin.H{"message": err.Error()})
+			return
+		case errors.Is(err, oidc.ErrProvider):
+			c.IndentedJSON(http.StatusBadGateway, gin.H{"message": err.Error()})
+			return
+		case err != nil:
+			c.IndentedJSON(http.StatusInternalServerError, gin.H{"message": err.Error()})
+			return
+		}
+
+		foundUser, created, err := h.Auth.FindOrCreateOIDCUser(c.Request.Context(), claims)
+		switch {
+		case errors.Is(err, helper.ErrOIDCNoEmail):
+			c.IndentedJSON(http.StatusUnprocessableEntity, gin.H{"message": err.Error()})
+			return
+		case errors.Is(err, helper.ErrOIDCEmailInUse):
+			c.IndentedJSON(http.StatusConflict, gin.H{"message": err.Error()})
+			return
+		case err != nil:
+			c.IndentedJSON(http.StatusInternalServerError, gin.H{"message": err.Error()})
+			return
+		}
+
+		if created && !foundUser.IsEmailVerified() {
+			if err := h.Auth.SendVerificationEmail(c.Request.Context(), *foundUser.Email_address, foundUser.User_id); err != nil {
+				h.Logger.Println("Error sending verification email: ", err)
+			}
+		}
+
+		if foundUser.Mfa_enabled {
+			h.respondWithMFAChallenge(c, foundUser)
+			return
+		}
+
+		h.completeLogin(c, foundUser)
+	}
+}
+
+// oidcStateCookie binds a pending OpenID Connect login to the browser that started it.
+const oidcStateCookie = "oidc_state"
+
+// setOIDCStateCookie sets the state cookie for maxAge seconds, or deletes it when maxAge
+// is negative. The cookie is only sent to the callback, and only over HTTPS when the
+// callback is served over HTTPS. It has to survive the top-level redirect back from
+// the provider, which SameSite=Lax allows.
+func (h *Handler) setOIDCStateCookie(c *gin.Context, state string, maxAge int) {
+	path := "/"
+	secure := false
+	if callback, err := url.Parse(h.Auth.OIDCClient.Config.RedirectURL); err == nil {
+		if callback.Path != "" {
+			path = callback.Path
+		}
+		secure = callback.Scheme == "https"
+	}
+
+	c.SetSameSite(http.SameSiteLaxMode)
+	c.SetCookie(oidcStateCookie, state, maxAge, path, "", secure, true)
+}
@@ -0,0 +1,66 @@
+package handler
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+// GetSessions responds with the sessions of the user whose ID matches the user_id
+// parameter, most recently active first. The session making the request is marked current.
+func (h *Handler) GetSessions() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		sessions, err := h.Auth.ListSessions(c.Request.Context(), c.Param("user_id"))
+		if err != nil {
+			c.IndentedJSON(http.StatusInternalServerError, gin.H{"message": err.Error()})
+			return
+		}
+
+		currentSessionId := c.GetString("session_id")
+		for i := range sessions {
+			sessions[i].Current = currentSessionId != "" && sessions[i].Session_id == currentSessionId
+		}
+
+		c.IndentedJSON(http.StatusOK, sessions)
+	}
+}
+
+// RevokeSession ends the session whose ID matches the session_id parameter,
+// logging that device out.
+func (h *Handler) RevokeSession() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		found, err := h.Auth.RevokeSession(c.Request.Context(), c.Param("user_id"), c.Param("session_id"))
+		if err != nil {
+			c.IndentedJSON(http.StatusInternalServerError, gin.H{"message": err.Error()})
+			return
+		}
+		if !found {
+			c.IndentedJSON(http.StatusNotFound, gin.H{"message": "Session not found"})
+			return
+		}
+
+		c.IndentedJSON(http.StatusOK, gin.H{"message": "Session revoked"})
+	}
+}
+
+// RevokeOtherSessions ends every session of the user whose ID matches the user_id
+// parameter except the one making the request.
+func (h *Handler) RevokeOtherSessions() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userId := c.Param("user_id")
+
+		// Admins ending another user's sessions end them all
+		keepSessionId := ""
+		if userId == c.GetString("user_id") {
+			keepSessionId = c.GetString("session_id")
+		}
+
+		revoked, err := h.Auth.RevokeOtherSessions(c.Request.Context(), userId, keepSessionId)
+		if err != nil {
+			c.IndentedJSON(http.StatusInternalServerError, gin.H{"message": err.Error()})
+			return
+		}
+
+		c.IndentedJSON(http.StatusOK, gin.H{"message": "Sessions revoked", "revoked": revoked})
+	}
+}
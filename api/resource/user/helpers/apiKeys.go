@@ -0,0 +1,100 @@
+package helpers
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"time"
+
+	models "movie-api/api/resource/user/model"
+	"movie-api/api/resource/user/repository"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// APIKeyPrefix starts every API key, so keys can be told apart from JWTs and found by secret scanners.
+const APIKeyPrefix string = "mak_"
+
+const (
+	APIKeyDefaultLifetime time.Duration = 90 * 24 * time.Hour
+	APIKeyMaxLifetime     time.Duration = 365 * 24 * time.Hour
+
+	// apiKeyLastUsedResolution limits how often using a key writes last_used_at.
+	apiKeyLastUsedResolution time.Duration = time.Minute
+)
+
+// ErrInvalidAPIKey is returned for API keys that are unknown, revoked or expired.
+var ErrInvalidAPIKey = errors.New("API key is invalid, revoked or expired")
+
+// IsAPIKey reports whether a bearer credential is an API key rather than a JWT.
+func IsAPIKey(credential string) bool {
+	return strings.HasPrefix(credential, APIKeyPrefix)
+}
+
+// Handles the creation of an API key for the user. The returned key is the only copy
+// of the secret.
+func (s *Service) CreateAPIKey(ctx context.Context, userId, name string, scopes []string, expiresAt time.Time) (models.CreatedAPIKey, error) {
+	secret, err := GenerateRandomToken()
+	if err != nil {
+		return models.CreatedAPIKey{}, err
+	}
+	key := APIKeyPrefix + secret
+
+	createdAt, _ := time.Parse(time.RFC3339, time.Now().Format(time.RFC3339))
+	apiKey := models.APIKey{
+		Key_id:     primitive.NewObjectID().Hex(),
+		User_id:    userId,
+		Name:       name,
+		Prefix:     key[:len(APIKeyPrefix)+6],
+		Key_hash:   HashToken(key),
+		Scopes:     scopes,
+		Created_at: createdAt,
+		Expires_at: expiresAt,
+	}
+
+	if err := s.APIKeys.Insert(ctx, apiKey); err != nil {
+		return models.CreatedAPIKey{}, err
+	}
+
+	return models.CreatedAPIKey{APIKey: apiKey, Key: key}, nil
+}
+
+// Handles listing the user's API keys, newest first, including revoked and expired ones.
+func (s *Service) ListAPIKeys(ctx context.Context, userId string) ([]models.APIKey, error) {
+	return s.APIKeys.ListByUser(ctx, userId)
+}
+
+// Handles revoking one of the user's API keys. found is false when the user has no such key.
+func (s *Service) RevokeAPIKey(ctx context.Context, userId, keyId string) (found bool, err error) {
+	// Revoking twice keeps the first revocation time
+	return s.APIKeys.Revoke(ctx, userId, keyId, time.Now())
+}
+
+// Handles authenticating a request made with an API key. It returns the key and its
+// owner, who must still have an active account, and records when the key was used.
+func (s *Service) AuthenticateAPIKey(ctx context.Context, key string) (models.APIKey, models.User, error) {
+	now := time.Now()
+
+	apiKey, err := s.APIKeys.FindActiveByHash(ctx, HashToken(key), now)
+	if errors.Is(err, repository.ErrAPIKeyNotFound) {
+		return apiKey, models.User{}, ErrInvalidAPIKey
+	}
+	if err != nil {
+		return apiKey, models.User{}, err
+	}
+
+	owner, err := s.Users.FindByID(ctx, apiKey.User_id, false)
+	if errors.Is(err, repository.ErrUserNotFound) {
+		return apiKey, owner, ErrInvalidAPIKey
+	}
+	if err != nil {
+		return apiKey, owner, err
+	}
+
+	// Busy keys only write last_used_at once per apiKeyLastUsedResolution
+	if err := s.APIKeys.TouchLastUsed(ctx, apiKey.Key_id, now, now.Add(-apiKeyLastUsedResolution)); err != nil {
+		return apiKey, owner, err
+	}
+
+	return apiKey, owner, nil
+}
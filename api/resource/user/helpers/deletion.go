@@ -0,0 +1,92 @@
+package helpers
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"movie-api/api/resource/user/repository"
+)
+
+// Handles soft deletion of an account. The user is logged out everywhere at once and
+// the account is purged once the deletion grace period has passed. found is false when
+// there is no active user with that user_id.
+func (s *Service) SoftDeleteUser(ctx context.Context, userId string) (found bool, err error) {
+	now := time.Now()
+
+	found, err = s.Users.SoftDelete(ctx, userId, now, now.Add(s.DeletionGracePeriod))
+	if err != nil || !found {
+		return false, err
+	}
+
+	return true, s.RevokeAllTokens(ctx, userId)
+}
+
+// Handles restoring a soft deleted account during its grace period.
+// found is false when there is no deleted user with that user_id.
+func (s *Service) RestoreUser(ctx context.Context, userId string) (found bool, err error) {
+	return s.Users.Restore(ctx, userId, time.Now())
+}
+
+// Handles permanently removing the accounts whose grace period is over,
+// together with the rest of their data.
+func (s *Service) PurgeDeletedUsers(ctx context.Context) (purged int, err error) {
+	userIds, err := s.Users.ListPurgeDue(ctx, time.Now())
+	if err != nil {
+		return 0, err
+	}
+
+	for _, userId := range userIds {
+		// Another replica may have purged the account in the meantime
+		user, err := s.Users.FindByID(ctx, userId, true)
+		if errors.Is(err, repository.ErrUserNotFound) {
+			continue
+		}
+		if err != nil {
+			return purged, err
+		}
+		if user.Email_address != nil {
+			if err := s.LoginGuard.Forget(ctx, *user.Email_address); err != nil {
+				return purged, err
+			}
+		}
+
+		if err := s.Tokens.DeleteByUser(ctx, userId); err != nil {
+			return purged, err
+		}
+		if err := s.APIKeys.DeleteByUser(ctx, userId); err != nil {
+			return purged, err
+		}
+		if err := s.Sessions.DeleteByUser(ctx, userId); err != nil {
+			return purged, err
+		}
+		if err := s.Users.Delete(ctx, userId); err != nil {
+			return purged, err
+		}
+
+		purged++
+	}
+
+	return purged, nil
+}
+
+// RunAccountPurger calls PurgeDeletedUsers every interval until ctx is done.
+func (s *Service) RunAccountPurger(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		purged, err := s.PurgeDeletedUsers(ctx)
+		if err != nil {
+			s.Logger.Println("Error purging deleted users: ", err)
+		} else if purged > 0 {
+			s.Logger.Printf("Purged %d deleted user(s)\n", purged)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
@@ -0,0 +1,49 @@
+package helpers
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"time"
+
+	"movie-api/api/mail"
+)
+
+// EmailVerificationResendInterval is the minimum time between two verification emails to the same user.
+const EmailVerificationResendInterval time.Duration = time.Minute
+
+// Handles sending the signed verification link to the user's email address.
+func (s *Service) SendVerificationEmail(ctx context.Context, emailAddress, userId string) error {
+	token, err := s.GenerateEmailVerificationToken(emailAddress, userId)
+	if err != nil {
+		return err
+	}
+
+	message := mail.Message{
+		To:      emailAddress,
+		Subject: "Verify your email address",
+		Body: fmt.Sprintf("Open the link below to verify your email address. It expires in %s.\n\n%s/auth/verify-email?token=%s",
+			s.EmailVerificationTokenLifetime, s.AppBaseURL, url.QueryEscape(token)),
+	}
+
+	return s.Mail.Send(ctx, message)
+}
+
+// Handles throttling of verification emails. It records that an email is being sent
+// now, unless one was sent less than EmailVerificationResendInterval ago, in which
+// case retryAfter tells how long to wait. found is false when the user has no
+// unverified email address.
+func (s *Service) ClaimVerificationEmail(ctx context.Context, userId string) (found bool, retryAfter time.Duration, err error) {
+	found, lastSentAt, err := s.Users.ClaimVerificationEmail(ctx, userId, time.Now(), EmailVerificationResendInterval)
+	if err != nil || !found || lastSentAt.IsZero() {
+		return found, 0, err
+	}
+
+	return true, time.Until(lastSentAt.Add(EmailVerificationResendInterval)), nil
+}
+
+// Handles marking the email address as verified. It only succeeds while the
+// user still has the address the verification link was sent to.
+func (s *Service) MarkEmailVerified(ctx context.Context, emailAddress, userId string) (found bool, err error) {
+	return s.Users.MarkEmailVerified(ctx, userId, emailAddress, time.Now())
+}
@@ -1,111 +1,182 @@
 package helpers
 
 import (
-	"context"
-	"errors"
 	"fmt"
-	"log"
-	"os"
 	"time"
 
-	"movie-api/api/database"
+	"movie-api/api/jwtkeys"
+	models "movie-api/api/resource/user/model"
 
-	"github.com/gin-gonic/gin"
 	jwt "github.com/golang-jwt/jwt/v5"
-	"go.mongodb.org/mongo-driver/bson"
 	"go.mongodb.org/mongo-driver/bson/primitive"
-	"go.mongodb.org/mongo-driver/mongo"
-	"go.mongodb.org/mongo-driver/mongo/options"
 )
 
 type SignedDetails struct {
-	First_name    string
-	Last_name     string
-	Email_address string
-	User_type     string
-	User_id       string
+	First_name     string
+	Last_name      string
+	Email_address  string
+	User_type      string
+	User_id        string
+	Email_verified bool
+	Token_type     string
+	Session_id     string `json:",omitempty"` // the session access and refresh tokens belong to
+
+	// Mfa_enrollment_required marks access tokens of admins who must enable two-factor
+	// authentication; the middleware only lets them reach the enrolment endpoints.
+	Mfa_enrollment_required bool `json:",omitempty"`
+
+	// Token_generation is the user's token generation when the token was issued; the
+	// token is revoked once the user's has moved on (see RevokeAllTokens).
+	Token_generation int64 `json:",omitempty"`
 	jwt.RegisteredClaims
 }
 
-var userCollection *mongo.Collection = database.OpenCollection(database.Client, "user")
+// Values of SignedDetails.Token_type. Each token is only accepted where its type is expected,
+// so a refresh token cannot be used to call the API and vice versa.
+const (
+	AccessTokenType            string = "access"
+	RefreshTokenType           string = "refresh"
+	EmailVerificationTokenType string = "email_verification"
+	MFAChallengeTokenType      string = "mfa_challenge"
+)
 
-var SECRET_KEY = os.Getenv("SECRET_KEY")
+// MFAChallengeTokenLifetime is how long a user has to enter their second factor after the password.
+const MFAChallengeTokenLifetime time.Duration = 5 * time.Minute
 
-// Handle matching of user type to user `role`
-func CheckUserType(c *gin.Context, role string) (err error) {
-	userType := c.GetString("user_type")
-	err = nil
+// Handle the generation of the token & refreshToken of a session using JWT
+func (s *Service) GenerateAllTokens(user models.User, sessionId string, mfaEnrollmentRequired bool) (signedToken, signedRefreshToken string, err error) {
+	nowTime := time.Now()
+	userId := user.User_id
 
-	if userType != role {
-		err = errors.New("Unauthorized to access this resource.")
-		return err
+	claims := &SignedDetails{
+		Email_address:  *user.Email_address,
+		First_name:     *user.First_name,
+		Last_name:      *user.Last_name,
+		User_type:      *user.User_type,
+		User_id:        userId,
+		Email_verified: user.IsEmailVerified(),
+		Token_type:     AccessTokenType,
+		Session_id:     sessionId,
+
+		Mfa_enrollment_required: mfaEnrollmentRequired,
+		Token_generation:        user.Token_generation,
+		RegisteredClaims: jwt.RegisteredClaims{
+			ID:        primitive.NewObjectID().Hex(),
+			Subject:   userId,
+			IssuedAt:  jwt.NewNumericDate(nowTime),
+			ExpiresAt: jwt.NewNumericDate(nowTime.Add(s.AccessTokenLifetime)),
+		},
 	}
 
-	return err
-}
+	// The refresh token only identifies the user and session; the profile claims
+	// are read again from the user document when it is exchanged.
+	refreshClaims := &SignedDetails{
+		User_id:          userId,
+		Token_type:       RefreshTokenType,
+		Session_id:       sessionId,
+		Token_generation: user.Token_generation,
+		RegisteredClaims: jwt.RegisteredClaims{
+			ID:        primitive.NewObjectID().Hex(),
+			Subject:   userId,
+			IssuedAt:  jwt.NewNumericDate(nowTime),
+			ExpiresAt: jwt.NewNumericDate(nowTime.Add(s.RefreshTokenLifetime)),
+		},
+	}
 
-// Handle verification of user type to user_id matching
-func MatchUserTypeToUid(c *gin.Context, userId string) (err error) {
-	userType := c.GetString("user_type")
-	uid := c.GetString("uid")
-	err = nil
+	token, err := s.signClaims(claims)
+	if err != nil {
+		return "", "", err
+	}
 
-	if userType == "USER" && uid != userId {
-		err = errors.New("Unauthorized to access this resource.")
-		return err
+	refreshToken, err := s.signClaims(refreshClaims)
+	if err != nil {
+		return "", "", err
 	}
 
-	err = CheckUserType(c, userType)
-	return err
+	return token, refreshToken, nil
 }
 
-// Handle the generation and refresh of token & refreshToken using JWT
-func GenerateAllTokens(emailAddress, firstName, lastName, userType, userId string) (signedToken, signedRefreshToken string, err error) {
+// Handle the generation of the signed token in email verification links.
+// It is only valid for the email address it was sent to.
+func (s *Service) GenerateEmailVerificationToken(emailAddress, userId string) (string, error) {
 	nowTime := time.Now()
-	expireTokenTime := nowTime.Local().Add(time.Hour * time.Duration(24)).Unix()
-	expireRefreshTokenTime := nowTime.Local().Add(time.Hour * time.Duration(168)).Unix()
 
 	claims := &SignedDetails{
 		Email_address: emailAddress,
-		First_name:    firstName,
-		Last_name:     lastName,
-		User_type:     userType,
 		User_id:       userId,
+		Token_type:    EmailVerificationTokenType,
 		RegisteredClaims: jwt.RegisteredClaims{
-			// Use time.Unix to convert Unix timestamp value in int64 (expireTokenTime) to a time.Time value
-			ExpiresAt: jwt.NewNumericDate(time.Unix(expireTokenTime, 0)),
+			ID:        primitive.NewObjectID().Hex(),
+			Subject:   userId,
+			IssuedAt:  jwt.NewNumericDate(nowTime),
+			ExpiresAt: jwt.NewNumericDate(nowTime.Add(s.EmailVerificationTokenLifetime)),
 		},
 	}
 
-	refreshClaims := &SignedDetails{
+	return s.signClaims(claims)
+}
+
+// Handle the generation of the challenge token returned by login when the user has
+// two-factor authentication enabled. It proves the password was checked and is
+// exchanged for real tokens together with a code.
+func (s *Service) GenerateMFAChallengeToken(user models.User) (string, error) {
+	nowTime := time.Now()
+
+	claims := &SignedDetails{
+		Email_address:    *user.Email_address,
+		User_id:          user.User_id,
+		Token_type:       MFAChallengeTokenType,
+		Token_generation: user.Token_generation,
 		RegisteredClaims: jwt.RegisteredClaims{
-			// Use time.Unix to convert Unix timestamp value in int64 (expireRefreshTokenTime) to a time.Time value
-			ExpiresAt: jwt.NewNumericDate(time.Unix(expireRefreshTokenTime, 0)),
+			ID:        primitive.NewObjectID().Hex(),
+			Subject:   user.User_id,
+			IssuedAt:  jwt.NewNumericDate(nowTime),
+			ExpiresAt: jwt.NewNumericDate(nowTime.Add(MFAChallengeTokenLifetime)),
 		},
 	}
 
-	tokenClaims := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
-	token, err := tokenClaims.SignedString([]byte(SECRET_KEY))
-
-	refreshTokenClaims := jwt.NewWithClaims(jwt.SigningMethodHS256, refreshClaims)
-	refreshToken, err := refreshTokenClaims.SignedString([]byte(SECRET_KEY))
+	return s.signClaims(claims)
+}
 
-	if err != nil {
-		log.Panic(err)
-		return
+func (s *Service) signClaims(claims *SignedDetails) (string, error) {
+	if s.SigningKeys == nil {
+		return "", errNoSigningKeys
 	}
+	return s.SigningKeys.Sign(claims)
+}
+
+// Handles access token validation
+func (s *Service) ValidateToken(signedToken string) (claims *SignedDetails, msg string) {
+	return s.validateTokenOfType(signedToken, AccessTokenType)
+}
+
+// Handles refresh token validation
+func (s *Service) ValidateRefreshToken(signedToken string) (claims *SignedDetails, msg string) {
+	return s.validateTokenOfType(signedToken, RefreshTokenType)
+}
+
+// Handles email verification token validation
+func (s *Service) ValidateEmailVerificationToken(signedToken string) (claims *SignedDetails, msg string) {
+	return s.validateTokenOfType(signedToken, EmailVerificationTokenType)
+}
 
-	return token, refreshToken, err
+// Handles MFA challenge token validation
+func (s *Service) ValidateMFAChallengeToken(signedToken string) (claims *SignedDetails, msg string) {
+	return s.validateTokenOfType(signedToken, MFAChallengeTokenType)
 }
 
-// Handles token validation
-func ValidateToken(signedToken string) (claims *SignedDetails, msg string) {
+func (s *Service) validateTokenOfType(signedToken, tokenType string) (claims *SignedDetails, msg string) {
+	// ParseWithClaims also rejects expired tokens
 	token, err := jwt.ParseWithClaims(
 		signedToken,
 		&SignedDetails{},
 		func(token *jwt.Token) (interface{}, error) {
-			return []byte(SECRET_KEY), nil
+			if s.SigningKeys == nil {
+				return nil, errNoSigningKeys
+			}
+			return s.SigningKeys.Keyfunc(token)
 		},
+		jwt.WithValidMethods(jwtkeys.Algorithms),
 	)
 
 	if err != nil {
@@ -114,53 +185,15 @@ func ValidateToken(signedToken string) (claims *SignedDetails, msg string) {
 	}
 
 	claims, ok := token.Claims.(*SignedDetails)
-	if !ok {
+	if !ok || !token.Valid {
 		msg = fmt.Sprintf("The token is invalid")
-		msg = err.Error()
-		return
+		return nil, msg
 	}
 
-	if claims.ExpiresAt != nil {
-		expireTime := (*claims.ExpiresAt).Time
-
-		if expireTime.Before(time.Now().Local()) {
-			msg = fmt.Sprintf("Token is expired")
-			msg = err.Error()
-			return
-		}
+	if claims.Token_type != tokenType || claims.User_id == "" {
+		msg = fmt.Sprintf("The token is not a valid %s token", tokenType)
+		return nil, msg
 	}
 
 	return claims, msg
 }
-
-// Handles token update
-func UpdateAllTokens(signedToken, signedRefreshToken, userId string) {
-	var updateObj primitive.D
-
-	updateObj = append(updateObj, bson.E{Key: "token", Value: signedToken})
-	updateObj = append(updateObj, bson.E{Key: "refresh_token", Value: signedRefreshToken})
-
-	Updated_at, _ := time.Parse(time.RFC3339, time.Now().Format(time.RFC3339))
-	updateObj = append(updateObj, bson.E{Key: "updated_at", Value: Updated_at})
-
-	upsert := true
-	filter := bson.M{"user_id": userId}
-	opt := options.UpdateOptions{
-		Upsert: &upsert,
-	}
-
-	update := bson.D{{Key: "$set", Value: updateObj}}
-
-	_, err := userCollection.UpdateOne(
-		context.Background(),
-		filter,
-		update,
-		&opt,
-	)
-	if err != nil {
-		log.Panic(err)
-		return
-	}
-
-	return
-}
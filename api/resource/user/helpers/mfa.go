@@ -0,0 +1,153 @@
+package helpers
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"movie-api/api/resource/user/repository"
+	"movie-api/api/totp"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+// RecoveryCodeCount is the number of recovery codes issued at a time.
+const RecoveryCodeCount int = 10
+
+var (
+	ErrMFAAlreadyEnabled = errors.New("Two-factor authentication is already enabled")
+	ErrMFANotEnabled     = errors.New("Two-factor authentication is not enabled")
+	ErrNoMFAEnrollment   = errors.New("No two-factor authentication enrolment is in progress")
+	ErrInvalidMFACode    = errors.New("The authentication code is incorrect")
+)
+
+// Handles the start of a TOTP enrolment. The new secret only protects the account
+// once ConfirmMFAEnrollment has seen a code generated from it.
+func (s *Service) StartMFAEnrollment(ctx context.Context, emailAddress, userId string) (secret, provisioningURI string, err error) {
+	secret, err = totp.GenerateSecret()
+	if err != nil {
+		return "", "", err
+	}
+
+	started, err := s.Users.SetPendingMFASecret(ctx, userId, secret)
+	if err != nil {
+		return "", "", err
+	}
+	if !started {
+		return "", "", ErrMFAAlreadyEnabled
+	}
+
+	return secret, totp.ProvisioningURI(s.MFAIssuer, emailAddress, secret), nil
+}
+
+// Handles the confirmation of a TOTP enrolment with a code from the authenticator app.
+// It enables two-factor authentication and returns the recovery codes, which are
+// only stored hashed.
+func (s *Service) ConfirmMFAEnrollment(ctx context.Context, userId, code string) (recoveryCodes []string, err error) {
+	user, err := s.Users.FindByID(ctx, userId, false)
+	if err != nil {
+		return nil, err
+	}
+	if user.Mfa_enabled {
+		return nil, ErrMFAAlreadyEnabled
+	}
+	if user.Mfa_pending_secret == "" {
+		return nil, ErrNoMFAEnrollment
+	}
+
+	step, ok := totp.Validate(user.Mfa_pending_secret, code, time.Now())
+	if !ok {
+		return nil, ErrInvalidMFACode
+	}
+
+	recoveryCodes, hashes, err := newRecoveryCodes(s.BcryptCost)
+	if err != nil {
+		return nil, err
+	}
+
+	// Only enable the secret that was checked, in case enrolment was restarted meanwhile
+	enabled, err := s.Users.EnableMFA(ctx, userId, user.Mfa_pending_secret, hashes, step, time.Now())
+	if err != nil {
+		return nil, err
+	}
+	if !enabled {
+		return nil, ErrNoMFAEnrollment
+	}
+
+	return recoveryCodes, nil
+}
+
+// Handles checking a TOTP code against the user's enabled secret. Each code is
+// accepted once: the time step it belongs to is recorded and older steps are refused.
+func (s *Service) VerifyMFACode(ctx context.Context, userId, secret, code string) (bool, error) {
+	step, ok := totp.Validate(secret, code, time.Now())
+	if !ok {
+		return false, nil
+	}
+
+	return s.Users.RecordMFAStep(ctx, userId, step)
+}
+
+// Handles redeeming a recovery code. A matching code is removed so it cannot be used again.
+func (s *Service) UseRecoveryCode(ctx context.Context, userId string, hashes []string, code string) (bool, error) {
+	code = totp.NormalizeRecoveryCode(code)
+
+	for _, hash := range hashes {
+		if bcrypt.CompareHashAndPassword([]byte(hash), []byte(code)) != nil {
+			continue
+		}
+
+		// The filter on the hash makes concurrent use of the same code succeed only once
+		return s.Users.RemoveRecoveryCode(ctx, userId, hash)
+	}
+
+	return false, nil
+}
+
+// Handles replacing the user's recovery codes with a new set.
+func (s *Service) RegenerateRecoveryCodes(ctx context.Context, userId string) ([]string, error) {
+	recoveryCodes, hashes, err := newRecoveryCodes(s.BcryptCost)
+	if err != nil {
+		return nil, err
+	}
+
+	replaced, err := s.Users.SetRecoveryCodes(ctx, userId, hashes, time.Now())
+	if err != nil {
+		return nil, err
+	}
+	if !replaced {
+		return nil, ErrMFANotEnabled
+	}
+
+	return recoveryCodes, nil
+}
+
+// Handles turning two-factor authentication off and forgetting the secret and recovery codes.
+func (s *Service) DisableMFA(ctx context.Context, userId string) error {
+	found, err := s.Users.DisableMFA(ctx, userId, time.Now())
+	if err != nil {
+		return err
+	}
+	if !found {
+		return repository.ErrUserNotFound
+	}
+
+	return nil
+}
+
+func newRecoveryCodes(cost int) (codes, hashes []string, err error) {
+	codes, err = totp.GenerateRecoveryCodes(RecoveryCodeCount)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	for _, code := range codes {
+		hash, err := bcrypt.GenerateFromPassword([]byte(code), cost)
+		if err != nil {
+			return nil, nil, err
+		}
+		hashes = append(hashes, string(hash))
+	}
+
+	return codes, hashes, nil
+}
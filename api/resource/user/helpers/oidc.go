@@ -0,0 +1,163 @@
+package helpers
+
+import (
+	"context"
+	"crypto/subtle"
+	"errors"
+	"strings"
+	"time"
+
+	"movie-api/api/oidc"
+	models "movie-api/api/resource/user/model"
+	"movie-api/api/resource/user/repository"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// OIDCStateLifetime is how long a user has to log in at the provider.
+const OIDCStateLifetime time.Duration = 10 * time.Minute
+
+var (
+	ErrInvalidOIDCState = errors.New("Login request is invalid or has expired. Please try again")
+	ErrOIDCNoEmail      = errors.New("The identity provider did not share an email address")
+	ErrOIDCEmailInUse   = errors.New("An account with this email address already exists. Log in with your password to use it")
+)
+
+// Handles the start of an OpenID Connect login. It records the state, nonce and PKCE
+// code verifier and returns the provider URL to send the user to, together with the
+// state, which the caller binds to the user's browser.
+func (s *Service) StartOIDCLogin(ctx context.Context) (authURL, state string, err error) {
+	state, err = oidc.RandomString()
+	if err != nil {
+		return "", "", err
+	}
+	nonce, err := oidc.RandomString()
+	if err != nil {
+		return "", "", err
+	}
+	codeVerifier, err := oidc.RandomString()
+	if err != nil {
+		return "", "", err
+	}
+
+	authURL, err = s.OIDCClient.AuthCodeURL(ctx, state, nonce, oidc.CodeChallengeS256(codeVerifier))
+	if err != nil {
+		return "", "", err
+	}
+
+	// Only a hash of the state is stored; the nonce and PKCE code verifier are kept for the callback
+	err = s.Tokens.SaveOIDCLoginState(ctx, models.OIDCLoginState{
+		State_hash:    HashToken(state),
+		Nonce:         nonce,
+		Code_verifier: codeVerifier,
+		Expires_at:    time.Now().Add(OIDCStateLifetime),
+	})
+	if err != nil {
+		return "", "", err
+	}
+
+	return authURL, state, nil
+}
+
+// Handles the provider's redirect back. boundState is the state StartOIDCLogin bound to
+// the browser; a redirect carrying another one was not started there and is rejected
+// without using up the state. The state is single use: it is deleted before the
+// authorization code is exchanged for the verified ID token claims.
+func (s *Service) FinishOIDCLogin(ctx context.Context, state, boundState, code string) (*oidc.Claims, error) {
+	if boundState == "" || subtle.ConstantTimeCompare([]byte(state), []byte(boundState)) != 1 {
+		return nil, ErrInvalidOIDCState
+	}
+
+	pending, found, err := s.Tokens.ConsumeOIDCLoginState(ctx, HashToken(state), time.Now())
+	if err != nil {
+		return nil, err
+	}
+	if !found {
+		return nil, ErrInvalidOIDCState
+	}
+
+	return s.OIDCClient.Exchange(ctx, code, pending.Code_verifier, pending.Nonce)
+}
+
+// Handles finding the user an OpenID Connect identity belongs to. An identity seen for
+// the first time is linked to the account with the same email address when both the
+// provider and this service have verified that address, or gets a new account without
+// a password otherwise. created reports a new account.
+func (s *Service) FindOrCreateOIDCUser(ctx context.Context, claims *oidc.Claims) (user models.User, created bool, err error) {
+	issuer := s.OIDCClient.Config.Issuer
+
+	user, err = s.Users.FindByOIDCIdentity(ctx, issuer, claims.Subject)
+	if !errors.Is(err, repository.ErrUserNotFound) {
+		return user, false, err
+	}
+
+	if claims.Email == "" {
+		return user, false, ErrOIDCNoEmail
+	}
+
+	now, _ := time.Parse(time.RFC3339, time.Now().Format(time.RFC3339))
+	identity := models.OIDCIdentity{Issuer: issuer, Subject: claims.Subject, Linked_at: now}
+
+	// Deleted accounts keep their email address until they are purged
+	existing, err := s.Users.FindByEmail(ctx, claims.Email, true)
+	switch {
+	case err == nil:
+		// Linking on an unverified address would hand the account to whoever registered it first
+		if !claims.Email_verified || !existing.IsEmailVerified() || existing.Deleted_at != nil {
+			return user, false, ErrOIDCEmailInUse
+		}
+
+		user, err = s.Users.LinkOIDCIdentity(ctx, existing.User_id, identity, now)
+		return user, false, err
+
+	case !errors.Is(err, repository.ErrUserNotFound):
+		return user, false, err
+	}
+
+	// The address may have been taken since it was looked up
+	user = newOIDCUser(claims, identity, now)
+	err = s.Users.Insert(ctx, user)
+	switch {
+	case errors.Is(err, repository.ErrDuplicateEmail):
+		return user, false, ErrOIDCEmailInUse
+	case err != nil:
+		return user, false, err
+	}
+
+	return user, true, nil
+}
+
+func newOIDCUser(claims *oidc.Claims, identity models.OIDCIdentity, now time.Time) models.User {
+	firstName, lastName := claims.Given_name, claims.Family_name
+	if firstName == "" && lastName == "" {
+		firstName, lastName, _ = strings.Cut(claims.Name, " ")
+	}
+
+	userType := "USER"
+	emailAddress := claims.Email
+	emailVerified := claims.Email_verified
+
+	user := models.User{
+		ID:              primitive.NewObjectID(),
+		First_name:      &firstName,
+		Last_name:       &lastName,
+		Email_address:   &emailAddress,
+		User_type:       &userType,
+		Created_at:      now,
+		Updated_at:      now,
+		Email_verified:  &emailVerified,
+		Oidc_identities: []models.OIDCIdentity{identity},
+	}
+	user.User_id = user.ID.Hex()
+
+	if claims.Picture != "" {
+		user.Profile_photo = &claims.Picture
+	}
+	if emailVerified {
+		user.Email_verified_at = &now
+	} else {
+		user.Verification_sent_at = &now
+	}
+
+	return user
+}
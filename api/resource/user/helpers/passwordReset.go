@@ -0,0 +1,62 @@
+package helpers
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"time"
+)
+
+// ErrInvalidResetToken is returned for reset tokens that are unknown, expired or already used.
+var ErrInvalidResetToken = errors.New("Reset token is invalid or has expired")
+
+// Handles the creation of a single-use password reset token for the user.
+func (s *Service) CreatePasswordResetToken(ctx context.Context, userId string) (string, error) {
+	token, err := GenerateRandomToken()
+	if err != nil {
+		return "", err
+	}
+
+	// Only a SHA-256 hash of the token is stored, so the stored tokens
+	// cannot be used to reset passwords if they leak
+	now := time.Now()
+	err = s.Tokens.CreatePasswordReset(ctx, HashToken(token), userId, now, now.Add(s.PasswordResetTokenLifetime))
+	if err != nil {
+		return "", err
+	}
+
+	return token, nil
+}
+
+// Handles redeeming a password reset token. The token, and every other reset token
+// of the same user, cannot be used again afterwards.
+func (s *Service) ConsumePasswordResetToken(ctx context.Context, token string) (userId string, err error) {
+	userId, found, err := s.Tokens.ConsumePasswordReset(ctx, HashToken(token), time.Now())
+	if err != nil {
+		return "", err
+	}
+	if !found {
+		return "", ErrInvalidResetToken
+	}
+
+	return userId, nil
+}
+
+// GenerateRandomToken returns 32 random bytes encoded for use in URLs.
+func GenerateRandomToken() (string, error) {
+	buffer := make([]byte, 32)
+	if _, err := rand.Read(buffer); err != nil {
+		return "", err
+	}
+
+	return base64.RawURLEncoding.EncodeToString(buffer), nil
+}
+
+// HashToken returns the hex encoded SHA-256 hash under which a random token is stored.
+func HashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,51 @@
+package helpers
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"movie-api/api/resource/user/repository"
+)
+
+// Handles revocation of a single token until it expires.
+func (s *Service) RevokeToken(ctx context.Context, claims *SignedDetails) error {
+	expiresAt := time.Now().Add(s.RefreshTokenLifetime)
+	if claims.ExpiresAt != nil {
+		expiresAt = claims.ExpiresAt.Time
+	}
+
+	return s.Tokens.RevokeToken(ctx, claims.ID, claims.User_id, expiresAt)
+}
+
+// Handles revocation of every token issued to the user so far, e.g. on logout from
+// all devices or after a refresh token was reused. The user has to log in again.
+func (s *Service) RevokeAllTokens(ctx context.Context, userId string) error {
+	// Tokens carry the generation they were issued in, so advancing it revokes all of
+	// them at once. Tokens issued from here on carry the new one and stay valid.
+	if _, err := s.Users.IncrementTokenGeneration(ctx, userId); err != nil {
+		return err
+	}
+
+	// Ending the sessions stops their refresh tokens from being exchanged
+	return s.Sessions.DeleteByUser(ctx, userId)
+}
+
+// Handles checking a validated token against the revocation list and the token
+// generation of its user. Tokens of users that no longer exist are revoked too.
+func (s *Service) IsTokenRevoked(ctx context.Context, claims *SignedDetails) (bool, error) {
+	revoked, err := s.Tokens.IsRevoked(ctx, claims.ID, claims.Session_id)
+	if err != nil || revoked {
+		return revoked, err
+	}
+
+	generation, err := s.Users.TokenGeneration(ctx, claims.User_id)
+	if errors.Is(err, repository.ErrUserNotFound) {
+		return true, nil
+	}
+	if err != nil {
+		return false, err
+	}
+
+	return claims.Token_generation != generation, nil
+}
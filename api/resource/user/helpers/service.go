@@ -0,0 +1,115 @@
+package helpers
+
+import (
+	"fmt"
+	"log"
+	"time"
+
+	"movie-api/api/jwtkeys"
+	"movie-api/api/loginguard"
+	"movie-api/api/mail"
+	"movie-api/api/oidc"
+	userRepository "movie-api/api/resource/user/repository"
+	"movie-api/api/storage"
+)
+
+// Config holds the settings of the account operations.
+type Config struct {
+	// AppBaseURL is the public address links in emails point to.
+	AppBaseURL string
+
+	// MFAIssuer names the service in authenticator apps.
+	MFAIssuer string
+
+	// DeletionGracePeriod is how long a deleted account can still be restored before it is purged.
+	DeletionGracePeriod time.Duration
+
+	// LoginGuardStore is where failed logins are counted: "mongo" uses the storage backend,
+	// so that all replicas share the counters, and "memory" keeps them in the instance.
+	LoginGuardStore string
+
+	AccessTokenLifetime            time.Duration
+	RefreshTokenLifetime           time.Duration
+	EmailVerificationTokenLifetime time.Duration
+	PasswordResetTokenLifetime     time.Duration
+
+	// BcryptCost is the work factor of password and recovery code hashes.
+	BcryptCost int
+
+	// OIDC configures login with an OpenID Connect provider. It is nil when OpenID Connect
+	// login is not configured.
+	OIDC *oidc.Config
+}
+
+// Service carries out the account, token and session operations of one API instance.
+// Instances share no state, so several can run in one process.
+type Service struct {
+	Users    userRepository.UserRepository
+	Tokens   userRepository.TokenRepository
+	Sessions userRepository.SessionRepository
+	APIKeys  userRepository.APIKeyRepository
+	Settings userRepository.SettingsRepository
+
+	// LoginGuard throttles failed logins per account and per client IP address.
+	LoginGuard *loginguard.Guard
+
+	// SigningKeys sign and verify all tokens.
+	SigningKeys *jwtkeys.Keyring
+
+	// OIDCClient logs users in with the OpenID Connect provider. It is nil when
+	// OpenID Connect login is not configured.
+	OIDCClient *oidc.Client
+
+	Mail   mail.Sender
+	Logger *log.Logger
+
+	AppBaseURL          string
+	MFAIssuer           string
+	DeletionGracePeriod time.Duration
+
+	AccessTokenLifetime            time.Duration
+	RefreshTokenLifetime           time.Duration
+	EmailVerificationTokenLifetime time.Duration
+	PasswordResetTokenLifetime     time.Duration
+	BcryptCost                     int
+}
+
+// NewService returns a Service working with the repositories of store.
+func NewService(config Config, store *storage.Storage, signingKeys *jwtkeys.Keyring, sender mail.Sender, logger *log.Logger) (*Service, error) {
+	service := &Service{
+		Users:    store.Users,
+		Tokens:   store.Tokens,
+		Sessions: store.Sessions,
+		APIKeys:  store.APIKeys,
+		Settings: store.Settings,
+
+		SigningKeys: signingKeys,
+		Mail:        sender,
+		Logger:      logger,
+
+		AppBaseURL:          config.AppBaseURL,
+		MFAIssuer:           config.MFAIssuer,
+		DeletionGracePeriod: config.DeletionGracePeriod,
+
+		AccessTokenLifetime:            config.AccessTokenLifetime,
+		RefreshTokenLifetime:           config.RefreshTokenLifetime,
+		EmailVerificationTokenLifetime: config.EmailVerificationTokenLifetime,
+		PasswordResetTokenLifetime:     config.PasswordResetTokenLifetime,
+		BcryptCost:                     config.BcryptCost,
+	}
+
+	switch config.LoginGuardStore {
+	case "", "mongo":
+		service.LoginGuard = loginguard.New(store.LoginAttempts)
+	case "memory":
+		service.LoginGuard = loginguard.New(loginguard.NewMemoryStore())
+	default:
+		return nil, fmt.Errorf("Unknown login guard store %q, expected mongo or memory", config.LoginGuardStore)
+	}
+
+	if config.OIDC != nil {
+		service.OIDCClient = oidc.New(*config.OIDC)
+	}
+
+	return service, nil
+}
@@ -0,0 +1,148 @@
+package helpers
+
+import (
+	"context"
+	"strings"
+	"time"
+
+	models "movie-api/api/resource/user/model"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// sessionActivityResolution limits how often requests write a session's last_active_at.
+const sessionActivityResolution time.Duration = time.Minute
+
+// NewSessionID returns the ID of a new session, to be put in its tokens.
+func NewSessionID() string {
+	return primitive.NewObjectID().Hex()
+}
+
+// Handles recording a new session with the refresh token issued for it.
+func (s *Service) CreateSession(ctx context.Context, sessionId, userId, refreshToken, userAgent, ipAddress string) error {
+	now, _ := time.Parse(time.RFC3339, time.Now().Format(time.RFC3339))
+
+	session := models.Session{
+		Session_id:         sessionId,
+		User_id:            userId,
+		Refresh_token_hash: HashToken(refreshToken),
+		Device:             DescribeDevice(userAgent),
+		User_agent:         userAgent,
+		Ip_address:         ipAddress,
+		Created_at:         now,
+		Last_active_at:     now,
+		Expires_at:         now.Add(s.RefreshTokenLifetime),
+	}
+
+	return s.Sessions.Insert(ctx, session)
+}
+
+// Handles refresh token rotation within a session. The new refresh token is only
+// stored while the session's current one is still currentRefreshToken, so rotating
+// the same refresh token twice succeeds only once. found is false when the session
+// has ended; rotated is false when the token had already been replaced.
+func (s *Service) RotateSessionRefreshToken(ctx context.Context, sessionId, currentRefreshToken, newRefreshToken, userAgent, ipAddress string) (found, rotated bool, err error) {
+	now := time.Now()
+
+	next := models.Session{
+		Refresh_token_hash: HashToken(newRefreshToken),
+		User_agent:         userAgent,
+		Device:             DescribeDevice(userAgent),
+		Ip_address:         ipAddress,
+		Last_active_at:     now,
+		Expires_at:         now.Add(s.RefreshTokenLifetime),
+	}
+
+	return s.Sessions.RotateRefreshToken(ctx, sessionId, HashToken(currentRefreshToken), next)
+}
+
+// Handles recording activity on a session. Writes are limited to one per
+// sessionActivityResolution.
+func (s *Service) TouchSession(ctx context.Context, sessionId, ipAddress string) error {
+	now := time.Now()
+
+	return s.Sessions.Touch(ctx, sessionId, ipAddress, now, now.Add(-sessionActivityResolution))
+}
+
+// Handles listing the user's sessions, most recently active first.
+func (s *Service) ListSessions(ctx context.Context, userId string) ([]models.Session, error) {
+	return s.Sessions.ListByUser(ctx, userId)
+}
+
+// Handles ending one of the user's sessions. Its refresh token stops working and its
+// access tokens are revoked. found is false when the user has no such session.
+func (s *Service) RevokeSession(ctx context.Context, userId, sessionId string) (found bool, err error) {
+	found, err = s.Sessions.Delete(ctx, userId, sessionId)
+	if err != nil || !found {
+		return false, err
+	}
+
+	return true, s.revokeSessionTokens(ctx, userId, []string{sessionId})
+}
+
+// Handles ending all of the user's sessions except keepSessionId, which may be empty
+// to end them all. It returns the number of sessions ended.
+func (s *Service) RevokeOtherSessions(ctx context.Context, userId, keepSessionId string) (int, error) {
+	sessionIds, err := s.Sessions.DeleteOthers(ctx, userId, keepSessionId)
+	if err != nil || len(sessionIds) == 0 {
+		return 0, err
+	}
+
+	return len(sessionIds), s.revokeSessionTokens(ctx, userId, sessionIds)
+}
+
+// revokeSessionTokens puts the sessions on the revocation list until the last access
+// token issued for them has expired.
+func (s *Service) revokeSessionTokens(ctx context.Context, userId string, sessionIds []string) error {
+	return s.Tokens.RevokeSessions(ctx, userId, sessionIds, time.Now().Add(s.AccessTokenLifetime))
+}
+
+// DescribeDevice returns a short description such as "Firefox on Windows" of the
+// device a User-Agent header comes from.
+func DescribeDevice(userAgent string) string {
+	if userAgent == "" {
+		return "Unknown device"
+	}
+
+	// Order matters: e.g. Edge and Chrome user agents also mention Safari
+	client := "Unknown client"
+	for _, candidate := range []struct{ token, name string }{
+		{"Edg/", "Edge"},
+		{"OPR/", "Opera"},
+		{"Firefox/", "Firefox"},
+		{"Chrome/", "Chrome"},
+		{"CriOS/", "Chrome"},
+		{"Safari/", "Safari"},
+		{"curl/", "curl"},
+		{"okhttp/", "OkHttp"},
+		{"python-requests/", "Python"},
+		{"Go-http-client/", "Go"},
+		{"PostmanRuntime/", "Postman"},
+	} {
+		if strings.Contains(userAgent, candidate.token) {
+			client = candidate.name
+			break
+		}
+	}
+
+	system := ""
+	for _, candidate := range []struct{ token, name string }{
+		{"iPhone", "iPhone"},
+		{"iPad", "iPad"},
+		{"Android", "Android"},
+		{"Windows", "Windows"},
+		{"Mac OS X", "macOS"},
+		{"CrOS", "ChromeOS"},
+		{"Linux", "Linux"},
+	} {
+		if strings.Contains(userAgent, candidate.token) {
+			system = candidate.name
+			break
+		}
+	}
+
+	if system == "" {
+		return client
+	}
+	return client + " on " + system
+}
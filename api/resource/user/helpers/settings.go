@@ -0,0 +1,36 @@
+package helpers
+
+import (
+	"context"
+	"time"
+
+	models "movie-api/api/resource/user/model"
+)
+
+// Handles reading the security settings. Defaults apply until an admin saves them.
+func (s *Service) GetSecuritySettings(ctx context.Context) (models.SecuritySettings, error) {
+	return s.Settings.GetSecuritySettings(ctx)
+}
+
+// Handles saving the security settings.
+func (s *Service) UpdateSecuritySettings(ctx context.Context, settings models.SecuritySettings) (models.SecuritySettings, error) {
+	settings.Updated_at, _ = time.Parse(time.RFC3339, time.Now().Format(time.RFC3339))
+
+	return settings, s.Settings.SaveSecuritySettings(ctx, settings)
+}
+
+// Handles deciding whether the user must enable two-factor authentication before
+// their tokens may be used, which is the case for admins without it while the
+// security settings require it.
+func (s *Service) MFAEnrollmentRequired(ctx context.Context, user models.User) (bool, error) {
+	if user.Mfa_enabled || user.User_type == nil || *user.User_type != "ADMIN" {
+		return false, nil
+	}
+
+	settings, err := s.GetSecuritySettings(ctx)
+	if err != nil {
+		return false, err
+	}
+
+	return settings.Require_admin_mfa, nil
+}
@@ -0,0 +1,27 @@
+package helpers
+
+import (
+	"context"
+	"errors"
+	"time"
+)
+
+var errNoSigningKeys = errors.New("JWT signing keys are not loaded")
+
+// RunKeyReloader reads the key directory again every interval until ctx is done, so
+// keys added for rotation are published and start signing without a restart.
+func (s *Service) RunKeyReloader(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if err := s.SigningKeys.Reload(); err != nil {
+				s.Logger.Println("Error reloading JWT signing keys, keeping the current ones: ", err)
+			}
+		}
+	}
+}
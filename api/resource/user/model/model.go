@@ -8,16 +8,285 @@ import (
 
 type User struct {
 	ID            primitive.ObjectID `bson:"_id"`
-	First_name    *string            `json:"first_name"validate:"required,min=2,max=100"`
-	Last_name     *string            `json:"last_name"validate:"required,min=2,max=100"`
+	First_name    *string            `json:"first_name" validate:"required,min=2,max=100"`
+	Last_name     *string            `json:"last_name" validate:"required,min=2,max=100"`
 	Profile_photo *string            `json:"profile_photo"`
-	Password      *string            `json:"password"validate:"required,min=6"`
-	Email_address *string            `json:"email_address"validate:"email,required"`
-	Phone_number  *string            `json:"phone_number"validate:"required"`
-	Token         *string            `json:"token"`
-	User_type     *string            `json:"user_type"validate:"required,eq=ADMIN|eq=USER"`
-	Refresh_token *string            `json:"refresh_token"`
+	Password      *string            `json:"password" validate:"required,min=6"`
+	Email_address *string            `json:"email_address" validate:"email,required"`
+	Phone_number  *string            `json:"phone_number" validate:"required"`
+	User_type     *string            `json:"user_type" validate:"required,eq=ADMIN|eq=USER"`
 	Created_at    time.Time          `json:"created_at"`
 	Updated_at    time.Time          `json:"updated_at"`
 	User_id       string             `json:"user_id"`
+	Deleted_at    *time.Time         `json:"-" bson:"deleted_at,omitempty"`
+	Purge_after   *time.Time         `json:"-" bson:"purge_after,omitempty"`
+
+	// Email_verified is nil for accounts created before email verification was introduced;
+	// they count as verified.
+	Email_verified       *bool      `json:"-" bson:"email_verified,omitempty"`
+	Email_verified_at    *time.Time `json:"-" bson:"email_verified_at,omitempty"`
+	Verification_sent_at *time.Time `json:"-" bson:"verification_sent_at,omitempty"`
+
+	// Two-factor authentication. Mfa_pending_secret holds the secret of an enrolment
+	// that has not been confirmed with a code yet; recovery codes are stored as bcrypt hashes.
+	Mfa_enabled        bool     `json:"-" bson:"mfa_enabled,omitempty"`
+	Mfa_secret         string   `json:"-" bson:"mfa_secret,omitempty"`
+	Mfa_pending_secret string   `json:"-" bson:"mfa_pending_secret,omitempty"`
+	Mfa_recovery_codes []string `json:"-" bson:"mfa_recovery_codes,omitempty"`
+	Mfa_last_used_step int64    `json:"-" bson:"mfa_last_used_step,omitempty"` // rejects a code being used twice
+
+	// Oidc_identities are the external accounts the user can log in with.
+	Oidc_identities []OIDCIdentity `json:"-" bson:"oidc_identities,omitempty"`
+
+	// Token_generation is copied into the user's tokens. Revoking all tokens of the user
+	// advances it, so every token issued before carries a stale one.
+	Token_generation int64 `json:"-" bson:"token_generation,omitempty"`
+}
+
+// OIDCIdentity is an account at an OpenID Connect provider, identified by the
+// provider's issuer and the subject it assigns to the user.
+type OIDCIdentity struct {
+	Issuer    string    `json:"issuer"`
+	Subject   string    `json:"subject"`
+	Linked_at time.Time `json:"linked_at"`
+}
+
+// IsEmailVerified reports whether the user has verified their email address.
+func (user User) IsEmailVerified() bool {
+	return user.Email_verified == nil || *user.Email_verified
+}
+
+// UpdateUserRequest holds the profile fields a PATCH may change. Fields left out are not changed.
+type UpdateUserRequest struct {
+	First_name    *string `json:"first_name" validate:"omitempty,min=2,max=100"`
+	Last_name     *string `json:"last_name" validate:"omitempty,min=2,max=100"`
+	Phone_number  *string `json:"phone_number" validate:"omitempty,min=1"`
+	Profile_photo *string `json:"profile_photo" validate:"omitempty,max=2048"`
+	User_type     *string `json:"user_type" validate:"omitempty,eq=ADMIN|eq=USER"` // admins only
+}
+
+// UserFilter narrows a user listing. Zero values leave a criterion out.
+type UserFilter struct {
+	Status    string // active (the default), deleted or all
+	User_type string
+	Query     string // part of the email address, first name or last name, ignoring case
+}
+
+// RegisterRequest holds the fields a user chooses when signing up. It has no user_type:
+// every account starts as USER, and only admins can change that.
+type RegisterRequest struct {
+	First_name    *string `json:"first_name" validate:"required,min=2,max=100"`
+	Last_name     *string `json:"last_name" validate:"required,min=2,max=100"`
+	Profile_photo *string `json:"profile_photo"`
+	Password      *string `json:"password" validate:"required,min=6"`
+	Email_address *string `json:"email_address" validate:"email,required"`
+	Phone_number  *string `json:"phone_number" validate:"required"`
+}
+
+type LoginRequest struct {
+	Email_address *string `json:"email_address" validate:"required,email"`
+	Password      *string `json:"password" validate:"required"`
+}
+
+type ChangePasswordRequest struct {
+	Current_password string `json:"current_password" validate:"required"`
+	New_password     string `json:"new_password" validate:"required,min=6"`
+}
+
+type ForgotPasswordRequest struct {
+	Email_address string `json:"email_address" validate:"required,email"`
+}
+
+type ResetPasswordRequest struct {
+	Token        string `json:"token" validate:"required"`
+	New_password string `json:"new_password" validate:"required,min=6"`
+}
+
+type RefreshTokenRequest struct {
+	Refresh_token string `json:"refresh_token" validate:"required"`
+}
+
+// MFALoginRequest completes a login with either a code from the authenticator app or a recovery code.
+type MFALoginRequest struct {
+	Mfa_token     string `json:"mfa_token" validate:"required"`
+	Code          string `json:"code" validate:"required_without=Recovery_code"`
+	Recovery_code string `json:"recovery_code" validate:"required_without=Code"`
+}
+
+type MFACodeRequest struct {
+	Code string `json:"code" validate:"required"`
+}
+
+type DisableMFARequest struct {
+	Password      string `json:"password" validate:"required"`
+	Code          string `json:"code" validate:"required_without=Recovery_code"`
+	Recovery_code string `json:"recovery_code" validate:"required_without=Code"`
+}
+
+// SecuritySettings are the account security rules admins can change at runtime.
+type SecuritySettings struct {
+	Require_admin_mfa bool      `json:"require_admin_mfa" bson:"require_admin_mfa"`
+	Updated_at        time.Time `json:"updated_at" bson:"updated_at"`
+}
+
+type UpdateSecuritySettingsRequest struct {
+	Require_admin_mfa *bool `json:"require_admin_mfa" validate:"required"`
+}
+
+// OIDCLoginState is an OpenID Connect login waiting for the provider's redirect back.
+// Only a hash of the state is stored; the nonce and PKCE code verifier are kept for the callback.
+type OIDCLoginState struct {
+	State_hash    string    `bson:"state_hash"`
+	Nonce         string    `bson:"nonce"`
+	Code_verifier string    `bson:"code_verifier"`
+	Expires_at    time.Time `bson:"expires_at"`
+}
+
+// SelfProfile is the user as shown to themselves.
+type SelfProfile struct {
+	User_id        string    `json:"user_id"`
+	First_name     *string   `json:"first_name"`
+	Last_name      *string   `json:"last_name"`
+	Profile_photo  *string   `json:"profile_photo"`
+	Email_address  *string   `json:"email_address"`
+	Phone_number   *string   `json:"phone_number"`
+	User_type      *string   `json:"user_type"`
+	Email_verified bool      `json:"email_verified"`
+	Mfa_enabled    bool      `json:"mfa_enabled"`
+	Created_at     time.Time `json:"created_at"`
+	Updated_at     time.Time `json:"updated_at"`
+}
+
+func NewSelfProfile(user User) SelfProfile {
+	return SelfProfile{
+		User_id:        user.User_id,
+		First_name:     user.First_name,
+		Last_name:      user.Last_name,
+		Profile_photo:  user.Profile_photo,
+		Email_address:  user.Email_address,
+		Phone_number:   user.Phone_number,
+		User_type:      user.User_type,
+		Email_verified: user.IsEmailVerified(),
+		Mfa_enabled:    user.Mfa_enabled,
+		Created_at:     user.Created_at,
+		Updated_at:     user.Updated_at,
+	}
+}
+
+// AdminUserView is the user as listed to admins. It leaves out the password hash and tokens.
+type AdminUserView struct {
+	User_id        string     `json:"user_id"`
+	First_name     *string    `json:"first_name"`
+	Last_name      *string    `json:"last_name"`
+	Profile_photo  *string    `json:"profile_photo"`
+	Email_address  *string    `json:"email_address"`
+	Phone_number   *string    `json:"phone_number"`
+	User_type      *string    `json:"user_type"`
+	Email_verified bool       `json:"email_verified"`
+	Mfa_enabled    bool       `json:"mfa_enabled"`
+	Created_at     time.Time  `json:"created_at"`
+	Updated_at     time.Time  `json:"updated_at"`
+	Deleted_at     *time.Time `json:"deleted_at,omitempty"`
+	Purge_after    *time.Time `json:"purge_after,omitempty"`
+}
+
+func NewAdminUserView(user User) AdminUserView {
+	return AdminUserView{
+		User_id:        user.User_id,
+		First_name:     user.First_name,
+		Last_name:      user.Last_name,
+		Profile_photo:  user.Profile_photo,
+		Email_address:  user.Email_address,
+		Phone_number:   user.Phone_number,
+		User_type:      user.User_type,
+		Email_verified: user.IsEmailVerified(),
+		Mfa_enabled:    user.Mfa_enabled,
+		Created_at:     user.Created_at,
+		Updated_at:     user.Updated_at,
+		Deleted_at:     user.Deleted_at,
+		Purge_after:    user.Purge_after,
+	}
+}
+
+// TokenPair is an access token with the refresh token that renews it.
+type TokenPair struct {
+	Access_token  string `json:"access_token"`
+	Refresh_token string `json:"refresh_token"`
+	Token_type    string `json:"token_type"`
+	Expires_in    int64  `json:"expires_in"` // access token lifetime in seconds
+}
+
+// AuthResponse is returned by login and registration.
+// Mfa_enrollment_required is set for admins who must enable two-factor
+// authentication before they can use their tokens for anything else.
+type AuthResponse struct {
+	User                    SelfProfile `json:"user"`
+	Tokens                  TokenPair   `json:"tokens"`
+	Mfa_enrollment_required bool        `json:"mfa_enrollment_required,omitempty"`
+}
+
+// MFAChallengeResponse is returned by login instead of tokens when the user has
+// two-factor authentication enabled. Mfa_token is exchanged at /auth/login/mfa.
+type MFAChallengeResponse struct {
+	Mfa_required bool   `json:"mfa_required"`
+	Mfa_token    string `json:"mfa_token"`
+	Expires_in   int64  `json:"expires_in"` // challenge lifetime in seconds
+}
+
+// MFAEnrollment is returned when two-factor authentication is being set up.
+// Provisioning_uri is the otpauth:// URI to show as a QR code.
+type MFAEnrollment struct {
+	Secret           string `json:"secret"`
+	Provisioning_uri string `json:"provisioning_uri"`
+}
+
+// RecoveryCodes are shown once; only their hashes are stored. Tokens is set when
+// enabling two-factor authentication replaced the caller's tokens.
+type RecoveryCodes struct {
+	Recovery_codes []string   `json:"recovery_codes"`
+	Tokens         *TokenPair `json:"tokens,omitempty"`
+}
+
+// APIKey lets a program call the API as its owner, limited to Scopes. Only a hash of
+// the key is stored; Prefix is kept to tell keys apart.
+type APIKey struct {
+	Key_id       string     `json:"key_id" bson:"key_id"`
+	User_id      string     `json:"user_id" bson:"user_id"`
+	Name         string     `json:"name" bson:"name"`
+	Prefix       string     `json:"prefix" bson:"prefix"`
+	Key_hash     string     `json:"-" bson:"key_hash"`
+	Scopes       []string   `json:"scopes" bson:"scopes"`
+	Created_at   time.Time  `json:"created_at" bson:"created_at"`
+	Expires_at   time.Time  `json:"expires_at" bson:"expires_at"`
+	Last_used_at *time.Time `json:"last_used_at" bson:"last_used_at,omitempty"`
+	Revoked_at   *time.Time `json:"revoked_at,omitempty" bson:"revoked_at,omitempty"`
+}
+
+// CreateAPIKeyRequest names a new API key. Scopes are permission names such as
+// "movies:read"; Expires_at defaults to helpers.APIKeyDefaultLifetime from now.
+type CreateAPIKeyRequest struct {
+	Name       string     `json:"name" validate:"required,max=100"`
+	Scopes     []string   `json:"scopes" validate:"required,min=1,dive,required"`
+	Expires_at *time.Time `json:"expires_at"`
+}
+
+// CreatedAPIKey is returned once, when the key is created. Key cannot be retrieved later.
+type CreatedAPIKey struct {
+	APIKey
+	Key string `json:"key"`
+}
+
+// Session is one login of a user on a device. It holds the hash of the session's
+// current refresh token, which changes every time the tokens are refreshed.
+type Session struct {
+	Session_id         string    `json:"session_id" bson:"session_id"`
+	User_id            string    `json:"user_id" bson:"user_id"`
+	Refresh_token_hash string    `json:"-" bson:"refresh_token_hash"`
+	Device             string    `json:"device" bson:"device"`
+	User_agent         string    `json:"user_agent" bson:"user_agent"`
+	Ip_address         string    `json:"ip_address" bson:"ip_address"`
+	Created_at         time.Time `json:"created_at" bson:"created_at"`
+	Last_active_at     time.Time `json:"last_active_at" bson:"last_active_at"`
+	Expires_at         time.Time `json:"expires_at" bson:"expires_at"`
+	Current            bool      `json:"current" bson:"-"` // the session making the request
 }
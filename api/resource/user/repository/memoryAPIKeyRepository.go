@@ -0,0 +1,109 @@
+package repository
+
+import (
+	"context"
+	"sort"
+	"sync"
+	"time"
+
+	models "movie-api/api/resource/user/model"
+)
+
+// MemoryAPIKeyRepository keeps the API keys in process memory.
+type MemoryAPIKeyRepository struct {
+	mu      sync.Mutex
+	apiKeys map[string]models.APIKey // by key_id
+}
+
+func NewMemoryAPIKeyRepository() *MemoryAPIKeyRepository {
+	return &MemoryAPIKeyRepository{apiKeys: map[string]models.APIKey{}}
+}
+
+func (r *MemoryAPIKeyRepository) EnsureIndexes(ctx context.Context) error {
+	return nil
+}
+
+func (r *MemoryAPIKeyRepository) Insert(ctx context.Context, apiKey models.APIKey) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.apiKeys[apiKey.Key_id] = cloneAPIKey(apiKey)
+	return nil
+}
+
+func (r *MemoryAPIKeyRepository) ListByUser(ctx context.Context, userId string) ([]models.APIKey, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	apiKeys := []models.APIKey{}
+	for _, apiKey := range r.apiKeys {
+		if apiKey.User_id == userId {
+			apiKeys = append(apiKeys, cloneAPIKey(apiKey))
+		}
+	}
+
+	sort.Slice(apiKeys, func(i, j int) bool { return apiKeys[i].Created_at.After(apiKeys[j].Created_at) })
+	return apiKeys, nil
+}
+
+func (r *MemoryAPIKeyRepository) Revoke(ctx context.Context, userId, keyId string, revokedAt time.Time) (bool, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	apiKey, ok := r.apiKeys[keyId]
+	if !ok || apiKey.User_id != userId {
+		return false, nil
+	}
+
+	if apiKey.Revoked_at == nil {
+		apiKey.Revoked_at = timePtr(revokedAt)
+		r.apiKeys[keyId] = apiKey
+	}
+
+	return true, nil
+}
+
+func (r *MemoryAPIKeyRepository) FindActiveByHash(ctx context.Context, keyHash string, now time.Time) (models.APIKey, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for _, apiKey := range r.apiKeys {
+		if apiKey.Key_hash == keyHash && apiKey.Revoked_at == nil && apiKey.Expires_at.After(now) {
+			return cloneAPIKey(apiKey), nil
+		}
+	}
+
+	return models.APIKey{}, ErrAPIKeyNotFound
+}
+
+func (r *MemoryAPIKeyRepository) TouchLastUsed(ctx context.Context, keyId string, now, staleBefore time.Time) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	apiKey, ok := r.apiKeys[keyId]
+	if !ok || (apiKey.Last_used_at != nil && !apiKey.Last_used_at.Before(staleBefore)) {
+		return nil
+	}
+
+	apiKey.Last_used_at = timePtr(now)
+	r.apiKeys[keyId] = apiKey
+	return nil
+}
+
+func (r *MemoryAPIKeyRepository) DeleteByUser(ctx context.Context, userId string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for keyId, apiKey := range r.apiKeys {
+		if apiKey.User_id == userId {
+			delete(r.apiKeys, keyId)
+		}
+	}
+
+	return nil
+}
+
+func cloneAPIKey(apiKey models.APIKey) models.APIKey {
+	apiKey.Scopes = append([]string(nil), apiKey.Scopes...)
+	return apiKey
+}
@@ -0,0 +1,148 @@
+package repository
+
+import (
+	"context"
+	"sort"
+	"sync"
+	"time"
+
+	models "movie-api/api/resource/user/model"
+)
+
+// MemorySessionRepository keeps the sessions in process memory. Expired sessions
+// are dropped whenever a new one is added and are never returned.
+type MemorySessionRepository struct {
+	mu       sync.Mutex
+	sessions map[string]models.Session // by session_id
+}
+
+func NewMemorySessionRepository() *MemorySessionRepository {
+	return &MemorySessionRepository{sessions: map[string]models.Session{}}
+}
+
+func (r *MemorySessionRepository) EnsureIndexes(ctx context.Context) error {
+	return nil
+}
+
+func (r *MemorySessionRepository) Insert(ctx context.Context, session models.Session) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	now := time.Now()
+	for sessionId, stored := range r.sessions {
+		if !stored.Expires_at.After(now) {
+			delete(r.sessions, sessionId)
+		}
+	}
+
+	session.Current = false
+	r.sessions[session.Session_id] = session
+	return nil
+}
+
+func (r *MemorySessionRepository) RotateRefreshToken(ctx context.Context, sessionId, currentHash string, next models.Session) (found, rotated bool, err error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	session, ok := r.liveLocked(sessionId)
+	if !ok {
+		return false, false, nil
+	}
+	if session.Refresh_token_hash != currentHash {
+		return true, false, nil
+	}
+
+	session.Refresh_token_hash = next.Refresh_token_hash
+	session.User_agent = next.User_agent
+	session.Device = next.Device
+	session.Ip_address = next.Ip_address
+	session.Last_active_at = next.Last_active_at
+	session.Expires_at = next.Expires_at
+	r.sessions[sessionId] = session
+
+	return true, true, nil
+}
+
+func (r *MemorySessionRepository) Touch(ctx context.Context, sessionId, ipAddress string, now, staleBefore time.Time) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	session, ok := r.liveLocked(sessionId)
+	if !ok || !session.Last_active_at.Before(staleBefore) {
+		return nil
+	}
+
+	session.Last_active_at = now
+	session.Ip_address = ipAddress
+	r.sessions[sessionId] = session
+	return nil
+}
+
+func (r *MemorySessionRepository) ListByUser(ctx context.Context, userId string) ([]models.Session, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	now := time.Now()
+	sessions := []models.Session{}
+	for _, session := range r.sessions {
+		if session.User_id == userId && session.Expires_at.After(now) {
+			sessions = append(sessions, session)
+		}
+	}
+
+	sort.Slice(sessions, func(i, j int) bool { return sessions[i].Last_active_at.After(sessions[j].Last_active_at) })
+	return sessions, nil
+}
+
+func (r *MemorySessionRepository) Delete(ctx context.Context, userId, sessionId string) (bool, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	session, ok := r.liveLocked(sessionId)
+	if !ok || session.User_id != userId {
+		return false, nil
+	}
+
+	delete(r.sessions, sessionId)
+	return true, nil
+}
+
+func (r *MemorySessionRepository) DeleteOthers(ctx context.Context, userId, keepSessionId string) ([]string, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	var sessionIds []string
+	for sessionId, session := range r.sessions {
+		if session.User_id == userId && sessionId != keepSessionId {
+			delete(r.sessions, sessionId)
+			if session.Expires_at.After(time.Now()) {
+				sessionIds = append(sessionIds, sessionId)
+			}
+		}
+	}
+
+	return sessionIds, nil
+}
+
+func (r *MemorySessionRepository) DeleteByUser(ctx context.Context, userId string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for sessionId, session := range r.sessions {
+		if session.User_id == userId {
+			delete(r.sessions, sessionId)
+		}
+	}
+
+	return nil
+}
+
+// liveLocked returns the session with sessionId unless it has expired.
+func (r *MemorySessionRepository) liveLocked(sessionId string) (models.Session, bool) {
+	session, ok := r.sessions[sessionId]
+	if !ok || !session.Expires_at.After(time.Now()) {
+		return models.Session{}, false
+	}
+
+	return session, true
+}
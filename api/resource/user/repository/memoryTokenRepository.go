@@ -0,0 +1,189 @@
+package repository
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	models "movie-api/api/resource/user/model"
+)
+
+// MemoryTokenRepository keeps the token records in process memory. Expired records
+// are dropped whenever a new one is added.
+type MemoryTokenRepository struct {
+	mu             sync.Mutex
+	revocations    []revocation
+	passwordResets map[string]passwordReset // by token hash
+	oidcStates     map[string]models.OIDCLoginState
+}
+
+// revocation is an entry of the revocation list; exactly one of tokenId and
+// sessionId is set.
+type revocation struct {
+	tokenId   string
+	sessionId string
+	userId    string
+	expiresAt time.Time
+}
+
+type passwordReset struct {
+	userId    string
+	expiresAt time.Time
+}
+
+func NewMemoryTokenRepository() *MemoryTokenRepository {
+	return &MemoryTokenRepository{
+		passwordResets: map[string]passwordReset{},
+		oidcStates:     map[string]models.OIDCLoginState{},
+	}
+}
+
+func (r *MemoryTokenRepository) EnsureIndexes(ctx context.Context) error {
+	return nil
+}
+
+func (r *MemoryTokenRepository) RevokeToken(ctx context.Context, tokenId, userId string, expiresAt time.Time) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.pruneLocked(time.Now())
+
+	for i := range r.revocations {
+		if r.revocations[i].tokenId == tokenId {
+			r.revocations[i].expiresAt = expiresAt
+			return nil
+		}
+	}
+
+	r.revocations = append(r.revocations, revocation{tokenId: tokenId, userId: userId, expiresAt: expiresAt})
+	return nil
+}
+
+func (r *MemoryTokenRepository) RevokeSessions(ctx context.Context, userId string, sessionIds []string, expiresAt time.Time) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.pruneLocked(time.Now())
+
+	for _, sessionId := range sessionIds {
+		r.revocations = append(r.revocations, revocation{sessionId: sessionId, userId: userId, expiresAt: expiresAt})
+	}
+
+	return nil
+}
+
+func (r *MemoryTokenRepository) IsRevoked(ctx context.Context, tokenId, sessionId string) (bool, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	now := time.Now()
+	for _, entry := range r.revocations {
+		if !entry.expiresAt.After(now) {
+			continue
+		}
+
+		switch {
+		case entry.tokenId != "" && entry.tokenId == tokenId:
+			return true, nil
+		case entry.sessionId != "" && entry.sessionId == sessionId:
+			return true, nil
+		}
+	}
+
+	return false, nil
+}
+
+func (r *MemoryTokenRepository) CreatePasswordReset(ctx context.Context, tokenHash, userId string, createdAt, expiresAt time.Time) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.pruneLocked(time.Now())
+
+	r.passwordResets[tokenHash] = passwordReset{userId: userId, expiresAt: expiresAt}
+	return nil
+}
+
+func (r *MemoryTokenRepository) ConsumePasswordReset(ctx context.Context, tokenHash string, now time.Time) (string, bool, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	reset, ok := r.passwordResets[tokenHash]
+	if !ok || !reset.expiresAt.After(now) {
+		return "", false, nil
+	}
+
+	for hash, other := range r.passwordResets {
+		if other.userId == reset.userId {
+			delete(r.passwordResets, hash)
+		}
+	}
+
+	return reset.userId, true, nil
+}
+
+func (r *MemoryTokenRepository) SaveOIDCLoginState(ctx context.Context, state models.OIDCLoginState) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.pruneLocked(time.Now())
+
+	r.oidcStates[state.State_hash] = state
+	return nil
+}
+
+func (r *MemoryTokenRepository) ConsumeOIDCLoginState(ctx context.Context, stateHash string, now time.Time) (models.OIDCLoginState, bool, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	state, ok := r.oidcStates[stateHash]
+	if !ok || !state.Expires_at.After(now) {
+		return models.OIDCLoginState{}, false, nil
+	}
+
+	delete(r.oidcStates, stateHash)
+	return state, true, nil
+}
+
+func (r *MemoryTokenRepository) DeleteByUser(ctx context.Context, userId string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	kept := r.revocations[:0]
+	for _, entry := range r.revocations {
+		if entry.userId != userId {
+			kept = append(kept, entry)
+		}
+	}
+	r.revocations = kept
+
+	for hash, reset := range r.passwordResets {
+		if reset.userId == userId {
+			delete(r.passwordResets, hash)
+		}
+	}
+
+	return nil
+}
+
+// pruneLocked drops the expired records, as the TTL indexes do in MongoDB.
+func (r *MemoryTokenRepository) pruneLocked(now time.Time) {
+	kept := r.revocations[:0]
+	for _, entry := range r.revocations {
+		if entry.expiresAt.After(now) {
+			kept = append(kept, entry)
+		}
+	}
+	r.revocations = kept
+
+	for hash, reset := range r.passwordResets {
+		if !reset.expiresAt.After(now) {
+			delete(r.passwordResets, hash)
+		}
+	}
+
+	for hash, state := range r.oidcStates {
+		if !state.Expires_at.After(now) {
+			delete(r.oidcStates, hash)
+		}
+	}
+}
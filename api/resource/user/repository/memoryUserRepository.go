@@ -0,0 +1,469 @@
+package repository
+
+import (
+	"context"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"movie-api/api/pagination"
+	models "movie-api/api/resource/user/model"
+)
+
+// MemoryUserRepository keeps the users in process memory. Users are copied on the
+// way in and out, so callers may change what they get without touching the stored accounts.
+type MemoryUserRepository struct {
+	mu    sync.Mutex
+	users map[string]models.User // by user_id
+}
+
+func NewMemoryUserRepository() *MemoryUserRepository {
+	return &MemoryUserRepository{users: map[string]models.User{}}
+}
+
+func (r *MemoryUserRepository) EnsureIndexes(ctx context.Context) error {
+	return nil
+}
+
+func (r *MemoryUserRepository) FindByID(ctx context.Context, userId string, includeDeleted bool) (models.User, error) {
+	return r.findOne(func(user *models.User) bool {
+		return user.User_id == userId && (includeDeleted || user.Deleted_at == nil)
+	})
+}
+
+func (r *MemoryUserRepository) FindByEmail(ctx context.Context, emailAddress string, includeDeleted bool) (models.User, error) {
+	return r.findOne(func(user *models.User) bool {
+		return stringValue(user.Email_address) == emailAddress && (includeDeleted || user.Deleted_at == nil)
+	})
+}
+
+func (r *MemoryUserRepository) FindByOIDCIdentity(ctx context.Context, issuer, subject string) (models.User, error) {
+	return r.findOne(func(user *models.User) bool {
+		if user.Deleted_at != nil {
+			return false
+		}
+		for _, identity := range user.Oidc_identities {
+			if identity.Issuer == issuer && identity.Subject == subject {
+				return true
+			}
+		}
+		return false
+	})
+}
+
+func (r *MemoryUserRepository) EmailExists(ctx context.Context, emailAddress string) (bool, error) {
+	_, err := r.FindByEmail(ctx, emailAddress, true)
+	if err == ErrUserNotFound {
+		return false, nil
+	}
+
+	return err == nil, err
+}
+
+func (r *MemoryUserRepository) PhoneNumberExists(ctx context.Context, phoneNumber, exceptUserId string) (bool, error) {
+	_, err := r.findOne(func(user *models.User) bool {
+		return stringValue(user.Phone_number) == phoneNumber && user.User_id != exceptUserId
+	})
+	if err == ErrUserNotFound {
+		return false, nil
+	}
+
+	return err == nil, err
+}
+
+func (r *MemoryUserRepository) Insert(ctx context.Context, user models.User) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if _, ok := r.users[user.User_id]; ok {
+		return ErrDuplicateUserID
+	}
+	for _, other := range r.users {
+		if user.Email_address != nil && stringValue(other.Email_address) == *user.Email_address {
+			return ErrDuplicateEmail
+		}
+	}
+	if user.Phone_number != nil && r.phoneNumberTakenLocked(*user.Phone_number, user.User_id) {
+		return ErrDuplicatePhoneNumber
+	}
+
+	r.users[user.User_id] = cloneUser(user)
+	return nil
+}
+
+func (r *MemoryUserRepository) List(ctx context.Context, filter models.UserFilter, sortFields []pagination.SortField, params pagination.Params) ([]models.User, int64, error) {
+	r.mu.Lock()
+	users := []models.User{}
+	for _, user := range r.users {
+		if matchesUserFilter(&user, filter) {
+			users = append(users, withoutSecrets(cloneUser(user)))
+		}
+	}
+	r.mu.Unlock()
+
+	sort.Slice(users, func(i, j int) bool {
+		for _, field := range sortFields {
+			order := 0
+			if field.Field == "created_at" {
+				order = users[i].Created_at.Compare(users[j].Created_at)
+			}
+			if field.Descending {
+				order = -order
+			}
+			if order != 0 {
+				return order < 0
+			}
+		}
+		// Ties are broken by _id, as in MongoDB
+		return users[i].ID.Hex() < users[j].ID.Hex()
+	})
+
+	total := int64(len(users))
+	start := params.Skip()
+	if start > total {
+		start = total
+	}
+	end := start + int64(params.Limit)
+	if end > total {
+		end = total
+	}
+
+	return users[start:end], total, nil
+}
+
+func (r *MemoryUserRepository) UpdateProfile(ctx context.Context, userId string, changes models.UpdateUserRequest, updatedAt time.Time) (models.User, error) {
+	phoneNumberTaken := false
+	user, err := r.update(userId, false, func(user *models.User) bool {
+		if changes.Phone_number != nil && r.phoneNumberTakenLocked(*changes.Phone_number, userId) {
+			phoneNumberTaken = true
+			return false
+		}
+		if changes.First_name != nil {
+			user.First_name = stringPtr(*changes.First_name)
+		}
+		if changes.Last_name != nil {
+			user.Last_name = stringPtr(*changes.Last_name)
+		}
+		if changes.Profile_photo != nil {
+			user.Profile_photo = stringPtr(*changes.Profile_photo)
+		}
+		if changes.User_type != nil {
+			user.User_type = stringPtr(*changes.User_type)
+		}
+		if changes.Phone_number != nil {
+			user.Phone_number = stringPtr(*changes.Phone_number)
+		}
+		user.Updated_at = updatedAt
+		return true
+	})
+	if phoneNumberTaken {
+		return models.User{}, ErrDuplicatePhoneNumber
+	}
+
+	return user, err
+}
+
+func (r *MemoryUserRepository) SetPassword(ctx context.Context, userId, passwordHash string, updatedAt time.Time) error {
+	_, err := r.update(userId, true, func(user *models.User) bool {
+		user.Password = stringPtr(passwordHash)
+		user.Updated_at = updatedAt
+		return true
+	})
+	if err == ErrUserNotFound {
+		return nil
+	}
+
+	return err
+}
+
+func (r *MemoryUserRepository) SoftDelete(ctx context.Context, userId string, deletedAt, purgeAfter time.Time) (bool, error) {
+	return matched(r.update(userId, false, func(user *models.User) bool {
+		user.Deleted_at = timePtr(deletedAt)
+		user.Purge_after = timePtr(purgeAfter)
+		user.Updated_at = deletedAt
+		return true
+	}))
+}
+
+func (r *MemoryUserRepository) Restore(ctx context.Context, userId string, updatedAt time.Time) (bool, error) {
+	return matched(r.update(userId, true, func(user *models.User) bool {
+		if user.Deleted_at == nil {
+			return false
+		}
+		user.Deleted_at = nil
+		user.Purge_after = nil
+		user.Updated_at = updatedAt
+		return true
+	}))
+}
+
+func (r *MemoryUserRepository) ListPurgeDue(ctx context.Context, now time.Time) ([]string, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	userIds := []string{}
+	for _, user := range r.users {
+		if user.Purge_after != nil && !user.Purge_after.After(now) {
+			userIds = append(userIds, user.User_id)
+		}
+	}
+
+	return userIds, nil
+}
+
+func (r *MemoryUserRepository) Delete(ctx context.Context, userId string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	delete(r.users, userId)
+	return nil
+}
+
+func (r *MemoryUserRepository) ClaimVerificationEmail(ctx context.Context, userId string, now time.Time, resendInterval time.Duration) (bool, time.Time, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	user, ok := r.users[userId]
+	if !ok || user.Email_verified == nil || *user.Email_verified {
+		return false, time.Time{}, nil
+	}
+
+	if user.Verification_sent_at != nil && user.Verification_sent_at.After(now.Add(-resendInterval)) {
+		return true, *user.Verification_sent_at, nil
+	}
+
+	user.Verification_sent_at = timePtr(now)
+	r.users[userId] = user
+	return true, time.Time{}, nil
+}
+
+func (r *MemoryUserRepository) MarkEmailVerified(ctx context.Context, userId, emailAddress string, now time.Time) (bool, error) {
+	return matched(r.update(userId, false, func(user *models.User) bool {
+		if stringValue(user.Email_address) != emailAddress {
+			return false
+		}
+		verified := true
+		user.Email_verified = &verified
+		user.Email_verified_at = timePtr(now)
+		user.Verification_sent_at = nil
+		user.Updated_at = now
+		return true
+	}))
+}
+
+func (r *MemoryUserRepository) SetPendingMFASecret(ctx context.Context, userId, secret string) (bool, error) {
+	return matched(r.update(userId, false, func(user *models.User) bool {
+		if user.Mfa_enabled {
+			return false
+		}
+		user.Mfa_pending_secret = secret
+		return true
+	}))
+}
+
+func (r *MemoryUserRepository) EnableMFA(ctx context.Context, userId, pendingSecret string, recoveryCodeHashes []string, step int64, updatedAt time.Time) (bool, error) {
+	return matched(r.update(userId, true, func(user *models.User) bool {
+		if user.Mfa_pending_secret == "" || user.Mfa_pending_secret != pendingSecret {
+			return false
+		}
+		user.Mfa_enabled = true
+		user.Mfa_secret = pendingSecret
+		user.Mfa_pending_secret = ""
+		user.Mfa_recovery_codes = append([]string(nil), recoveryCodeHashes...)
+		user.Mfa_last_used_step = step
+		user.Updated_at = updatedAt
+		return true
+	}))
+}
+
+func (r *MemoryUserRepository) RecordMFAStep(ctx context.Context, userId string, step int64) (bool, error) {
+	return matched(r.update(userId, true, func(user *models.User) bool {
+		if !user.Mfa_enabled || user.Mfa_last_used_step >= step {
+			return false
+		}
+		user.Mfa_last_used_step = step
+		return true
+	}))
+}
+
+func (r *MemoryUserRepository) RemoveRecoveryCode(ctx context.Context, userId, hash string) (bool, error) {
+	return matched(r.update(userId, true, func(user *models.User) bool {
+		if !user.Mfa_enabled {
+			return false
+		}
+		for i, stored := range user.Mfa_recovery_codes {
+			if stored == hash {
+				user.Mfa_recovery_codes = append(user.Mfa_recovery_codes[:i:i], user.Mfa_recovery_codes[i+1:]...)
+				return true
+			}
+		}
+		return false
+	}))
+}
+
+func (r *MemoryUserRepository) SetRecoveryCodes(ctx context.Context, userId string, hashes []string, updatedAt time.Time) (bool, error) {
+	return matched(r.update(userId, false, func(user *models.User) bool {
+		if !user.Mfa_enabled {
+			return false
+		}
+		user.Mfa_recovery_codes = append([]string(nil), hashes...)
+		user.Updated_at = updatedAt
+		return true
+	}))
+}
+
+func (r *MemoryUserRepository) DisableMFA(ctx context.Context, userId string, updatedAt time.Time) (bool, error) {
+	return matched(r.update(userId, true, func(user *models.User) bool {
+		user.Mfa_enabled = false
+		user.Mfa_secret = ""
+		user.Mfa_pending_secret = ""
+		user.Mfa_recovery_codes = nil
+		user.Mfa_last_used_step = 0
+		user.Updated_at = updatedAt
+		return true
+	}))
+}
+
+func (r *MemoryUserRepository) TokenGeneration(ctx context.Context, userId string) (int64, error) {
+	user, err := r.FindByID(ctx, userId, true)
+	return user.Token_generation, err
+}
+
+func (r *MemoryUserRepository) IncrementTokenGeneration(ctx context.Context, userId string) (bool, error) {
+	return matched(r.update(userId, true, func(user *models.User) bool {
+		user.Token_generation++
+		return true
+	}))
+}
+
+func (r *MemoryUserRepository) LinkOIDCIdentity(ctx context.Context, userId string, identity models.OIDCIdentity, updatedAt time.Time) (models.User, error) {
+	return r.update(userId, true, func(user *models.User) bool {
+		user.Oidc_identities = append(user.Oidc_identities, identity)
+		user.Updated_at = updatedAt
+		return true
+	})
+}
+
+func (r *MemoryUserRepository) findOne(match func(user *models.User) bool) (models.User, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for _, user := range r.users {
+		if match(&user) {
+			return cloneUser(user), nil
+		}
+	}
+
+	return models.User{}, ErrUserNotFound
+}
+
+// update calls apply on a copy of the user and stores the copy when apply returns
+// true. It returns the updated user, or ErrUserNotFound when there is no such user
+// or apply returned false.
+func (r *MemoryUserRepository) update(userId string, includeDeleted bool, apply func(user *models.User) bool) (models.User, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	stored, ok := r.users[userId]
+	if !ok || (!includeDeleted && stored.Deleted_at != nil) {
+		return models.User{}, ErrUserNotFound
+	}
+
+	user := cloneUser(stored)
+	if !apply(&user) {
+		return models.User{}, ErrUserNotFound
+	}
+
+	r.users[userId] = user
+	return cloneUser(user), nil
+}
+
+// phoneNumberTakenLocked reports whether a user other than exceptUserId, deleted or not,
+// has the phone number, as the unique index does in MongoDB. r.mu must be held.
+func (r *MemoryUserRepository) phoneNumberTakenLocked(phoneNumber, exceptUserId string) bool {
+	for _, user := range r.users {
+		if user.User_id != exceptUserId && stringValue(user.Phone_number) == phoneNumber {
+			return true
+		}
+	}
+
+	return false
+}
+
+// matched turns the result of update into the matched flag of the interface methods.
+func matched(user models.User, err error) (bool, error) {
+	if err == ErrUserNotFound {
+		return false, nil
+	}
+
+	return err == nil, err
+}
+
+// matchesUserFilter applies filter the way MongoUserRepository.List does.
+func matchesUserFilter(user *models.User, filter models.UserFilter) bool {
+	switch filter.Status {
+	case "", "active":
+		if user.Deleted_at != nil {
+			return false
+		}
+	case "deleted":
+		if user.Deleted_at == nil {
+			return false
+		}
+	}
+
+	if filter.User_type != "" && stringValue(user.User_type) != filter.User_type {
+		return false
+	}
+
+	if filter.Query != "" {
+		query := strings.ToLower(filter.Query)
+		for _, field := range []*string{user.Email_address, user.First_name, user.Last_name} {
+			if strings.Contains(strings.ToLower(stringValue(field)), query) {
+				return true
+			}
+		}
+		return false
+	}
+
+	return true
+}
+
+// cloneUser copies user together with its slices. Pointer fields are shared: the
+// repository replaces them on update instead of writing through them.
+func cloneUser(user models.User) models.User {
+	if user.Mfa_recovery_codes != nil {
+		user.Mfa_recovery_codes = append([]string(nil), user.Mfa_recovery_codes...)
+	}
+	if user.Oidc_identities != nil {
+		user.Oidc_identities = append([]models.OIDCIdentity(nil), user.Oidc_identities...)
+	}
+
+	return user
+}
+
+// withoutSecrets clears the fields MongoUserRepository.List does not read.
+func withoutSecrets(user models.User) models.User {
+	user.Password = nil
+	user.Mfa_secret = ""
+	user.Mfa_pending_secret = ""
+	user.Mfa_recovery_codes = nil
+
+	return user
+}
+
+func stringValue(value *string) string {
+	if value == nil {
+		return ""
+	}
+	return *value
+}
+
+func stringPtr(value string) *string {
+	return &value
+}
+
+func timePtr(value time.Time) *time.Time {
+	return &value
+}
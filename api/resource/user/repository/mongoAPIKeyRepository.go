@@ -0,0 +1,113 @@
+package repository
+
+import (
+	"context"
+	"time"
+
+	models "movie-api/api/resource/user/model"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// MongoAPIKeyRepository keeps the API keys in a MongoDB collection.
+type MongoAPIKeyRepository struct {
+	apiKeys *mongo.Collection
+}
+
+func NewMongoAPIKeyRepository(apiKeys *mongo.Collection) *MongoAPIKeyRepository {
+	return &MongoAPIKeyRepository{apiKeys: apiKeys}
+}
+
+// EnsureIndexes creates the lookup indexes of the API keys.
+func (r *MongoAPIKeyRepository) EnsureIndexes(ctx context.Context) error {
+	indexes := []mongo.IndexModel{
+		{
+			Keys:    bson.D{{Key: "key_hash", Value: 1}},
+			Options: options.Index().SetUnique(true).SetName("key_hash_unique"),
+		},
+		{
+			Keys:    bson.D{{Key: "key_id", Value: 1}},
+			Options: options.Index().SetUnique(true).SetName("key_id_unique"),
+		},
+		{
+			Keys:    bson.D{{Key: "user_id", Value: 1}, {Key: "created_at", Value: -1}},
+			Options: options.Index().SetName("user_id_created_at"),
+		},
+	}
+
+	_, err := r.apiKeys.Indexes().CreateMany(ctx, indexes)
+	return err
+}
+
+func (r *MongoAPIKeyRepository) Insert(ctx context.Context, apiKey models.APIKey) error {
+	_, err := r.apiKeys.InsertOne(ctx, apiKey)
+	return err
+}
+
+func (r *MongoAPIKeyRepository) ListByUser(ctx context.Context, userId string) ([]models.APIKey, error) {
+	opts := options.Find().SetSort(bson.D{{Key: "created_at", Value: -1}})
+
+	cursor, err := r.apiKeys.Find(ctx, bson.M{"user_id": userId}, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	apiKeys := []models.APIKey{}
+	if err := cursor.All(ctx, &apiKeys); err != nil {
+		return nil, err
+	}
+
+	return apiKeys, nil
+}
+
+func (r *MongoAPIKeyRepository) Revoke(ctx context.Context, userId, keyId string, revokedAt time.Time) (bool, error) {
+	filter := bson.M{"user_id": userId, "key_id": keyId}
+
+	update := bson.M{"$set": bson.M{"revoked_at": revokedAt}}
+	result, err := r.apiKeys.UpdateOne(ctx, bson.M{"user_id": userId, "key_id": keyId, "revoked_at": nil}, update)
+	if err != nil {
+		return false, err
+	}
+	if result.MatchedCount == 1 {
+		return true, nil
+	}
+
+	count, err := r.apiKeys.CountDocuments(ctx, filter)
+	return count > 0, err
+}
+
+func (r *MongoAPIKeyRepository) FindActiveByHash(ctx context.Context, keyHash string, now time.Time) (models.APIKey, error) {
+	var apiKey models.APIKey
+
+	filter := bson.M{
+		"key_hash":   keyHash,
+		"revoked_at": nil,
+		"expires_at": bson.M{"$gt": now},
+	}
+	err := r.apiKeys.FindOne(ctx, filter).Decode(&apiKey)
+	if err == mongo.ErrNoDocuments {
+		return apiKey, ErrAPIKeyNotFound
+	}
+
+	return apiKey, err
+}
+
+func (r *MongoAPIKeyRepository) TouchLastUsed(ctx context.Context, keyId string, now, staleBefore time.Time) error {
+	filter := bson.M{
+		"key_id": keyId,
+		"$or": bson.A{
+			bson.M{"last_used_at": nil},
+			bson.M{"last_used_at": bson.M{"$lt": staleBefore}},
+		},
+	}
+
+	_, err := r.apiKeys.UpdateOne(ctx, filter, bson.M{"$set": bson.M{"last_used_at": now}})
+	return err
+}
+
+func (r *MongoAPIKeyRepository) DeleteByUser(ctx context.Context, userId string) error {
+	_, err := r.apiKeys.DeleteMany(ctx, bson.M{"user_id": userId})
+	return err
+}
@@ -0,0 +1,137 @@
+package repository
+
+import (
+	"context"
+	"time"
+
+	models "movie-api/api/resource/user/model"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// MongoSessionRepository keeps the sessions in a MongoDB collection. Sessions are
+// removed by Mongo once expires_at has passed, which is pushed back every time the
+// session's tokens are refreshed.
+type MongoSessionRepository struct {
+	sessions *mongo.Collection
+}
+
+func NewMongoSessionRepository(sessions *mongo.Collection) *MongoSessionRepository {
+	return &MongoSessionRepository{sessions: sessions}
+}
+
+// EnsureIndexes creates the TTL and lookup indexes of the sessions.
+func (r *MongoSessionRepository) EnsureIndexes(ctx context.Context) error {
+	indexes := []mongo.IndexModel{
+		{
+			Keys:    bson.D{{Key: "expires_at", Value: 1}},
+			Options: options.Index().SetExpireAfterSeconds(0).SetName("expires_at_ttl"),
+		},
+		{
+			Keys:    bson.D{{Key: "session_id", Value: 1}},
+			Options: options.Index().SetUnique(true).SetName("session_id_unique"),
+		},
+		{
+			Keys:    bson.D{{Key: "user_id", Value: 1}, {Key: "last_active_at", Value: -1}},
+			Options: options.Index().SetName("user_id_last_active_at"),
+		},
+	}
+
+	_, err := r.sessions.Indexes().CreateMany(ctx, indexes)
+	return err
+}
+
+func (r *MongoSessionRepository) Insert(ctx context.Context, session models.Session) error {
+	_, err := r.sessions.InsertOne(ctx, session)
+	return err
+}
+
+func (r *MongoSessionRepository) RotateRefreshToken(ctx context.Context, sessionId, currentHash string, next models.Session) (found, rotated bool, err error) {
+	filter := bson.M{"session_id": sessionId, "refresh_token_hash": currentHash}
+	update := bson.M{"$set": bson.M{
+		"refresh_token_hash": next.Refresh_token_hash,
+		"user_agent":         next.User_agent,
+		"device":             next.Device,
+		"ip_address":         next.Ip_address,
+		"last_active_at":     next.Last_active_at,
+		"expires_at":         next.Expires_at,
+	}}
+
+	result, err := r.sessions.UpdateOne(ctx, filter, update)
+	if err != nil {
+		return false, false, err
+	}
+	if result.MatchedCount == 1 {
+		return true, true, nil
+	}
+
+	count, err := r.sessions.CountDocuments(ctx, bson.M{"session_id": sessionId})
+	return count > 0, false, err
+}
+
+func (r *MongoSessionRepository) Touch(ctx context.Context, sessionId, ipAddress string, now, staleBefore time.Time) error {
+	filter := bson.M{"session_id": sessionId, "last_active_at": bson.M{"$lt": staleBefore}}
+	update := bson.M{"$set": bson.M{"last_active_at": now, "ip_address": ipAddress}}
+
+	_, err := r.sessions.UpdateOne(ctx, filter, update)
+	return err
+}
+
+func (r *MongoSessionRepository) ListByUser(ctx context.Context, userId string) ([]models.Session, error) {
+	opts := options.Find().SetSort(bson.D{{Key: "last_active_at", Value: -1}})
+
+	cursor, err := r.sessions.Find(ctx, bson.M{"user_id": userId}, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	sessions := []models.Session{}
+	if err := cursor.All(ctx, &sessions); err != nil {
+		return nil, err
+	}
+
+	return sessions, nil
+}
+
+func (r *MongoSessionRepository) Delete(ctx context.Context, userId, sessionId string) (bool, error) {
+	result, err := r.sessions.DeleteOne(ctx, bson.M{"user_id": userId, "session_id": sessionId})
+	if err != nil {
+		return false, err
+	}
+
+	return result.DeletedCount == 1, nil
+}
+
+func (r *MongoSessionRepository) DeleteOthers(ctx context.Context, userId, keepSessionId string) ([]string, error) {
+	filter := bson.M{"user_id": userId, "session_id": bson.M{"$ne": keepSessionId}}
+
+	var sessions []models.Session
+	cursor, err := r.sessions.Find(ctx, filter, options.Find().SetProjection(bson.M{"session_id": 1}))
+	if err != nil {
+		return nil, err
+	}
+	if err := cursor.All(ctx, &sessions); err != nil {
+		return nil, err
+	}
+	if len(sessions) == 0 {
+		return nil, nil
+	}
+
+	sessionIds := make([]string, 0, len(sessions))
+	for _, session := range sessions {
+		sessionIds = append(sessionIds, session.Session_id)
+	}
+
+	if _, err := r.sessions.DeleteMany(ctx, bson.M{"session_id": bson.M{"$in": sessionIds}}); err != nil {
+		return nil, err
+	}
+
+	return sessionIds, nil
+}
+
+func (r *MongoSessionRepository) DeleteByUser(ctx context.Context, userId string) error {
+	_, err := r.sessions.DeleteMany(ctx, bson.M{"user_id": userId})
+	return err
+}
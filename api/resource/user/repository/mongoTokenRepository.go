@@ -0,0 +1,209 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	models "movie-api/api/resource/user/model"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// indexNotFoundCode is the MongoDB error code for dropping an index that does not exist.
+const indexNotFoundCode int32 = 27
+
+// MongoTokenRepository keeps the token records in MongoDB collections, from which
+// Mongo removes them once expires_at has passed.
+//
+// The revocation list holds two kinds of entries:
+//   - {jti, user_id, expires_at}: a single token, kept until the token itself expires
+//   - {sid, user_id, expires_at}: every access token of an ended session, kept until the
+//     last of them has expired (the session's refresh token dies with the session)
+//
+// Revoking every token of a user is done through the user's token generation instead.
+//
+// Only SHA-256 hashes of password reset tokens and OpenID Connect states are stored,
+// so the collections cannot be used to reset passwords or log in if they leak.
+type MongoTokenRepository struct {
+	revokedTokens  *mongo.Collection
+	passwordResets *mongo.Collection
+	oidcStates     *mongo.Collection
+}
+
+func NewMongoTokenRepository(revokedTokens, passwordResets, oidcStates *mongo.Collection) *MongoTokenRepository {
+	return &MongoTokenRepository{revokedTokens: revokedTokens, passwordResets: passwordResets, oidcStates: oidcStates}
+}
+
+// EnsureIndexes creates the TTL and lookup indexes of the three collections.
+func (r *MongoTokenRepository) EnsureIndexes(ctx context.Context) error {
+	_, err := r.revokedTokens.Indexes().CreateMany(ctx, []mongo.IndexModel{
+		{
+			Keys:    bson.D{{Key: "expires_at", Value: 1}},
+			Options: options.Index().SetExpireAfterSeconds(0).SetName("expires_at_ttl"),
+		},
+		{
+			Keys: bson.D{{Key: "jti", Value: 1}},
+			Options: options.Index().
+				SetUnique(true).
+				SetPartialFilterExpression(bson.M{"jti": bson.M{"$exists": true}}).
+				SetName("jti_unique"),
+		},
+		{
+			Keys: bson.D{{Key: "sid", Value: 1}},
+			Options: options.Index().
+				SetPartialFilterExpression(bson.M{"sid": bson.M{"$exists": true}}).
+				SetName("sid"),
+		},
+	})
+	if err != nil {
+		return err
+	}
+
+	// Revoking every token of a user by issue time was replaced by the token generation
+	var commandErr mongo.CommandError
+	if _, err := r.revokedTokens.Indexes().DropOne(ctx, "user_id_revoked_before"); err != nil && !(errors.As(err, &commandErr) && commandErr.Code == indexNotFoundCode) {
+		return err
+	}
+
+	_, err = r.passwordResets.Indexes().CreateMany(ctx, []mongo.IndexModel{
+		{
+			Keys:    bson.D{{Key: "expires_at", Value: 1}},
+			Options: options.Index().SetExpireAfterSeconds(0).SetName("expires_at_ttl"),
+		},
+		{
+			Keys:    bson.D{{Key: "token_hash", Value: 1}},
+			Options: options.Index().SetUnique(true).SetName("token_hash_unique"),
+		},
+	})
+	if err != nil {
+		return err
+	}
+
+	_, err = r.oidcStates.Indexes().CreateMany(ctx, []mongo.IndexModel{
+		{
+			Keys:    bson.D{{Key: "expires_at", Value: 1}},
+			Options: options.Index().SetExpireAfterSeconds(0).SetName("expires_at_ttl"),
+		},
+		{
+			Keys:    bson.D{{Key: "state_hash", Value: 1}},
+			Options: options.Index().SetUnique(true).SetName("state_hash_unique"),
+		},
+	})
+	return err
+}
+
+func (r *MongoTokenRepository) RevokeToken(ctx context.Context, tokenId, userId string, expiresAt time.Time) error {
+	filter := bson.M{"jti": tokenId}
+	update := bson.M{"$set": bson.M{
+		"jti":        tokenId,
+		"user_id":    userId,
+		"expires_at": expiresAt,
+		"revoked_at": time.Now(),
+	}}
+
+	_, err := r.revokedTokens.UpdateOne(ctx, filter, update, options.Update().SetUpsert(true))
+	return err
+}
+
+func (r *MongoTokenRepository) RevokeSessions(ctx context.Context, userId string, sessionIds []string, expiresAt time.Time) error {
+	if len(sessionIds) == 0 {
+		return nil
+	}
+
+	now := time.Now()
+	entries := make([]interface{}, 0, len(sessionIds))
+	for _, sessionId := range sessionIds {
+		entries = append(entries, bson.M{
+			"sid":        sessionId,
+			"user_id":    userId,
+			"expires_at": expiresAt,
+			"revoked_at": now,
+		})
+	}
+
+	_, err := r.revokedTokens.InsertMany(ctx, entries)
+	return err
+}
+
+func (r *MongoTokenRepository) IsRevoked(ctx context.Context, tokenId, sessionId string) (bool, error) {
+	conditions := bson.A{bson.M{"jti": tokenId}}
+
+	if sessionId != "" {
+		conditions = append(conditions, bson.M{"sid": sessionId})
+	}
+
+	count, err := r.revokedTokens.CountDocuments(ctx, bson.M{"$or": conditions}, options.Count().SetLimit(1))
+	if err != nil {
+		return false, err
+	}
+
+	return count > 0, nil
+}
+
+func (r *MongoTokenRepository) CreatePasswordReset(ctx context.Context, tokenHash, userId string, createdAt, expiresAt time.Time) error {
+	_, err := r.passwordResets.InsertOne(ctx, bson.M{
+		"token_hash": tokenHash,
+		"user_id":    userId,
+		"created_at": createdAt,
+		"expires_at": expiresAt,
+	})
+	return err
+}
+
+func (r *MongoTokenRepository) ConsumePasswordReset(ctx context.Context, tokenHash string, now time.Time) (string, bool, error) {
+	var reset struct {
+		User_id string `bson:"user_id"`
+	}
+
+	filter := bson.M{
+		"token_hash": tokenHash,
+		"expires_at": bson.M{"$gt": now},
+	}
+
+	// Deleting the token claims it atomically, so it can only be redeemed once
+	err := r.passwordResets.FindOneAndDelete(ctx, filter).Decode(&reset)
+	if err == mongo.ErrNoDocuments {
+		return "", false, nil
+	}
+	if err != nil {
+		return "", false, err
+	}
+
+	if _, err := r.passwordResets.DeleteMany(ctx, bson.M{"user_id": reset.User_id}); err != nil {
+		return "", false, err
+	}
+
+	return reset.User_id, true, nil
+}
+
+func (r *MongoTokenRepository) SaveOIDCLoginState(ctx context.Context, state models.OIDCLoginState) error {
+	_, err := r.oidcStates.InsertOne(ctx, state)
+	return err
+}
+
+func (r *MongoTokenRepository) ConsumeOIDCLoginState(ctx context.Context, stateHash string, now time.Time) (models.OIDCLoginState, bool, error) {
+	var state models.OIDCLoginState
+
+	filter := bson.M{"state_hash": stateHash, "expires_at": bson.M{"$gt": now}}
+	err := r.oidcStates.FindOneAndDelete(ctx, filter).Decode(&state)
+	if err == mongo.ErrNoDocuments {
+		return state, false, nil
+	}
+	if err != nil {
+		return state, false, err
+	}
+
+	return state, true, nil
+}
+
+func (r *MongoTokenRepository) DeleteByUser(ctx context.Context, userId string) error {
+	if _, err := r.revokedTokens.DeleteMany(ctx, bson.M{"user_id": userId}); err != nil {
+		return err
+	}
+
+	_, err := r.passwordResets.DeleteMany(ctx, bson.M{"user_id": userId})
+	return err
+}
@@ -0,0 +1,452 @@
+package repository
+
+import (
+	"context"
+	"regexp"
+	"strings"
+	"time"
+
+	"movie-api/api/pagination"
+	models "movie-api/api/resource/user/model"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// secretUserFields are never read from the user collection when listing users.
+var secretUserFields = bson.M{
+	"password":           0,
+	"token":              0,
+	"refresh_token":      0,
+	"mfa_secret":         0,
+	"mfa_pending_secret": 0,
+	"mfa_recovery_codes": 0,
+}
+
+// MongoUserRepository keeps the users in a MongoDB collection.
+type MongoUserRepository struct {
+	users *mongo.Collection
+}
+
+func NewMongoUserRepository(users *mongo.Collection) *MongoUserRepository {
+	return &MongoUserRepository{users: users}
+}
+
+// activeUserFilter matches users that have not been deleted.
+func activeUserFilter(filter bson.M) bson.M {
+	filter["deleted_at"] = nil
+	return filter
+}
+
+// Names of the unique indexes, which duplicate key errors mention
+const (
+	userIDIndex       string = "user_id_unique"
+	emailAddressIndex string = "email_address_unique"
+	phoneNumberIndex  string = "phone_number_unique"
+)
+
+// EnsureIndexes creates the unique indexes of user_ids, email addresses, phone numbers
+// and linked OpenID Connect identities. Users without a phone number, such as those
+// created by an OpenID Connect login, are left out of its index.
+func (r *MongoUserRepository) EnsureIndexes(ctx context.Context) error {
+	_, err := r.users.Indexes().CreateMany(ctx, []mongo.IndexModel{
+		{
+			Keys:    bson.D{{Key: "user_id", Value: 1}},
+			Options: options.Index().SetUnique(true).SetName(userIDIndex),
+		},
+		{
+			Keys: bson.D{{Key: "email_address", Value: 1}},
+			Options: options.Index().
+				SetUnique(true).
+				SetPartialFilterExpression(bson.M{"email_address": bson.M{"$type": "string"}}).
+				SetName(emailAddressIndex),
+		},
+		{
+			Keys: bson.D{{Key: "phone_number", Value: 1}},
+			Options: options.Index().
+				SetUnique(true).
+				SetPartialFilterExpression(bson.M{"phone_number": bson.M{"$type": "string"}}).
+				SetName(phoneNumberIndex),
+		},
+		{
+			Keys: bson.D{{Key: "oidc_identities.issuer", Value: 1}, {Key: "oidc_identities.subject", Value: 1}},
+			Options: options.Index().
+				SetUnique(true).
+				SetPartialFilterExpression(bson.M{"oidc_identities": bson.M{"$exists": true}}).
+				SetName("oidc_identity_unique"),
+		},
+	})
+	return err
+}
+
+func (r *MongoUserRepository) FindByID(ctx context.Context, userId string, includeDeleted bool) (models.User, error) {
+	filter := bson.M{"user_id": userId}
+	if !includeDeleted {
+		filter = activeUserFilter(filter)
+	}
+
+	return r.findOne(ctx, filter)
+}
+
+func (r *MongoUserRepository) FindByEmail(ctx context.Context, emailAddress string, includeDeleted bool) (models.User, error) {
+	filter := bson.M{"email_address": emailAddress}
+	if !includeDeleted {
+		filter = activeUserFilter(filter)
+	}
+
+	return r.findOne(ctx, filter)
+}
+
+func (r *MongoUserRepository) FindByOIDCIdentity(ctx context.Context, issuer, subject string) (models.User, error) {
+	filter := bson.M{"oidc_identities": bson.M{"$elemMatch": bson.M{"issuer": issuer, "subject": subject}}}
+
+	return r.findOne(ctx, activeUserFilter(filter))
+}
+
+func (r *MongoUserRepository) EmailExists(ctx context.Context, emailAddress string) (bool, error) {
+	count, err := r.users.CountDocuments(ctx, bson.M{"email_address": emailAddress}, options.Count().SetLimit(1))
+	return count > 0, err
+}
+
+func (r *MongoUserRepository) PhoneNumberExists(ctx context.Context, phoneNumber, exceptUserId string) (bool, error) {
+	filter := bson.M{"phone_number": phoneNumber}
+	if exceptUserId != "" {
+		filter["user_id"] = bson.M{"$ne": exceptUserId}
+	}
+
+	count, err := r.users.CountDocuments(ctx, filter, options.Count().SetLimit(1))
+	return count > 0, err
+}
+
+func (r *MongoUserRepository) Insert(ctx context.Context, user models.User) error {
+	_, err := r.users.InsertOne(ctx, user)
+	return duplicateKeyError(err)
+}
+
+func (r *MongoUserRepository) List(ctx context.Context, filter models.UserFilter, sort []pagination.SortField, params pagination.Params) ([]models.User, int64, error) {
+	query := bson.M{}
+
+	switch filter.Status {
+	case "", "active":
+		query = activeUserFilter(query)
+	case "deleted":
+		query["deleted_at"] = bson.M{"$ne": nil}
+	}
+
+	if filter.User_type != "" {
+		query["user_type"] = filter.User_type
+	}
+
+	if filter.Query != "" {
+		pattern := primitive.Regex{Pattern: regexp.QuoteMeta(filter.Query), Options: "i"}
+		query["$or"] = bson.A{
+			bson.M{"email_address": pattern},
+			bson.M{"first_name": pattern},
+			bson.M{"last_name": pattern},
+		}
+	}
+
+	total, err := r.users.CountDocuments(ctx, query)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	sortQuery := bson.D{}
+	for _, field := range sort {
+		direction := 1
+		if field.Descending {
+			direction = -1
+		}
+		sortQuery = append(sortQuery, bson.E{Key: field.Field, Value: direction})
+	}
+	// _id keeps the order stable between pages when sort keys tie
+	sortQuery = append(sortQuery, bson.E{Key: "_id", Value: 1})
+
+	opts := options.Find().
+		SetProjection(secretUserFields).
+		SetSort(sortQuery).
+		SetSkip(params.Skip()).
+		SetLimit(int64(params.Limit))
+
+	cursor, err := r.users.Find(ctx, query, opts)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	users := []models.User{}
+	if err := cursor.All(ctx, &users); err != nil {
+		return nil, 0, err
+	}
+
+	return users, total, nil
+}
+
+func (r *MongoUserRepository) UpdateProfile(ctx context.Context, userId string, changes models.UpdateUserRequest, updatedAt time.Time) (models.User, error) {
+	updateObj := bson.M{"updated_at": updatedAt}
+	if changes.First_name != nil {
+		updateObj["first_name"] = *changes.First_name
+	}
+	if changes.Last_name != nil {
+		updateObj["last_name"] = *changes.Last_name
+	}
+	if changes.Profile_photo != nil {
+		updateObj["profile_photo"] = *changes.Profile_photo
+	}
+	if changes.User_type != nil {
+		updateObj["user_type"] = *changes.User_type
+	}
+	if changes.Phone_number != nil {
+		updateObj["phone_number"] = *changes.Phone_number
+	}
+
+	user, err := r.findOneAndUpdate(ctx, activeUserFilter(bson.M{"user_id": userId}), bson.M{"$set": updateObj})
+	return user, duplicateKeyError(err)
+}
+
+func (r *MongoUserRepository) SetPassword(ctx context.Context, userId, passwordHash string, updatedAt time.Time) error {
+	update := bson.M{"$set": bson.M{"password": passwordHash, "updated_at": updatedAt}}
+
+	_, err := r.users.UpdateOne(ctx, bson.M{"user_id": userId}, update)
+	return err
+}
+
+func (r *MongoUserRepository) SoftDelete(ctx context.Context, userId string, deletedAt, purgeAfter time.Time) (bool, error) {
+	update := bson.M{"$set": bson.M{
+		"deleted_at":  deletedAt,
+		"purge_after": purgeAfter,
+		"updated_at":  deletedAt,
+	}}
+
+	return r.updateOne(ctx, activeUserFilter(bson.M{"user_id": userId}), update)
+}
+
+func (r *MongoUserRepository) Restore(ctx context.Context, userId string, updatedAt time.Time) (bool, error) {
+	filter := bson.M{"user_id": userId, "deleted_at": bson.M{"$ne": nil}}
+	update := bson.M{
+		"$unset": bson.M{"deleted_at": "", "purge_after": ""},
+		"$set":   bson.M{"updated_at": updatedAt},
+	}
+
+	return r.updateOne(ctx, filter, update)
+}
+
+func (r *MongoUserRepository) ListPurgeDue(ctx context.Context, now time.Time) ([]string, error) {
+	opts := options.Find().SetProjection(bson.M{"user_id": 1})
+
+	cursor, err := r.users.Find(ctx, bson.M{"purge_after": bson.M{"$lte": now}}, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	var users []struct {
+		User_id string `bson:"user_id"`
+	}
+	if err := cursor.All(ctx, &users); err != nil {
+		return nil, err
+	}
+
+	userIds := make([]string, 0, len(users))
+	for _, user := range users {
+		userIds = append(userIds, user.User_id)
+	}
+
+	return userIds, nil
+}
+
+func (r *MongoUserRepository) Delete(ctx context.Context, userId string) error {
+	_, err := r.users.DeleteOne(ctx, bson.M{"user_id": userId})
+	return err
+}
+
+func (r *MongoUserRepository) ClaimVerificationEmail(ctx context.Context, userId string, now time.Time, resendInterval time.Duration) (bool, time.Time, error) {
+	filter := bson.M{
+		"user_id":        userId,
+		"email_verified": false,
+		"$or": bson.A{
+			bson.M{"verification_sent_at": nil},
+			bson.M{"verification_sent_at": bson.M{"$lte": now.Add(-resendInterval)}},
+		},
+	}
+	update := bson.M{"$set": bson.M{"verification_sent_at": now}}
+
+	claimed, err := r.updateOne(ctx, filter, update)
+	if err != nil || claimed {
+		return claimed, time.Time{}, err
+	}
+
+	// Nothing was updated: either the address is verified or an email went out recently
+	var user struct {
+		Verification_sent_at *time.Time `bson:"verification_sent_at"`
+	}
+	err = r.users.FindOne(ctx, bson.M{"user_id": userId, "email_verified": false}).Decode(&user)
+	if err == mongo.ErrNoDocuments || (err == nil && user.Verification_sent_at == nil) {
+		return false, time.Time{}, nil
+	}
+	if err != nil {
+		return false, time.Time{}, err
+	}
+
+	return true, *user.Verification_sent_at, nil
+}
+
+func (r *MongoUserRepository) MarkEmailVerified(ctx context.Context, userId, emailAddress string, now time.Time) (bool, error) {
+	filter := activeUserFilter(bson.M{"user_id": userId, "email_address": emailAddress})
+	update := bson.M{
+		"$set":   bson.M{"email_verified": true, "email_verified_at": now, "updated_at": now},
+		"$unset": bson.M{"verification_sent_at": ""},
+	}
+
+	return r.updateOne(ctx, filter, update)
+}
+
+func (r *MongoUserRepository) SetPendingMFASecret(ctx context.Context, userId, secret string) (bool, error) {
+	filter := activeUserFilter(bson.M{"user_id": userId, "mfa_enabled": bson.M{"$ne": true}})
+	update := bson.M{"$set": bson.M{"mfa_pending_secret": secret}}
+
+	return r.updateOne(ctx, filter, update)
+}
+
+func (r *MongoUserRepository) EnableMFA(ctx context.Context, userId, pendingSecret string, recoveryCodeHashes []string, step int64, updatedAt time.Time) (bool, error) {
+	filter := bson.M{"user_id": userId, "mfa_pending_secret": pendingSecret}
+	update := bson.M{
+		"$set": bson.M{
+			"mfa_enabled":        true,
+			"mfa_secret":         pendingSecret,
+			"mfa_recovery_codes": recoveryCodeHashes,
+			"mfa_last_used_step": step,
+			"updated_at":         updatedAt,
+		},
+		"$unset": bson.M{"mfa_pending_secret": ""},
+	}
+
+	return r.updateOne(ctx, filter, update)
+}
+
+func (r *MongoUserRepository) RecordMFAStep(ctx context.Context, userId string, step int64) (bool, error) {
+	filter := bson.M{
+		"user_id":     userId,
+		"mfa_enabled": true,
+		"$or": bson.A{
+			bson.M{"mfa_last_used_step": nil},
+			bson.M{"mfa_last_used_step": bson.M{"$lt": step}},
+		},
+	}
+	update := bson.M{"$set": bson.M{"mfa_last_used_step": step}}
+
+	return r.updateOne(ctx, filter, update)
+}
+
+func (r *MongoUserRepository) RemoveRecoveryCode(ctx context.Context, userId, hash string) (bool, error) {
+	filter := bson.M{"user_id": userId, "mfa_enabled": true, "mfa_recovery_codes": hash}
+	update := bson.M{"$pull": bson.M{"mfa_recovery_codes": hash}}
+
+	result, err := r.users.UpdateOne(ctx, filter, update)
+	if err != nil {
+		return false, err
+	}
+
+	return result.ModifiedCount == 1, nil
+}
+
+func (r *MongoUserRepository) SetRecoveryCodes(ctx context.Context, userId string, hashes []string, updatedAt time.Time) (bool, error) {
+	filter := activeUserFilter(bson.M{"user_id": userId, "mfa_enabled": true})
+	update := bson.M{"$set": bson.M{"mfa_recovery_codes": hashes, "updated_at": updatedAt}}
+
+	return r.updateOne(ctx, filter, update)
+}
+
+func (r *MongoUserRepository) DisableMFA(ctx context.Context, userId string, updatedAt time.Time) (bool, error) {
+	update := bson.M{
+		"$set": bson.M{"updated_at": updatedAt},
+		"$unset": bson.M{
+			"mfa_enabled":        "",
+			"mfa_secret":         "",
+			"mfa_pending_secret": "",
+			"mfa_recovery_codes": "",
+			"mfa_last_used_step": "",
+		},
+	}
+
+	return r.updateOne(ctx, bson.M{"user_id": userId}, update)
+}
+
+func (r *MongoUserRepository) TokenGeneration(ctx context.Context, userId string) (int64, error) {
+	var user models.User
+
+	opts := options.FindOne().SetProjection(bson.M{"token_generation": 1})
+	err := r.users.FindOne(ctx, bson.M{"user_id": userId}, opts).Decode(&user)
+	if err == mongo.ErrNoDocuments {
+		return 0, ErrUserNotFound
+	}
+
+	return user.Token_generation, err
+}
+
+func (r *MongoUserRepository) IncrementTokenGeneration(ctx context.Context, userId string) (bool, error) {
+	update := bson.M{"$inc": bson.M{"token_generation": 1}}
+
+	return r.updateOne(ctx, bson.M{"user_id": userId}, update)
+}
+
+func (r *MongoUserRepository) LinkOIDCIdentity(ctx context.Context, userId string, identity models.OIDCIdentity, updatedAt time.Time) (models.User, error) {
+	update := bson.M{
+		"$push": bson.M{"oidc_identities": identity},
+		"$set":  bson.M{"updated_at": updatedAt},
+	}
+
+	return r.findOneAndUpdate(ctx, bson.M{"user_id": userId}, update)
+}
+
+func (r *MongoUserRepository) findOne(ctx context.Context, filter bson.M) (models.User, error) {
+	var user models.User
+
+	err := r.users.FindOne(ctx, filter).Decode(&user)
+	if err == mongo.ErrNoDocuments {
+		return user, ErrUserNotFound
+	}
+
+	return user, err
+}
+
+// findOneAndUpdate applies update to the user matching filter and returns the updated user.
+func (r *MongoUserRepository) findOneAndUpdate(ctx context.Context, filter, update bson.M) (models.User, error) {
+	var user models.User
+
+	opts := options.FindOneAndUpdate().SetReturnDocument(options.After)
+	err := r.users.FindOneAndUpdate(ctx, filter, update, opts).Decode(&user)
+	if err == mongo.ErrNoDocuments {
+		return user, ErrUserNotFound
+	}
+
+	return user, err
+}
+
+// duplicateKeyError maps a duplicate key error to the error of the unique index it names.
+func duplicateKeyError(err error) error {
+	if !mongo.IsDuplicateKeyError(err) {
+		return err
+	}
+
+	switch message := err.Error(); {
+	case strings.Contains(message, "index: "+emailAddressIndex):
+		return ErrDuplicateEmail
+	case strings.Contains(message, "index: "+phoneNumberIndex):
+		return ErrDuplicatePhoneNumber
+	case strings.Contains(message, "index: "+userIDIndex):
+		return ErrDuplicateUserID
+	}
+
+	return err
+}
+
+// updateOne applies update to the user matching filter and reports whether there was one.
+func (r *MongoUserRepository) updateOne(ctx context.Context, filter, update bson.M) (bool, error) {
+	result, err := r.users.UpdateOne(ctx, filter, update)
+	if err != nil {
+		return false, err
+	}
+
+	return result.MatchedCount == 1, nil
+}
@@ -0,0 +1,188 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"movie-api/api/pagination"
+	models "movie-api/api/resource/user/model"
+)
+
+// ErrUserNotFound is returned when no user matches a lookup or an update.
+var ErrUserNotFound = errors.New("user not found")
+
+// ErrDuplicateEmail and ErrDuplicatePhoneNumber are returned when another user, deleted
+// or not, already has the email address or phone number. ErrDuplicateUserID is returned
+// when the user_id is taken.
+var (
+	ErrDuplicateEmail       = errors.New("email address already exists")
+	ErrDuplicatePhoneNumber = errors.New("phone number already exists")
+	ErrDuplicateUserID      = errors.New("user_id already exists")
+)
+
+// ErrAPIKeyNotFound is returned when no usable API key has the requested hash.
+var ErrAPIKeyNotFound = errors.New("API key not found")
+
+// The repositories below are implemented twice: the Mongo* types are used in
+// production, the Memory* types keep everything in process memory for tests and
+// offline runs. Updates that guard against concurrent requests (the MFA methods,
+// RotateRefreshToken, ...) only apply while the stored state still matches, and
+// report through their bool result whether they did.
+
+// UserRepository stores the user accounts. Methods that take no includeDeleted
+// argument only see users that have not been deleted, unless documented otherwise.
+type UserRepository interface {
+	// EnsureIndexes prepares the storage for the user queries. It is safe to call on every start.
+	EnsureIndexes(ctx context.Context) error
+
+	// FindByID returns the user with userId, or ErrUserNotFound.
+	FindByID(ctx context.Context, userId string, includeDeleted bool) (models.User, error)
+	// FindByEmail returns the user with the email address, or ErrUserNotFound.
+	FindByEmail(ctx context.Context, emailAddress string, includeDeleted bool) (models.User, error)
+	// FindByOIDCIdentity returns the user an OpenID Connect identity is linked to, or ErrUserNotFound.
+	FindByOIDCIdentity(ctx context.Context, issuer, subject string) (models.User, error)
+	// EmailExists reports whether any user, deleted or not, has the email address.
+	EmailExists(ctx context.Context, emailAddress string) (bool, error)
+	// PhoneNumberExists reports whether any user other than exceptUserId, deleted or not,
+	// has the phone number. exceptUserId may be empty.
+	PhoneNumberExists(ctx context.Context, phoneNumber, exceptUserId string) (bool, error)
+	// Insert stores a new user. It returns ErrDuplicateEmail, ErrDuplicatePhoneNumber or
+	// ErrDuplicateUserID when another user already has one of them.
+	Insert(ctx context.Context, user models.User) error
+	// List returns one page of the users matching filter in the given sort order, together
+	// with the number of matching users across all pages. Password hashes and MFA secrets
+	// are left out.
+	List(ctx context.Context, filter models.UserFilter, sort []pagination.SortField, params pagination.Params) ([]models.User, int64, error)
+	// UpdateProfile applies the fields set in changes and returns the updated user, or
+	// ErrUserNotFound. A phone number another user has gives ErrDuplicatePhoneNumber.
+	UpdateProfile(ctx context.Context, userId string, changes models.UpdateUserRequest, updatedAt time.Time) (models.User, error)
+	// SetPassword replaces the password hash of the user, deleted or not.
+	SetPassword(ctx context.Context, userId, passwordHash string, updatedAt time.Time) error
+
+	// SoftDelete marks the user as deleted until purgeAfter. found is false when there is
+	// no active user with userId.
+	SoftDelete(ctx context.Context, userId string, deletedAt, purgeAfter time.Time) (found bool, err error)
+	// Restore cancels a deletion. found is false when there is no deleted user with userId.
+	Restore(ctx context.Context, userId string, updatedAt time.Time) (found bool, err error)
+	// ListPurgeDue returns the user_ids of the deleted users to purge at now.
+	ListPurgeDue(ctx context.Context, now time.Time) ([]string, error)
+	// Delete permanently removes the user, deleted or not.
+	Delete(ctx context.Context, userId string) error
+
+	// ClaimVerificationEmail records that a verification email goes out at now, unless
+	// the previous one went out less than resendInterval ago; lastSentAt then tells when.
+	// found is false when the user, deleted or not, has no unverified email address.
+	ClaimVerificationEmail(ctx context.Context, userId string, now time.Time, resendInterval time.Duration) (found bool, lastSentAt time.Time, err error)
+	// MarkEmailVerified marks the email address as verified while the user still has it.
+	MarkEmailVerified(ctx context.Context, userId, emailAddress string, now time.Time) (found bool, err error)
+
+	// SetPendingMFASecret starts an MFA enrolment of a user who has not enabled MFA yet.
+	SetPendingMFASecret(ctx context.Context, userId, secret string) (bool, error)
+	// EnableMFA turns on MFA with the pending secret while it is still pendingSecret.
+	// The user may be deleted.
+	EnableMFA(ctx context.Context, userId, pendingSecret string, recoveryCodeHashes []string, step int64, updatedAt time.Time) (bool, error)
+	// RecordMFAStep records the time step of a used code while the user has MFA enabled
+	// and the step is newer than the last one. The user may be deleted.
+	RecordMFAStep(ctx context.Context, userId string, step int64) (bool, error)
+	// RemoveRecoveryCode removes a recovery code hash while the user has MFA enabled
+	// and still has the hash. The user may be deleted.
+	RemoveRecoveryCode(ctx context.Context, userId, hash string) (bool, error)
+	// SetRecoveryCodes replaces the recovery code hashes of a user with MFA enabled.
+	SetRecoveryCodes(ctx context.Context, userId string, hashes []string, updatedAt time.Time) (bool, error)
+	// DisableMFA forgets the MFA secrets and recovery codes of the user, deleted or not.
+	DisableMFA(ctx context.Context, userId string, updatedAt time.Time) (found bool, err error)
+
+	// TokenGeneration returns the token generation of the user, deleted or not, or ErrUserNotFound.
+	TokenGeneration(ctx context.Context, userId string) (int64, error)
+	// IncrementTokenGeneration advances the token generation of the user, deleted or not.
+	IncrementTokenGeneration(ctx context.Context, userId string) (found bool, err error)
+
+	// LinkOIDCIdentity adds an OpenID Connect identity to the user, deleted or not,
+	// and returns the updated user, or ErrUserNotFound.
+	LinkOIDCIdentity(ctx context.Context, userId string, identity models.OIDCIdentity, updatedAt time.Time) (models.User, error)
+}
+
+// TokenRepository stores the short-lived records behind tokens: the revocation list,
+// password reset tokens and pending OpenID Connect logins. Records are forgotten once
+// their expiry time has passed.
+type TokenRepository interface {
+	// EnsureIndexes prepares the storage for the token queries. It is safe to call on every start.
+	EnsureIndexes(ctx context.Context) error
+
+	// RevokeToken revokes the token with the given JWT ID until expiresAt.
+	RevokeToken(ctx context.Context, tokenId, userId string, expiresAt time.Time) error
+	// RevokeSessions revokes every token of the sessions until expiresAt.
+	RevokeSessions(ctx context.Context, userId string, sessionIds []string, expiresAt time.Time) error
+	// IsRevoked reports whether a token is revoked by its JWT ID or its session (when
+	// sessionId is set).
+	IsRevoked(ctx context.Context, tokenId, sessionId string) (bool, error)
+
+	// CreatePasswordReset stores the hash of a password reset token.
+	CreatePasswordReset(ctx context.Context, tokenHash, userId string, createdAt, expiresAt time.Time) error
+	// ConsumePasswordReset redeems an unexpired reset token once and drops every other
+	// reset token of its user. found is false for unknown, expired or used tokens.
+	ConsumePasswordReset(ctx context.Context, tokenHash string, now time.Time) (userId string, found bool, err error)
+
+	// SaveOIDCLoginState stores a pending OpenID Connect login.
+	SaveOIDCLoginState(ctx context.Context, state models.OIDCLoginState) error
+	// ConsumeOIDCLoginState returns and forgets the unexpired pending login with the state hash.
+	ConsumeOIDCLoginState(ctx context.Context, stateHash string, now time.Time) (models.OIDCLoginState, bool, error)
+
+	// DeleteByUser forgets every record of the user.
+	DeleteByUser(ctx context.Context, userId string) error
+}
+
+// SessionRepository stores the users' sessions. A session is forgotten once its
+// Expires_at has passed.
+type SessionRepository interface {
+	// EnsureIndexes prepares the storage for the session queries. It is safe to call on every start.
+	EnsureIndexes(ctx context.Context) error
+
+	// Insert stores a new session.
+	Insert(ctx context.Context, session models.Session) error
+	// RotateRefreshToken stores the refresh token hash, client details and times of next
+	// in the session while its refresh token hash is still currentHash. found is false
+	// when the session has ended; rotated is false when the hash had already changed.
+	RotateRefreshToken(ctx context.Context, sessionId, currentHash string, next models.Session) (found, rotated bool, err error)
+	// Touch records activity from ipAddress at now on a session last active before staleBefore.
+	Touch(ctx context.Context, sessionId, ipAddress string, now, staleBefore time.Time) error
+	// ListByUser returns the user's sessions, most recently active first.
+	ListByUser(ctx context.Context, userId string) ([]models.Session, error)
+	// Delete ends one of the user's sessions. found is false when the user has no such session.
+	Delete(ctx context.Context, userId, sessionId string) (found bool, err error)
+	// DeleteOthers ends the user's sessions except keepSessionId, which may be empty,
+	// and returns the IDs of the ended sessions.
+	DeleteOthers(ctx context.Context, userId, keepSessionId string) ([]string, error)
+	// DeleteByUser ends all of the user's sessions.
+	DeleteByUser(ctx context.Context, userId string) error
+}
+
+// APIKeyRepository stores the users' API keys.
+type APIKeyRepository interface {
+	// EnsureIndexes prepares the storage for the API key queries. It is safe to call on every start.
+	EnsureIndexes(ctx context.Context) error
+
+	// Insert stores a new API key.
+	Insert(ctx context.Context, apiKey models.APIKey) error
+	// ListByUser returns the user's API keys, newest first, including revoked and expired ones.
+	ListByUser(ctx context.Context, userId string) ([]models.APIKey, error)
+	// Revoke revokes one of the user's API keys. Revoking twice keeps the first
+	// revocation time. found is false when the user has no such key.
+	Revoke(ctx context.Context, userId, keyId string, revokedAt time.Time) (found bool, err error)
+	// FindActiveByHash returns the unrevoked key with the hash that has not expired at now,
+	// or ErrAPIKeyNotFound.
+	FindActiveByHash(ctx context.Context, keyHash string, now time.Time) (models.APIKey, error)
+	// TouchLastUsed records that the key was used at now, unless it was already used after staleBefore.
+	TouchLastUsed(ctx context.Context, keyId string, now, staleBefore time.Time) error
+	// DeleteByUser removes all of the user's API keys.
+	DeleteByUser(ctx context.Context, userId string) error
+}
+
+// SettingsRepository stores the settings admins can change at runtime.
+type SettingsRepository interface {
+	// GetSecuritySettings returns the saved security settings, or the zero value before any were saved.
+	GetSecuritySettings(ctx context.Context) (models.SecuritySettings, error)
+	// SaveSecuritySettings replaces the security settings.
+	SaveSecuritySettings(ctx context.Context, settings models.SecuritySettings) error
+}
@@ -0,0 +1,70 @@
+package repository
+
+import (
+	"context"
+	"sync"
+
+	models "movie-api/api/resource/user/model"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// securitySettingsID is the _id of the security settings document.
+const securitySettingsID string = "security"
+
+// MongoSettingsRepository keeps each group of settings as one document of a MongoDB collection.
+type MongoSettingsRepository struct {
+	settings *mongo.Collection
+}
+
+func NewMongoSettingsRepository(settings *mongo.Collection) *MongoSettingsRepository {
+	return &MongoSettingsRepository{settings: settings}
+}
+
+func (r *MongoSettingsRepository) GetSecuritySettings(ctx context.Context) (models.SecuritySettings, error) {
+	var settings models.SecuritySettings
+
+	err := r.settings.FindOne(ctx, bson.M{"_id": securitySettingsID}).Decode(&settings)
+	if err == mongo.ErrNoDocuments {
+		return models.SecuritySettings{}, nil
+	}
+
+	return settings, err
+}
+
+func (r *MongoSettingsRepository) SaveSecuritySettings(ctx context.Context, settings models.SecuritySettings) error {
+	_, err := r.settings.UpdateOne(ctx,
+		bson.M{"_id": securitySettingsID},
+		bson.M{"$set": settings},
+		options.Update().SetUpsert(true),
+	)
+
+	return err
+}
+
+// MemorySettingsRepository keeps the settings in process memory.
+type MemorySettingsRepository struct {
+	mu       sync.Mutex
+	security models.SecuritySettings
+}
+
+func NewMemorySettingsRepository() *MemorySettingsRepository {
+	return &MemorySettingsRepository{}
+}
+
+func (r *MemorySettingsRepository) GetSecuritySettings(ctx context.Context) (models.SecuritySettings, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	return r.security, nil
+}
+
+func (r *MemorySettingsRepository) SaveSecuritySettings(ctx context.Context, settings models.SecuritySettings) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.security = settings
+	return nil
+}
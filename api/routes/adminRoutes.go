@@ -0,0 +1,18 @@
+package routes
+
+import (
+	middleware "movie-api/api/middleware"
+	"movie-api/api/resource/user/handler"
+
+	"github.com/gin-gonic/gin"
+)
+
+// AdminRoutes creates and returns a router for handling operations on settings.
+func AdminRoutes(r *gin.Engine, auth *middleware.Auth, h *handler.Handler) {
+	adminGroup := r.Group("/admin")
+
+	// Define endpoints for admin
+	adminGroup.Use(auth.Authenticate(), auth.RequirePermission(middleware.PermissionManageSettings))
+	adminGroup.GET("/settings/security", h.GetSecuritySettings())
+	adminGroup.PUT("/settings/security", h.UpdateSecuritySettings())
+}
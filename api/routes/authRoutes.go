@@ -1,16 +1,35 @@
 package routes
 
 import (
+	middleware "movie-api/api/middleware"
 	"movie-api/api/resource/user/handler"
 
 	"github.com/gin-gonic/gin"
 )
 
 // AuthRoutes creates and returns a router for handling operations on auth.
-func AuthRoutes(r *gin.Engine) {
+func AuthRoutes(r *gin.Engine, auth *middleware.Auth, h *handler.Handler) {
 	authGroup := r.Group("/auth")
 
 	// Define endpoints for auth
-	authGroup.POST("/login", handler.LoginUser())
-	authGroup.POST("/register", handler.RegisterUser())
+	authGroup.POST("/login", h.LoginUser())
+	authGroup.POST("/register", h.RegisterUser())
+	authGroup.POST("/refresh", h.RefreshTokens())
+	authGroup.POST("/login/mfa", h.LoginMFA())
+	authGroup.GET("/oidc/login", h.OIDCLogin())
+	authGroup.GET("/oidc/callback", h.OIDCCallback())
+	authGroup.POST("/logout", auth.AuthenticateForMFAEnrollment(), h.LogoutUser())
+	authGroup.POST("/logout-all", auth.AuthenticateForMFAEnrollment(), h.LogoutAllUser())
+	authGroup.POST("/forgot-password", h.ForgotPassword())
+	authGroup.POST("/reset-password", h.ResetPassword())
+	authGroup.GET("/verify-email", h.VerifyEmail())
+	authGroup.POST("/verify-email/resend", auth.Authenticate(), h.ResendVerificationEmail())
+
+	// Two-factor authentication of the authenticated user
+	mfaGroup := authGroup.Group("/mfa")
+	mfaGroup.Use(auth.AuthenticateForMFAEnrollment())
+	mfaGroup.POST("/enroll", h.EnrollMFA())
+	mfaGroup.POST("/confirm", h.ConfirmMFA())
+	mfaGroup.POST("/disable", h.DisableMFA())
+	mfaGroup.POST("/recovery-codes", h.RegenerateRecoveryCodes())
 }
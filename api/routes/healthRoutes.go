@@ -0,0 +1,14 @@
+package routes
+
+import (
+	"movie-api/api/health"
+
+	"github.com/gin-gonic/gin"
+)
+
+// HealthRoutes creates and returns a router for the liveness and readiness probes.
+func HealthRoutes(r *gin.Engine, checker *health.Checker) {
+	// Define endpoints for the probes
+	r.GET("/healthz", checker.Liveness())
+	r.GET("/readyz", checker.Readiness())
+}
@@ -8,13 +8,23 @@ import (
 )
 
 // MoviesRoutes creates and returns a router for handling CRUD operations on movies.
-func MoviesRoutes(r *gin.Engine) {
+func MoviesRoutes(r *gin.Engine, auth *middleware.Auth, h *handler.Handler) {
 	moviesGroup := r.Group("/movies")
 
 	// Define CRUD endpoints for movies
-	moviesGroup.Use(middleware.Authenticate())
-	moviesGroup.GET("/", handler.GetMovies())
-	moviesGroup.GET("/:movie_id", handler.GetMovieByID())
-	moviesGroup.GET("/:movie_id/cast", handler.GetMovieByIDCast())
-	moviesGroup.GET("/:movie_id/similar_movies", handler.GetMovieByIDSimilarMoviesByGenre())
+	moviesGroup.Use(auth.Authenticate())
+
+	readGroup := moviesGroup.Group("", auth.RequirePermission(middleware.PermissionReadMovies))
+	readGroup.GET("/", h.GetMovies())
+	readGroup.GET("/search", h.SearchMovies())
+	readGroup.GET("/:movie_id", h.GetMovieByID())
+	readGroup.GET("/:movie_id/cast", h.GetMovieByIDCast())
+	readGroup.GET("/:movie_id/similar_movies", h.GetMovieByIDSimilarMoviesByGenre())
+
+	writeGroup := moviesGroup.Group("", auth.RequirePermission(middleware.PermissionWriteMovies))
+	writeGroup.POST("/", h.CreateMovie())
+	writeGroup.POST("/import", h.ImportMovies())
+	writeGroup.PUT("/:movie_id", h.ReplaceMovie())
+	writeGroup.PATCH("/:movie_id", h.UpdateMovie())
+	writeGroup.DELETE("/:movie_id", h.DeleteMovie())
 }
@@ -8,11 +8,31 @@ import (
 )
 
 // UserRoutes creates and returns a router for handling operations on user.
-func UserRoutes(r *gin.Engine) {
+func UserRoutes(r *gin.Engine, auth *middleware.Auth, h *handler.Handler) {
 	userGroup := r.Group("/users")
 
 	// Define endpoints for user
-	userGroup.Use(middleware.Authenticate())
-	userGroup.GET("/:user_id", handler.GetUser())
-	// userGroup.GET("/register", handler.RegisterUser())
+	userGroup.Use(auth.Authenticate())
+	userGroup.GET("/", auth.RequirePermission(middleware.PermissionListUsers), h.GetUsers())
+	userGroup.GET("/:user_id", auth.RequireSelfOrPermission("user_id", middleware.PermissionReadUsers), h.GetUser())
+	userGroup.PATCH("/:user_id", auth.RequireSelfOrPermission("user_id", middleware.PermissionManageUsers), h.UpdateUser())
+	userGroup.DELETE("/:user_id", auth.RequireSelfOrPermission("user_id", middleware.PermissionManageUsers), h.DeleteUser())
+	userGroup.POST("/:user_id/password", middleware.RequireSelfOrRole("user_id"), h.ChangePassword())
+	userGroup.POST("/:user_id/restore", auth.RequirePermission(middleware.PermissionManageUsers), h.RestoreUser())
+	userGroup.POST("/:user_id/unlock", auth.RequirePermission(middleware.PermissionManageUsers), h.UnlockUser())
+	userGroup.GET("/:user_id/failed-logins", auth.RequirePermission(middleware.PermissionManageUsers), h.GetFailedLogins())
+	userGroup.DELETE("/:user_id/mfa", auth.RequirePermission(middleware.PermissionManageUsers), h.ResetUserMFA())
+
+	sessionGroup := userGroup.Group("/:user_id/sessions")
+	sessionGroup.Use(auth.RequireSelfOrPermission("user_id", middleware.PermissionManageUsers))
+	sessionGroup.GET("", h.GetSessions())
+	sessionGroup.DELETE("", h.RevokeOtherSessions())
+	sessionGroup.DELETE("/:session_id", h.RevokeSession())
+
+	// API keys are managed by people, never by other API keys
+	apiKeyGroup := userGroup.Group("/:user_id/api-keys")
+	apiKeyGroup.Use(middleware.DenyAPIKeys(), auth.RequireSelfOrPermission("user_id", middleware.PermissionManageUsers))
+	apiKeyGroup.GET("", h.GetAPIKeys())
+	apiKeyGroup.POST("", h.CreateAPIKey())
+	apiKeyGroup.DELETE("/:key_id", h.RevokeAPIKey())
 }
@@ -0,0 +1,15 @@
+package routes
+
+import (
+	"movie-api/api/resource/user/handler"
+
+	"github.com/gin-gonic/gin"
+)
+
+// WellKnownRoutes creates and returns a router for the /.well-known documents.
+func WellKnownRoutes(r *gin.Engine, h *handler.Handler) {
+	wellKnownGroup := r.Group("/.well-known")
+
+	// Define endpoints for well-known documents
+	wellKnownGroup.GET("/jwks.json", h.GetJWKS())
+}
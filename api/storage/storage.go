@@ -0,0 +1,104 @@
+package storage
+
+import (
+	"context"
+
+	"movie-api/api/database"
+	"movie-api/api/health"
+	"movie-api/api/loginguard"
+	movieRepository "movie-api/api/resource/movie/repository"
+	userRepository "movie-api/api/resource/user/repository"
+
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// Storage holds one implementation of every repository the API reads and writes.
+// Build it with NewMongo or NewMemory and hand it to the packages that serve requests.
+type Storage struct {
+	Movies        movieRepository.Repository
+	Users         userRepository.UserRepository
+	Tokens        userRepository.TokenRepository
+	Sessions      userRepository.SessionRepository
+	APIKeys       userRepository.APIKeyRepository
+	Settings      userRepository.SettingsRepository
+	LoginAttempts loginguard.Store
+
+	// client is the connection the repositories use. It is nil for in-memory storage.
+	client *mongo.Client
+}
+
+// NewMongo returns a Storage backed by the collections of the named database of client.
+func NewMongo(client *mongo.Client, databaseName string) *Storage {
+	collection := func(name string) *mongo.Collection {
+		return database.OpenCollection(client, databaseName, name)
+	}
+
+	return &Storage{
+		Movies:   movieRepository.NewMongoRepository(collection("movies")),
+		Users:    userRepository.NewMongoUserRepository(collection("user")),
+		Tokens:   userRepository.NewMongoTokenRepository(collection("revoked_tokens"), collection("password_resets"), collection("oidc_states")),
+		Sessions: userRepository.NewMongoSessionRepository(collection("sessions")),
+		APIKeys:  userRepository.NewMongoAPIKeyRepository(collection("api_keys")),
+		Settings: userRepository.NewMongoSettingsRepository(collection("settings")),
+
+		LoginAttempts: loginguard.NewMongoStore(collection("login_attempts"), collection("login_audit")),
+
+		client: client,
+	}
+}
+
+// NewMemory returns a Storage that keeps everything in process memory. It needs no
+// external service, which suits tests and offline runs; all data is lost on exit.
+func NewMemory() *Storage {
+	return &Storage{
+		Movies:   movieRepository.NewMemoryRepository(),
+		Users:    userRepository.NewMemoryUserRepository(),
+		Tokens:   userRepository.NewMemoryTokenRepository(),
+		Sessions: userRepository.NewMemorySessionRepository(),
+		APIKeys:  userRepository.NewMemoryAPIKeyRepository(),
+		Settings: userRepository.NewMemorySettingsRepository(),
+
+		LoginAttempts: loginguard.NewMemoryStore(),
+	}
+}
+
+// EnsureIndexes prepares every repository for its queries. It is safe to call on every start.
+func (s *Storage) EnsureIndexes(ctx context.Context) error {
+	for _, repository := range []interface{ EnsureIndexes(context.Context) error }{
+		s.Movies, s.Users, s.Tokens, s.Sessions, s.APIKeys,
+	} {
+		if err := repository.EnsureIndexes(ctx); err != nil {
+			return err
+		}
+	}
+
+	if store, ok := s.LoginAttempts.(*loginguard.MongoStore); ok {
+		return store.EnsureIndexes(ctx)
+	}
+
+	return nil
+}
+
+// Close disconnects from MongoDB, waiting for operations in progress until ctx is done.
+// The Storage cannot be used afterwards.
+func (s *Storage) Close(ctx context.Context) error {
+	if s.client == nil {
+		return nil
+	}
+
+	return s.client.Disconnect(ctx)
+}
+
+// HealthChecks returns the readiness checks of the backend: a ping of MongoDB, or none
+// for in-memory storage.
+func (s *Storage) HealthChecks() map[string]health.Check {
+	if s.client == nil {
+		return nil
+	}
+
+	return map[string]health.Check{
+		"mongo": func(ctx context.Context) error {
+			return s.client.Ping(ctx, nil)
+		},
+	}
+}
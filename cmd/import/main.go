@@ -0,0 +1,103 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"time"
+
+	"movie-api/api/config"
+	"movie-api/api/database"
+	"movie-api/api/resource/movie/importer"
+	movieRepository "movie-api/api/resource/movie/repository"
+	"movie-api/api/storage"
+)
+
+// Imports TMDB movie documents into the movies collection.
+//
+// Usage:
+//
+//	go run ./cmd/import sample-movie.json dump.ndjson
+//	cat dump.ndjson | go run ./cmd/import
+func main() {
+	timeout := flag.Duration("timeout", 30*time.Minute, "maximum duration of the whole import")
+	configFile := flag.String("config", "", "YAML or TOML config file (default $CONFIG_FILE)")
+	flag.Usage = func() {
+		fmt.Fprintf(flag.CommandLine.Output(), "Usage: %s [flags] [file ...]\n\nReads standard input when no file is given.\n\n", os.Args[0])
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+
+	settings, err := config.Load(*configFile)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), *timeout)
+	defer cancel()
+
+	client, err := database.Connect(ctx, settings.Database.URI)
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer client.Disconnect(context.Background())
+
+	movies := storage.NewMongo(client, settings.Database.Name).Movies
+	if err := movies.EnsureIndexes(ctx); err != nil {
+		log.Fatal("Error creating movie indexes: ", err)
+	}
+
+	paths := flag.Args()
+	if len(paths) == 0 {
+		paths = []string{"-"}
+	}
+
+	failed := false
+	for _, path := range paths {
+		report, err := importPath(ctx, movies, path)
+		if err != nil {
+			log.Printf("%s: %v", path, err)
+			failed = true
+		}
+		if report != nil {
+			printReport(path, report)
+			failed = failed || report.Failed > 0
+		}
+	}
+
+	if failed {
+		os.Exit(1)
+	}
+}
+
+func importPath(ctx context.Context, movies movieRepository.Repository, path string) (*importer.Report, error) {
+	var source io.Reader = os.Stdin
+
+	if path != "-" {
+		file, err := os.Open(path)
+		if err != nil {
+			return nil, err
+		}
+		defer file.Close()
+
+		source = file
+	}
+
+	return importer.Import(ctx, movies, source)
+}
+
+func printReport(path string, report *importer.Report) {
+	output, err := json.MarshalIndent(struct {
+		File string `json:"file"`
+		*importer.Report
+	}{path, report}, "", "  ")
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	fmt.Println(string(output))
+}
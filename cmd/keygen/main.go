@@ -0,0 +1,73 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"time"
+
+	"movie-api/api/jwtkeys"
+)
+
+// Adds a JWT signing key to a key directory and removes keys that can no longer have
+// valid tokens. Run it on a schedule to rotate keys: the new key is published in the
+// JWKS right away but only starts signing after -activate-in, giving other services
+// time to fetch it.
+func main() {
+	dir := flag.String("dir", "keys", "key directory (JWT_KEY_DIR)")
+	algorithm := flag.String("alg", jwtkeys.EdDSA, "signing algorithm, RS256 or EdDSA")
+	activateIn := flag.Duration("activate-in", time.Hour, "delay before the new key starts signing (ignored for the first key)")
+	retain := flag.Duration("retain", 7*24*time.Hour, "how long a superseded key keeps verifying; should be the refresh token lifetime")
+	prune := flag.Bool("prune", true, "remove keys superseded for longer than -retain")
+	flag.Parse()
+
+	if err := os.MkdirAll(*dir, 0o700); err != nil {
+		log.Fatal(err)
+	}
+
+	keys, err := jwtkeys.ReadDir(*dir)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	now := time.Now().UTC().Truncate(time.Second)
+	notBefore := now
+	if len(keys) > 0 {
+		notBefore = now.Add(*activateIn)
+	}
+
+	signer, err := jwtkeys.GenerateKey(*algorithm)
+	if err != nil {
+		log.Fatal(err)
+	}
+	data, err := jwtkeys.EncodeKey(signer, notBefore)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	kid := fmt.Sprintf("%s-%s", notBefore.Format("20060102T150405Z"), *algorithm)
+	path := filepath.Join(*dir, kid+".pem")
+	if err := os.WriteFile(path, data, 0o600); err != nil {
+		log.Fatal(err)
+	}
+	fmt.Printf("Added key %s, signing from %s\n", kid, notBefore.Format(time.RFC3339))
+
+	if !*prune {
+		return
+	}
+
+	// A key is superseded once the next key starts signing
+	for i := 0; i+1 < len(keys); i++ {
+		supersededAt := keys[i+1].Not_before
+		if supersededAt.After(now) || now.Sub(supersededAt) < *retain {
+			continue
+		}
+
+		if err := os.Remove(keys[i].Path); err != nil {
+			log.Fatal(err)
+		}
+		fmt.Printf("Removed key %s, superseded at %s\n", keys[i].ID, supersededAt.Format(time.RFC3339))
+	}
+}
@@ -0,0 +1,31 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"net/http"
+
+	"movie-api/api/oidc/mockprovider"
+)
+
+// Runs a mock OpenID Connect provider for trying the /auth/oidc login locally.
+func main() {
+	addr := flag.String("addr", "localhost:9000", "address to listen on")
+	issuer := flag.String("issuer", "", "issuer identifier (default http://<addr>)")
+	flag.Parse()
+
+	if *issuer == "" {
+		*issuer = "http://" + *addr
+	}
+
+	provider, err := mockprovider.New(*issuer)
+	if err != nil {
+		log.Fatal("Error creating the mock provider: ", err)
+	}
+
+	fmt.Printf("Mock OpenID Connect provider listening on %s. Configure the API with:\n", *addr)
+	fmt.Printf("  OIDC_ISSUER=%s\n  OIDC_CLIENT_ID=movie-api\n  OIDC_REDIRECT_URL=http://localhost:8080/auth/oidc/callback\n", provider.Issuer)
+
+	log.Fatal(http.ListenAndServe(*addr, provider))
+}
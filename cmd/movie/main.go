@@ -1,40 +1,80 @@
 package main
 
 import (
-	"fmt"
+	"context"
+	"flag"
 	"log"
-	routes "movie-api/api/routes"
+	"movie-api/api/app"
+	"movie-api/api/config"
+	"movie-api/api/database"
+	movieModels "movie-api/api/resource/movie/model"
+	"movie-api/api/storage"
 	"os"
-
-	"github.com/gin-gonic/gin"
-	"github.com/joho/godotenv"
+	"os/signal"
+	"syscall"
+	"time"
 )
 
 func main() {
-	err := godotenv.Load(".env")
+	configFile := flag.String("config", "", "YAML or TOML config file (default $CONFIG_FILE)")
+	printConfig := flag.Bool("print-config", false, "print the effective configuration with secrets redacted and exit")
+	flag.Parse()
+
+	// Settings come from the config file, .env and the environment, and are all checked before anything starts
+	settings, err := config.Load(*configFile)
 	if err != nil {
-		log.Fatal("Error loading .env file")
+		log.Fatal(err)
 	}
 
-	// Get PORT from .env
-	port := os.Getenv("PORT")
+	if *printConfig {
+		if err := settings.Print(os.Stdout); err != nil {
+			log.Fatal(err)
+		}
+		return
+	}
 
-	// If PORT is not declared, set to 0.0.0.0:8080
-	if port == "" {
-		port = "8080"
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	// The memory backend keeps all data in memory, so the API runs without MongoDB
+	var store *storage.Storage
+	switch settings.Database.Backend {
+	case "mongo":
+		client, err := database.Connect(ctx, settings.Database.URI)
+		if err != nil {
+			log.Fatal(err)
+		}
+		store = storage.NewMongo(client, settings.Database.Name)
+	case "memory":
+		store = storage.NewMemory()
 	}
 
-	// Initialize Gin router
-	router := gin.Default()
-	router.Use(gin.Logger())
+	// Prepare the collections
+	if err := store.EnsureIndexes(ctx); err != nil {
+		store.Close(context.Background())
+		log.Fatal("Error creating indexes: ", err)
+	}
+	if err := store.Movies.SeedIfEmpty(ctx, movieModels.SeedMovies); err != nil {
+		store.Close(context.Background())
+		log.Fatal("Error seeding movies: ", err)
+	}
 
-	// Use the routes
-	routes.MoviesRoutes(router)
-	routes.AuthRoutes(router)
-	routes.UserRoutes(router)
+	// Tokens cannot be issued or verified without keys, so New refuses to start without them
+	api, err := app.New(settings, store, log.Default())
+	if err != nil {
+		store.Close(context.Background())
+		log.Fatal(err)
+	}
 
-	fmt.Printf("Starting server on port: %v\n", port)
+	// Shut down gracefully on Ctrl-C and on SIGTERM from the container runtime. A second
+	// signal stops the process right away.
+	signalContext, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	go func() {
+		<-signalContext.Done()
+		stop()
+	}()
 
-	// Start the server
-	router.Run(":" + port) // listen and serve on port
+	if err := api.Run(signalContext); err != nil {
+		log.Fatal(err)
+	}
 }
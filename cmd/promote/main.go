@@ -0,0 +1,71 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"time"
+
+	"movie-api/api/config"
+	"movie-api/api/database"
+	"movie-api/api/middleware"
+	userModels "movie-api/api/resource/user/model"
+	userRepository "movie-api/api/resource/user/repository"
+	"movie-api/api/storage"
+)
+
+// Changes the role of an existing account. Sign-ups always create USER accounts, so
+// this is how the first admin is made; later admins can change roles through the API.
+//
+// Usage:
+//
+//	go run ./cmd/promote -email admin@example.com
+//	go run ./cmd/promote -email former-admin@example.com -role USER
+func main() {
+	email := flag.String("email", "", "email address of the account")
+	role := flag.String("role", middleware.RoleAdmin, "new role, ADMIN or USER")
+	configFile := flag.String("config", "", "YAML or TOML config file (default $CONFIG_FILE)")
+	flag.Usage = func() {
+		fmt.Fprintf(flag.CommandLine.Output(), "Usage: %s -email address [flags]\n\n", os.Args[0])
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+
+	if *email == "" || (*role != middleware.RoleAdmin && *role != middleware.RoleUser) {
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	settings, err := config.Load(*configFile)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	client, err := database.Connect(ctx, settings.Database.URI)
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer client.Disconnect(context.Background())
+
+	users := storage.NewMongo(client, settings.Database.Name).Users
+
+	user, err := users.FindByEmail(ctx, *email, false)
+	if errors.Is(err, userRepository.ErrUserNotFound) {
+		log.Fatalf("No active account with the email address %s", *email)
+	}
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	if _, err := users.UpdateProfile(ctx, user.User_id, userModels.UpdateUserRequest{User_type: role}, time.Now()); err != nil {
+		log.Fatal(err)
+	}
+
+	fmt.Printf("%s is now %s\n", *email, *role)
+}